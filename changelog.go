@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// maxRangeSubjects caps how many commit subjects are kept per commit range.
+const maxRangeSubjects = 5
+
+// commitRangeTimeout bounds the git lookups for all of a deploy's services.
+const commitRangeTimeout = 5 * time.Second
+
+// commitRange summarizes the commits a deploy brings in over what was live.
+type commitRange struct {
+	Count    int      `json:"count"`
+	Subjects []string `json:"subjects,omitempty"` // newest first, at most maxRangeSubjects
+}
+
+// lookupCommitRanges returns each service's commit range from previousTags to
+// tags. Services without a known range are left out. Services moving between
+// the same two tags share one lookup.
+func lookupCommitRanges(ctx context.Context, services []string, tags, previousTags map[string]string) map[string]*commitRange {
+	ctx, cancel := context.WithTimeout(ctx, commitRangeTimeout)
+	defer cancel()
+
+	ranges := make(map[string]*commitRange)
+	seen := make(map[[2]string]*commitRange)
+	for _, svc := range services {
+		key := [2]string{previousTags[svc], tags[svc]}
+		r, ok := seen[key]
+		if !ok {
+			r = lookupCommitRange(ctx, key[0], key[1])
+			seen[key] = r
+		}
+		if r != nil {
+			ranges[svc] = r
+		}
+	}
+	return ranges
+}
+
+// lookupCommitRange returns the commits in git log old..new, where old and new
+// are the SHAs embedded in the two build tags. It returns nil when either tag
+// can't be parsed, the SHAs are equal, or the local repo doesn't know them.
+func lookupCommitRange(ctx context.Context, oldTag, newTag string) *commitRange {
+	if oldTag == "" || newTag == "" {
+		return nil
+	}
+	oldT, err := parseTag(oldTag)
+	if err != nil {
+		return nil
+	}
+	newT, err := parseTag(newTag)
+	if err != nil {
+		return nil
+	}
+	if oldT.SHA == newT.SHA {
+		return nil
+	}
+
+	out, err := exec.CommandContext(ctx, "git", "log", "--format=%s", oldT.SHA+".."+newT.SHA).Output()
+	log := strings.TrimSpace(string(out))
+	if err != nil || log == "" {
+		return nil
+	}
+	subjects := strings.Split(log, "\n")
+	r := &commitRange{Count: len(subjects), Subjects: subjects}
+	if len(r.Subjects) > maxRangeSubjects {
+		r.Subjects = r.Subjects[:maxRangeSubjects]
+	}
+	return r
+}
+
+// String formats the range for display, e.g. "12 commits, incl. Fix login".
+func (r *commitRange) String() string {
+	noun := "commits"
+	if r.Count == 1 {
+		noun = "commit"
+	}
+	if len(r.Subjects) == 0 {
+		return fmt.Sprintf("%d %s", r.Count, noun)
+	}
+	return fmt.Sprintf("%d %s, incl. %s", r.Count, noun, r.Subjects[0])
+}
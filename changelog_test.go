@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLookupCommitRangeFromLocalGit(t *testing.T) {
+	oldSHA, err := gitOutput("git", "rev-parse", "--short=7", "HEAD~2")
+	if err != nil {
+		t.Skipf("not enough local git history: %v", err)
+	}
+	newSHA, err := gitOutput("git", "rev-parse", "--short=7", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse HEAD: %v", err)
+	}
+	subject, err := gitOutput("git", "log", "-1", "--format=%s")
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+
+	r := lookupCommitRange(context.Background(), "main-"+oldSHA+"-20250101000000", "main-"+newSHA+"-20250102000000")
+	if r == nil {
+		t.Fatal("expected a commit range")
+	}
+	if r.Count != 2 {
+		t.Errorf("Count = %d, want 2", r.Count)
+	}
+	if len(r.Subjects) == 0 || r.Subjects[0] != subject {
+		t.Errorf("Subjects = %v, want newest first starting with %q", r.Subjects, subject)
+	}
+}
+
+func TestLookupCommitRangeUnknown(t *testing.T) {
+	tests := []struct {
+		name   string
+		oldTag string
+		newTag string
+	}{
+		{"first deploy", "", "main-abc1234-20250101000000"},
+		{"unparseable tag", "latest", "main-abc1234-20250101000000"},
+		{"same sha", "main-abc1234-20250101000000", "main-abc1234-20250102000000"},
+		{"unknown shas", "main-0000000-20250101000000", "main-1111111-20250102000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r := lookupCommitRange(context.Background(), tt.oldTag, tt.newTag); r != nil {
+				t.Errorf("expected nil, got %+v", r)
+			}
+		})
+	}
+}
+
+func TestLookupCommitRanges(t *testing.T) {
+	oldSHA, err := gitOutput("git", "rev-parse", "--short=7", "HEAD~1")
+	if err != nil {
+		t.Skipf("not enough local git history: %v", err)
+	}
+	newSHA, err := gitOutput("git", "rev-parse", "--short=7", "HEAD")
+	if err != nil {
+		t.Fatalf("rev-parse HEAD: %v", err)
+	}
+
+	newTag := "main-" + newSHA + "-20250102000000"
+	tags := map[string]string{"api": newTag, "worker": newTag, "web": newTag}
+	previousTags := map[string]string{"api": "main-" + oldSHA + "-20250101000000", "worker": "main-" + oldSHA + "-20250101000000"}
+
+	ranges := lookupCommitRanges(context.Background(), []string{"api", "worker", "web"}, tags, previousTags)
+	if ranges["api"] == nil || ranges["api"].Count != 1 {
+		t.Errorf("api = %+v, want 1 commit", ranges["api"])
+	}
+	if ranges["worker"] != ranges["api"] {
+		t.Error("services between the same tags should share one lookup")
+	}
+	if _, ok := ranges["web"]; ok {
+		t.Error("a first deploy should have no range")
+	}
+}
+
+func TestCommitRangeString(t *testing.T) {
+	tests := []struct {
+		r    commitRange
+		want string
+	}{
+		{commitRange{Count: 12, Subjects: []string{"Fix login", "Add search"}}, "12 commits, incl. Fix login"},
+		{commitRange{Count: 1, Subjects: []string{"Fix login"}}, "1 commit, incl. Fix login"},
+		{commitRange{Count: 3}, "3 commits"},
+	}
+	for _, tt := range tests {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -11,8 +12,9 @@ import (
 func newBuildsCmd() *cobra.Command {
 	var (
 		limit    int
-		cfgPath  string
 		services []string
+		branch   string
+		utc      bool
 	)
 
 	cmd := &cobra.Command{
@@ -21,16 +23,17 @@ func newBuildsCmd() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := loadConfig(cfgPath)
+			cfg, err := loadCommandConfig(cmd)
 			if err != nil {
 				return err
 			}
 
 			ctx := cmd.Context()
-			p, err := newProviders(ctx, cfg)
+			p, err := newProviders(ctx, &cfg)
 			if err != nil {
 				return err
 			}
+			defer p.close()
 			allServices := sortedServiceNames(cfg)
 			if len(services) > 0 {
 				for _, s := range services {
@@ -45,10 +48,19 @@ func newBuildsCmd() *cobra.Command {
 				return fmt.Errorf("no builds provider available")
 			}
 
-			builds, err := bp.listBuilds(ctx, limit+1, 0)
+			// A branch's builds can be anywhere in the list, so filtering
+			// needs all of them.
+			fetch := limit + 1
+			if branch != "" {
+				fetch = math.MaxInt
+			}
+			builds, err := bp.listBuilds(ctx, fetch, 0)
 			if err != nil {
 				return fmt.Errorf("listing builds: %w", err)
 			}
+			if branch != "" {
+				builds = filterBuildsByBranch(builds, branch, cfg.MaxBranchLength)
+			}
 
 			hasMore := len(builds) > limit
 			if hasMore {
@@ -57,48 +69,106 @@ func newBuildsCmd() *cobra.Command {
 
 			enrichBuilds(builds)
 
-			fmt.Print(formatBuildsTable(builds, limit, hasMore))
+			loc := time.Local
+			if utc {
+				loc = time.UTC
+			}
+			fmt.Print(formatBuildsTable(builds, limit, hasMore, loc, time.Now()))
 			return nil
 		},
 	}
 
 	cmd.Flags().IntVar(&limit, "limit", 10, "maximum number of builds to show")
-	cmd.Flags().StringVarP(&cfgPath, "config", "c", "hoist.yml", "config file path")
 	cmd.Flags().StringSliceVarP(&services, "service", "s", nil, "filter by service (comma-separated)")
+	cmd.Flags().StringVar(&branch, "branch", "", "only show builds of this branch")
+	cmd.Flags().BoolVar(&utc, "utc", false, "show build times in UTC instead of local time")
 
 	return cmd
 }
 
+// filterBuildsByBranch returns the builds of branch, matched the same way
+// resolveBuildTag matches a branch name.
+func filterBuildsByBranch(builds []build, branch string, maxBranchLen int) []build {
+	var out []build
+	for _, b := range builds {
+		if branchMatches(b.Branch, branch, maxBranchLen) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// enrichBuilds fills in commit message and author for each build with a
+// single git invocation. SHAs unknown to the local repo are left blank.
 func enrichBuilds(builds []build) {
-	for i, b := range builds {
-		out, err := gitOutput("git", "log", "-1", "--format=%s\n%an", b.SHA)
-		if err != nil {
+	args := []string{"log", "--no-walk=unsorted", "--ignore-missing", "--format=%H%x00%s%x00%an"}
+	seen := make(map[string]bool)
+	for _, b := range builds {
+		if b.SHA != "" && !seen[b.SHA] {
+			seen[b.SHA] = true
+			args = append(args, b.SHA)
+		}
+	}
+	if len(seen) == 0 {
+		return
+	}
+
+	out, err := gitOutput("git", args...)
+	if err != nil {
+		return
+	}
+
+	type commitInfo struct{ message, author string }
+	var hashes []string
+	commits := make(map[string]commitInfo)
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, "\x00", 3)
+		if len(parts) != 3 {
 			continue
 		}
-		parts := strings.SplitN(out, "\n", 2)
-		if len(parts) >= 1 {
-			builds[i].Message = parts[0]
+		hashes = append(hashes, parts[0])
+		commits[parts[0]] = commitInfo{message: parts[1], author: parts[2]}
+	}
+
+	// Tags carry abbreviated SHAs, so match them against full hashes by prefix.
+	for i, b := range builds {
+		if b.SHA == "" {
+			continue
 		}
-		if len(parts) >= 2 {
-			builds[i].Author = parts[1]
+		for _, h := range hashes {
+			if strings.HasPrefix(h, b.SHA) {
+				builds[i].Message = commits[h].message
+				builds[i].Author = commits[h].author
+				break
+			}
 		}
 	}
 }
 
-func formatBuildTime(t time.Time) string {
+// formatBuildTime formats t in loc, with the year when it differs from now's
+// so older builds aren't mistaken for this year's.
+func formatBuildTime(t time.Time, loc *time.Location, now time.Time) string {
 	if t.IsZero() {
 		return ""
 	}
-	return t.Local().Format("Jan 02 15:04")
+	t = t.In(loc)
+	if t.Year() != now.In(loc).Year() {
+		return t.Format("Jan 02 2006 15:04")
+	}
+	return t.Format("Jan 02 15:04")
 }
 
-func formatBuildsTable(builds []build, limit int, hasMore bool) string {
+func formatBuildsTable(builds []build, limit int, hasMore bool, loc *time.Location, now time.Time) string {
 	if len(builds) == 0 {
 		return "No builds found.\n"
 	}
 
 	// Calculate column widths
-	buildW, commitW, authorW, timeW := len("BUILD"), len("COMMIT"), len("AUTHOR"), len("TIME")
+	timeHeader := "TIME"
+	if loc == time.UTC {
+		timeHeader = "TIME (UTC)"
+	}
+	buildW, commitW, authorW, timeW := len("BUILD"), len("COMMIT"), len("AUTHOR"), len(timeHeader)
 	for _, b := range builds {
 		if len(b.Tag) > buildW {
 			buildW = len(b.Tag)
@@ -109,16 +179,16 @@ func formatBuildsTable(builds []build, limit int, hasMore bool) string {
 		if len(b.Author) > authorW {
 			authorW = len(b.Author)
 		}
-		ts := formatBuildTime(b.Time)
+		ts := formatBuildTime(b.Time, loc, now)
 		if len(ts) > timeW {
 			timeW = len(ts)
 		}
 	}
 
 	var sb strings.Builder
-	fmt.Fprintf(&sb, "%-*s  %-*s  %-*s  %-*s\n", buildW, "BUILD", commitW, "COMMIT", authorW, "AUTHOR", timeW, "TIME")
+	fmt.Fprintf(&sb, "%-*s  %-*s  %-*s  %-*s\n", buildW, "BUILD", commitW, "COMMIT", authorW, "AUTHOR", timeW, timeHeader)
 	for _, b := range builds {
-		fmt.Fprintf(&sb, "%-*s  %-*s  %-*s  %-*s\n", buildW, b.Tag, commitW, b.Message, authorW, b.Author, timeW, formatBuildTime(b.Time))
+		fmt.Fprintf(&sb, "%-*s  %-*s  %-*s  %-*s\n", buildW, b.Tag, commitW, b.Message, authorW, b.Author, timeW, formatBuildTime(b.Time, loc, now))
 	}
 
 	if hasMore {
@@ -91,7 +91,7 @@ func TestFormatBuildsTable(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			output := formatBuildsTable(tt.builds, tt.limit, tt.hasMore)
+			output := formatBuildsTable(tt.builds, tt.limit, tt.hasMore, time.Local, now)
 			tt.check(t, output)
 		})
 	}
@@ -101,7 +101,7 @@ func TestFormatBuildsTableHeaders(t *testing.T) {
 	builds := []build{
 		{Tag: "main-abc1234-20250615103000", Message: "msg", Author: "who", Time: time.Now()},
 	}
-	output := formatBuildsTable(builds, 10, false)
+	output := formatBuildsTable(builds, 10, false, time.Local, time.Now())
 	for _, header := range []string{"BUILD", "COMMIT", "AUTHOR", "TIME"} {
 		if !strings.Contains(output, header) {
 			t.Errorf("expected header %q in output", header)
@@ -109,22 +109,86 @@ func TestFormatBuildsTableHeaders(t *testing.T) {
 	}
 }
 
+func TestFilterBuildsByBranch(t *testing.T) {
+	builds := []build{
+		{Tag: "main-abc1234-20250615103000", Branch: "main"},
+		{Tag: "feat-login-def5678-20250614103000", Branch: "feat-login"},
+		{Tag: "main-0123456-20250613103000", Branch: "main"},
+	}
+
+	got := filterBuildsByBranch(builds, "main", 0)
+	if len(got) != 2 || got[0].Tag != "main-abc1234-20250615103000" || got[1].Tag != "main-0123456-20250613103000" {
+		t.Errorf("main builds = %v", got)
+	}
+	// Branch names are matched as they appear in tags.
+	if got := filterBuildsByBranch(builds, "feat/login", 0); len(got) != 1 || got[0].Branch != "feat-login" {
+		t.Errorf("feat/login builds = %v", got)
+	}
+	if got := filterBuildsByBranch(builds, "release", 0); len(got) != 0 {
+		t.Errorf("release builds = %v, want none", got)
+	}
+}
+
+func TestFormatBuildsTableUTC(t *testing.T) {
+	builds := []build{
+		{Tag: "main-abc1234-20250615103000", Time: time.Date(2025, 6, 15, 10, 30, 0, 0, time.UTC)},
+	}
+	output := formatBuildsTable(builds, 10, false, time.UTC, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC))
+	if !strings.Contains(output, "TIME (UTC)") || !strings.Contains(output, "Jun 15 10:30") {
+		t.Errorf("expected UTC header and time, got:\n%s", output)
+	}
+}
+
 func TestFormatBuildTime(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
 	tests := []struct {
 		name string
 		time time.Time
+		loc  *time.Location
 		want string
 	}{
-		{"zero time", time.Time{}, ""},
-		{"valid time", time.Date(2025, 6, 15, 10, 30, 0, 0, time.Local), "Jun 15 10:30"},
+		{"zero time", time.Time{}, time.UTC, ""},
+		{"valid time", time.Date(2025, 6, 15, 10, 30, 0, 0, time.UTC), time.UTC, "Jun 15 10:30"},
+		{"converted to loc", time.Date(2025, 6, 15, 10, 30, 0, 0, time.UTC), nyc, "Jun 15 06:30"},
+		{"previous year", time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC), time.UTC, "Dec 31 2024 23:00"},
+		// The year is judged in loc: this is still 2025 in UTC.
+		{"year boundary in loc", time.Date(2025, 1, 1, 2, 0, 0, 0, time.UTC), nyc, "Dec 31 2024 21:00"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := formatBuildTime(tt.time)
+			got := formatBuildTime(tt.time, tt.loc, now)
 			if got != tt.want {
 				t.Errorf("formatBuildTime() = %q, want %q", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestEnrichBuildsFromLocalGit(t *testing.T) {
+	head, err := gitOutput("git", "log", "-1", "--format=%h%x00%s%x00%an")
+	if err != nil {
+		t.Skipf("no local git history: %v", err)
+	}
+	parts := strings.SplitN(head, "\x00", 3)
+
+	builds := []build{
+		{Tag: "a", SHA: parts[0]},
+		{Tag: "b", SHA: "0000000"},
+		{Tag: "c", SHA: parts[0]},
+	}
+	enrichBuilds(builds)
+
+	for _, i := range []int{0, 2} {
+		if builds[i].Message != parts[1] || builds[i].Author != parts[2] {
+			t.Errorf("builds[%d] = %q by %q, want %q by %q", i, builds[i].Message, builds[i].Author, parts[1], parts[2])
+		}
+	}
+	if builds[1].Message != "" || builds[1].Author != "" {
+		t.Errorf("unknown SHA should stay blank, got %q by %q", builds[1].Message, builds[1].Author)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// crontabDiffer is implemented by history providers that can compare live
+// crontabs with the config.
+type crontabDiffer interface {
+	crontabDiff(ctx context.Context, services []string, env string) ([]cronDrift, error)
+}
+
+func newCrontabDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "crontab-diff <env>",
+		Short:         "Compare live cronjob crontabs with the config",
+		Long:          "Compare the crontab entry of every cronjob service in an environment with the line its config renders for the deployed tag, and flag entries that were edited by hand or drifted from the config. Exits non-zero if any drifted.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadCommandConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			p, err := newProviders(ctx, &cfg)
+			if err != nil {
+				return err
+			}
+			defer p.close()
+			return crontabDiff(ctx, cfg, p, args[0], cmd.OutOrStdout())
+		},
+	}
+
+	return cmd
+}
+
+// crontabDiff prints, for each cronjob service in env, whether its live
+// crontab entry matches the config, with a diff for those that don't.
+func crontabDiff(ctx context.Context, cfg config, p providers, env string, w io.Writer) error {
+	services := filterServicesByType(cfg, servicesWithEnv(cfg, env), "cronjob")
+	if len(services) == 0 {
+		return fmt.Errorf("no cronjob services have environment %q", env)
+	}
+	cd, ok := p.history["cronjob"].(crontabDiffer)
+	if !ok {
+		return fmt.Errorf("history provider for cronjob services can't read crontabs")
+	}
+
+	drifts, err := cd.crontabDiff(ctx, services, env)
+	if err != nil {
+		return err
+	}
+
+	drifted := 0
+	for _, d := range drifts {
+		switch {
+		case d.Live == nil:
+			fmt.Fprintf(w, "%s: not deployed\n", d.Service)
+		case !d.drifted():
+			fmt.Fprintf(w, "%s: in sync (%s)\n", d.Service, d.Tag)
+		default:
+			drifted++
+			fmt.Fprintf(w, "%s: DRIFT (%s)\n", d.Service, d.Tag)
+			for _, line := range d.Live {
+				fmt.Fprintf(w, "  - %s\n", line)
+			}
+			for _, line := range strings.Split(d.Want, "\n") {
+				fmt.Fprintf(w, "  + %s\n", line)
+			}
+		}
+	}
+	if drifted > 0 {
+		return fmt.Errorf("%d cronjob(s) in %s drifted from the config", drifted, env)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCrontabDiff(t *testing.T) {
+	cfg := cronjobTestConfig()
+	cleanup := cfg.Services["report"]
+	cleanup.Schedule = "30 1 * * *"
+	cfg.Services["cleanup"] = cleanup
+
+	tag := "main-abc1234-20250101000000"
+	reportLine := buildCronLine(cfg.Project, cfg.Region, "report", "prod", tag, cfg.Services["report"], cfg.Services["report"].Env["prod"])
+	crontab := "# hoist:begin report-prod\n# hoist:tag=" + tag + "\n# hoist:previous=\n" + reportLine + "\n# hoist:end report-prod\n" +
+		"# hoist:begin cleanup-prod\n# hoist:tag=" + tag + "\n# hoist:previous=\n0 3 * * * docker run edited-by-hand\n# hoist:end cleanup-prod\n"
+
+	reads := 0
+	p := providers{history: map[string]historyProvider{
+		"cronjob": &cronjobHistoryProvider{cfg: cfg, run: func(_ context.Context, addr, cmd string) (string, error) {
+			if !strings.HasPrefix(cmd, "crontab -l") {
+				return "", fmt.Errorf("unexpected command: %s", cmd)
+			}
+			reads++
+			return crontab, nil
+		}},
+	}}
+
+	var buf bytes.Buffer
+	err := crontabDiff(context.Background(), cfg, p, "prod", &buf)
+	if err == nil || !strings.Contains(err.Error(), "1 cronjob(s) in prod drifted") {
+		t.Fatalf("expected drift error, got: %v", err)
+	}
+	if reads != 1 {
+		t.Errorf("expected one crontab read for the shared node, got %d", reads)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"cleanup: DRIFT (" + tag + ")",
+		"  - 0 3 * * * docker run edited-by-hand",
+		"  + 30 1 * * * docker rm -f cleanup-prod",
+		"report: in sync (" + tag + ")",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCrontabDiffTimezone(t *testing.T) {
+	cfg := cronjobTestConfig()
+	report := cfg.Services["report"]
+	report.Timezone = "America/New_York"
+	cfg.Services["report"] = report
+
+	tag := "main-abc1234-20250101000000"
+	want := buildCronLine(cfg.Project, cfg.Region, "report", "prod", tag, report, report.Env["prod"])
+	for _, tc := range []struct {
+		lines string
+		want  []string
+	}{
+		{want, []string{"report: in sync (" + tag + ")"}},
+		{want + "\n# hoist:reset-tz\nCRON_TZ=Etc/UTC", []string{"report: in sync (" + tag + ")"}},
+		{strings.TrimPrefix(want, "CRON_TZ=America/New_York\n"), []string{
+			"report: DRIFT (" + tag + ")",
+			"  + CRON_TZ=America/New_York\n  + 0 0 * * * docker rm -f report-prod",
+		}},
+	} {
+		crontab := "# hoist:begin report-prod\n# hoist:tag=" + tag + "\n# hoist:previous=\n" + tc.lines + "\n# hoist:end report-prod\n"
+		p := providers{history: map[string]historyProvider{
+			"cronjob": &cronjobHistoryProvider{cfg: cfg, run: func(context.Context, string, string) (string, error) {
+				return crontab, nil
+			}},
+		}}
+
+		var buf bytes.Buffer
+		crontabDiff(context.Background(), cfg, p, "prod", &buf)
+		for _, w := range tc.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("output missing %q:\n%s", w, buf.String())
+			}
+		}
+	}
+}
+
+func TestCrontabDiffNotDeployed(t *testing.T) {
+	cfg := cronjobTestConfig()
+	p := providers{history: map[string]historyProvider{
+		"cronjob": &cronjobHistoryProvider{cfg: cfg, run: func(_ context.Context, addr, cmd string) (string, error) {
+			return "", nil
+		}},
+	}}
+
+	var buf bytes.Buffer
+	if err := crontabDiff(context.Background(), cfg, p, "prod", &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "report: not deployed") {
+		t.Errorf("expected not deployed, got: %s", buf.String())
+	}
+
+	if err := crontabDiff(context.Background(), cfg, p, "staging", &buf); err == nil || !strings.Contains(err.Error(), `no cronjob services have environment "staging"`) {
+		t.Errorf("expected no services error, got: %v", err)
+	}
+}
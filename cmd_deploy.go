@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
@@ -13,48 +14,149 @@ import (
 
 func addDeployToRoot(cmd *cobra.Command) {
 	var (
-		services []string
-		env      string
-		build    string
-		yes      bool
-		cfgPath  string
+		services   []string
+		group      string
+		exclude    []string
+		svcType    string
+		env        string
+		build      string
+		yes        bool
+		resultFile string
+		bestEffort bool
+		pick       bool
+		onFailure  string
+		showCmds   bool
+		parallel   int
+		previous   map[string]string
+		dryRun     bool
+		format     string
+		timestamps bool
+		downgrade  bool
+		retries    int
+		waitHooks  bool
+		allEnvs    bool
+		parEnvs    bool
+		cont       bool
 	)
 
 	cmd.Flags().StringSliceVarP(&services, "service", "s", nil, "services to deploy (comma-separated)")
+	cmd.Flags().StringVar(&group, "group", "", "deploy the services of this group from the config")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", nil, "services to leave out of the deploy (comma-separated)")
+	cmd.Flags().StringVar(&svcType, "type", "", "only deploy services of this type (server, static, cronjob)")
 	cmd.Flags().StringVarP(&env, "env", "e", "", "target environment")
+	cmd.Flags().BoolVar(&allEnvs, "all-envs", false, "deploy the build to every environment the services share, one at a time (needs -s and -b)")
+	cmd.Flags().BoolVar(&parEnvs, "parallel-envs", false, "with --all-envs, deploy to every environment at once (needs --rollback auto or never)")
+	cmd.Flags().BoolVar(&cont, "continue", false, "with --all-envs, go on to the next environment when one fails")
 	cmd.Flags().StringVarP(&build, "build", "b", "", "build tag or branch name")
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
-	cmd.Flags().StringVarP(&cfgPath, "config", "c", "hoist.yml", "config file path")
+	cmd.Flags().BoolVar(&downgrade, "allow-downgrade", false, "deploy a build older than the live one without asking to confirm the downgrade")
+	cmd.Flags().BoolVar(&pick, "pick", false, "always show the build picker, even when only one build exists")
+	cmd.Flags().BoolVar(&timestamps, "timestamps", false, "start each log line with the time since the deploy began")
+	cmd.Flags().BoolVar(&showCmds, "show-commands", false, "show the full docker run command and crontab line for each service before deploying")
+	cmd.Flags().StringVar(&resultFile, "result-file", "", "write the deploy result as JSON to this path (with --all-envs, one file per environment, e.g. result.staging.json)")
+	cmd.Flags().BoolVar(&waitHooks, "wait-hooks", false, "wait for post_deploy hooks to finish however long they take, instead of giving up once every attempt should have timed out")
+	cmd.Flags().IntVar(&retries, "retries", 0, "retry a service this many times when it fails to connect or pull (default from the config's retries)")
+	cmd.Flags().IntVar(&parallel, "parallel", 0, "max services to deploy at once (0 = all; 1 deploys one at a time with full output); --max-parallel also works")
+	cmd.Flags().StringToStringVar(&previous, "previous", nil, "record this as the previous tag for a service instead of what history reports (service=tag, repeatable)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would be deployed without changing anything")
+	cmd.Flags().StringVar(&format, "format", "", "dry-run output format: markdown prints the plan as a table for PRs")
+	cmd.Flags().StringVar(&onFailure, "rollback", rollbackPolicyPrompt, "what to do when the deploy or its smoke test fails: prompt, auto, or never")
+	cmd.Flags().BoolVar(&bestEffort, "invalidate-best-effort", false, "warn instead of failing when CloudFront invalidation fails")
+	cmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{"max-parallel": "parallel"}))
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		cfg, err := loadConfig(cfgPath)
+		if err := checkRollbackPolicy(onFailure); err != nil {
+			return err
+		}
+		if parallel < 0 {
+			return fmt.Errorf("--parallel must be 0 or more")
+		}
+		if format != "" && format != planFormatMarkdown {
+			return fmt.Errorf("unknown --format %q (want markdown)", format)
+		}
+		if format != "" && !dryRun {
+			return fmt.Errorf("--format requires --dry-run")
+		}
+
+		cfg, err := loadCommandConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("retries") {
+			if retries < 0 {
+				return fmt.Errorf("--retries must be 0 or more")
+			}
+			cfg.Retries = retries
+		}
+		if waitHooks {
+			cfg.Hooks.Wait = true
+		}
+		services, err := expandGroup(cfg, services, group)
 		if err != nil {
 			return err
 		}
 
+		if env == "" && !allEnvs && len(cfg.BranchEnvMap) > 0 {
+			branch, _, err := resolveGitInfo()
+			if err != nil {
+				return err
+			}
+			if mapped, ok := cfg.BranchEnvMap[branch]; ok {
+				fmt.Fprintf(cmd.OutOrStdout(), "Using environment %s for branch %s\n", mapped, branch)
+				env = mapped
+			}
+		}
+
 		ctx := cmd.Context()
-		p, err := newProviders(ctx, cfg)
+		p, err := newProviders(ctx, &cfg)
 		if err != nil {
 			return err
 		}
+		defer p.close()
+		if sd, ok := p.deployers["static"].(*staticDeployer); ok {
+			sd.invalidateBestEffort = bestEffort
+		}
 
 		opts := deployOpts{
-			Services: services,
-			Env:      env,
-			Build:    build,
-			Yes:      yes,
+			Services:     services,
+			Exclude:      exclude,
+			Type:         svcType,
+			Env:          env,
+			Build:        build,
+			Yes:          yes,
+			Pick:         pick,
+			Downgrade:    downgrade,
+			AllEnvs:      allEnvs,
+			ParallelEnvs: parEnvs,
+			Continue:     cont,
+			ResultFile:   resultFile,
+			OnFailure:    onFailure,
+			ShowCmds:     showCmds,
+			Parallel:     parallel,
+			DryRun:       dryRun,
+			Format:       format,
+			Timestamps:   timestamps,
+			Previous:     previous,
 		}
 
 		return runDeploy(ctx, cfg, p, opts)
 	}
 }
 
-func newProviders(ctx context.Context, cfg config) (providers, error) {
+// newProviders builds the AWS- and SSH-backed providers. It fills in
+// cfgp.Region from the AWS config when unset, so commands rendered from the
+// caller's config (--show-commands, --dry-run) name the region the deployers
+// use.
+func newProviders(ctx context.Context, cfgp *config) (providers, error) {
 	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
 	if err != nil {
 		return providers{}, fmt.Errorf("loading AWS config: %w", err)
 	}
-	s3Client := s3.NewFromConfig(awsCfg)
+	if cfgp.Region == "" {
+		cfgp.Region = awsCfg.Region
+	}
+	cfg := *cfgp
+	s3Client := newS3Client(awsCfg, cfg)
 	ecrClient := ecr.NewFromConfig(awsCfg)
 	cfClient := cloudfront.NewFromConfig(awsCfg)
 
@@ -62,7 +164,11 @@ func newProviders(ctx context.Context, cfg config) (providers, error) {
 	for name, svc := range cfg.Services {
 		switch svc.Type {
 		case "server", "cronjob":
-			builds[name] = &serverBuildsProvider{ecr: ecrClient, repoName: parseECRRepo(svc.Image)}
+			bp := &serverBuildsProvider{ecr: ecrClient, repoName: parseECRRepo(svc.Image)}
+			if svc.BuildLabels {
+				bp.labels = ecrClient
+			}
+			builds[name] = bp
 		case "static":
 			for _, ec := range svc.Env {
 				builds[name] = &staticBuildsProvider{s3: s3Client, bucket: ec.Bucket}
@@ -71,24 +177,44 @@ func newProviders(ctx context.Context, cfg config) (providers, error) {
 		}
 	}
 
-	dial := func(addr string) (sshRunner, error) { return sshDial(addr) }
+	var registry registryAuth
+	if cfg.Registry != nil {
+		registry = &ecrRegistryAuth{ecr: ecrClient}
+	}
+
+	dial := dialNode
+	// History lookups fan out across services and environments; share one
+	// connection per node for them.
+	pool := newSSHPool(dial)
 
 	return providers{
 		builds: builds,
 		deployers: map[string]deployer{
-			"server":  &serverDeployer{cfg: cfg, dial: dial},
+			"server":  &serverDeployer{cfg: cfg, dial: dial, registry: registry},
 			"static":  &staticDeployer{cfg: cfg, s3: s3Client, cloudfront: cfClient},
-			"cronjob": &cronjobDeployer{cfg: cfg, dial: dial},
+			"cronjob": &cronjobDeployer{cfg: cfg, dial: dial, registry: registry},
 		},
 		history: map[string]historyProvider{
-			"server":  &serverHistoryProvider{cfg: cfg, run: sshRun},
+			"server":  &serverHistoryProvider{cfg: cfg, run: pool.run},
 			"static":  &staticHistoryProvider{cfg: cfg, s3: s3Client},
-			"cronjob": &cronjobHistoryProvider{cfg: cfg, run: sshRun},
+			"cronjob": &cronjobHistoryProvider{cfg: cfg, run: pool.run},
 		},
 		logs: map[string]logsProvider{
 			"server":  &serverLogsProvider{cfg: cfg, dial: dial},
 			"static":  &staticLogsProvider{},
 			"cronjob": &cronjobLogsProvider{cfg: cfg, dial: dial},
 		},
+		pool: pool,
 	}, nil
 }
+
+// newS3Client creates an S3 client, pointed at cfg.S3Endpoint with path-style
+// addressing when a custom endpoint (MinIO, localstack) is configured.
+func newS3Client(awsCfg aws.Config, cfg config) *s3.Client {
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+}
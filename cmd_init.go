@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// initOpts are the values filled into the scaffolded config.
+type initOpts struct {
+	Project string
+	Node    string // node address, e.g. ubuntu@10.0.0.1
+	Env     string
+}
+
+var initTemplate = template.Must(template.New("hoist.yml").Parse(`project: {{.Project}}
+
+nodes:
+  node1: {{.Node}}
+
+# hooks:
+#   post_deploy: https://hooks.example.com/deploy
+#   smoke_test: ./scripts/smoke-test.sh
+
+services:
+  # A long-running container behind Traefik, deployed blue-green.
+  api:
+    type: server
+    image: 123456789012.dkr.ecr.us-east-1.amazonaws.com/{{.Project}}-api
+    port: 8080
+    healthcheck: /health
+    env:
+      {{.Env}}:
+        node: node1
+        host: api.example.com
+        envfile: /etc/{{.Project}}/api.env
+
+  # A static site served from S3 behind CloudFront.
+  web:
+    type: static
+    env:
+      {{.Env}}:
+        bucket: {{.Project}}-web-{{.Env}}
+        cloudfront: E0000000000000
+
+  # A container run on a schedule from the node's crontab.
+  report:
+    type: cronjob
+    image: 123456789012.dkr.ecr.us-east-1.amazonaws.com/{{.Project}}-report
+    schedule: "0 3 * * *"
+    command: /app/report
+    env:
+      {{.Env}}:
+        node: node1
+        envfile: /etc/{{.Project}}/report.env
+`))
+
+func newInitCmd() *cobra.Command {
+	var (
+		opts initOpts
+	)
+
+	cmd := &cobra.Command{
+		Use:           "init",
+		Short:         "Scaffold a hoist.yml with one service of each type",
+		Long:          "Scaffold a hoist.yml with one example server, static and cronjob service. Values not given as flags are prompted for; press enter to accept the default.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath, _ := cmd.Flags().GetString("config")
+			if _, err := os.Stat(cfgPath); err == nil {
+				return fmt.Errorf("%s already exists", cfgPath)
+			}
+
+			in := bufio.NewReader(cmd.InOrStdin())
+			out := cmd.OutOrStdout()
+			if opts.Project == "" {
+				opts.Project = askString(in, out, "Project name", defaultProjectName())
+			}
+			if opts.Node == "" {
+				opts.Node = askString(in, out, "Node address", "ubuntu@10.0.0.1")
+			}
+			if opts.Env == "" {
+				opts.Env = askString(in, out, "Environment", "production")
+			}
+
+			data, err := renderInitConfig(opts)
+			if err != nil {
+				return err
+			}
+			if err := writeNewFile(cfgPath, data); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Wrote %s. Replace the example images, hosts, buckets and distribution IDs before deploying.\n", cfgPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Project, "project", "", "project name (default: current directory name)")
+	cmd.Flags().StringVar(&opts.Node, "node", "", "address of the node to deploy to")
+	cmd.Flags().StringVarP(&opts.Env, "env", "e", "", "environment name")
+	return cmd
+}
+
+// renderInitConfig fills in the config template and checks that the result
+// loads, so init never writes a config hoist itself would reject.
+func renderInitConfig(opts initOpts) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := initTemplate.Execute(&buf, opts); err != nil {
+		return nil, fmt.Errorf("rendering config: %w", err)
+	}
+
+	var cfg config
+	if err := yaml.Unmarshal(buf.Bytes(), &cfg); err != nil {
+		return nil, fmt.Errorf("generated config does not parse: %w", err)
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("generated config is invalid: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeNewFile writes data to path, failing if the file already exists.
+func writeNewFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists", path)
+		}
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// askString prompts on w with a default and reads one line from r. An empty
+// answer or end of input returns def.
+func askString(r *bufio.Reader, w io.Writer, question, def string) string {
+	fmt.Fprintf(w, "%s [%s]: ", question, def)
+	line, _ := r.ReadString('\n')
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+func defaultProjectName() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "myapp"
+	}
+	return sanitizeBranch(strings.ToLower(filepath.Base(wd)), 0)
+}
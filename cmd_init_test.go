@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitWritesValidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hoist.yml")
+
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetIn(strings.NewReader(""))
+	cmd.SetArgs([]string{"init", "-c", path, "--project", "shop", "--node", "deploy@10.1.2.3", "-e", "staging"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("generated config does not load: %v", err)
+	}
+	if cfg.Project != "shop" {
+		t.Errorf("project = %q, want shop", cfg.Project)
+	}
+	if cfg.Nodes["node1"] != "deploy@10.1.2.3" {
+		t.Errorf("nodes = %v", cfg.Nodes)
+	}
+	types := map[string]bool{}
+	for _, svc := range cfg.Services {
+		types[svc.Type] = true
+		if _, ok := svc.Env["staging"]; !ok {
+			t.Errorf("service of type %s has no staging env", svc.Type)
+		}
+	}
+	if !types["server"] || !types["static"] || !types["cronjob"] {
+		t.Errorf("expected one service of each type, got %v", types)
+	}
+}
+
+func TestInitPromptsForMissingValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hoist.yml")
+
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&out)
+	// Project is given, node is answered, env takes the default.
+	cmd.SetIn(strings.NewReader("root@192.168.0.10\n\n"))
+	cmd.SetArgs([]string{"init", "-c", path, "--project", "shop"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Contains(out.String(), "Project name") {
+		t.Error("should not prompt for a value given as a flag")
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("generated config does not load: %v", err)
+	}
+	if cfg.Nodes["node1"] != "root@192.168.0.10" {
+		t.Errorf("node = %q, want answered value", cfg.Nodes["node1"])
+	}
+	if _, ok := cfg.Services["api"].Env["production"]; !ok {
+		t.Errorf("expected default production env, got %v", cfg.Services["api"].Env)
+	}
+}
+
+func TestInitRefusesToOverwrite(t *testing.T) {
+	path := writeTemp(t, "project: existing\n")
+
+	cmd := newRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader(""))
+	cmd.SetArgs([]string{"init", "-c", path, "--project", "shop", "--node", "10.0.0.1", "-e", "production"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected already exists error, got: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "project: existing\n" {
+		t.Errorf("existing file was modified: %q", data)
+	}
+}
+
+func TestRenderInitConfigRejectsInvalidValues(t *testing.T) {
+	if _, err := renderInitConfig(initOpts{Project: "", Node: "10.0.0.1", Env: "production"}); err == nil {
+		t.Error("expected error for empty project")
+	}
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -13,10 +17,18 @@ import (
 func newLogsCmd() *cobra.Command {
 	var (
 		services []string
+		group    string
+		exclude  []string
+		svcType  string
 		env      string
 		n        int
 		since    string
-		cfgPath  string
+		follow   bool
+		forDur   time.Duration
+		previous bool
+		sinceDep bool
+		grep     string
+		grepV    string
 	)
 
 	cmd := &cobra.Command{
@@ -25,23 +37,33 @@ func newLogsCmd() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := loadConfig(cfgPath)
+			cfg, err := loadCommandConfig(cmd)
 			if err != nil {
 				return err
 			}
 
 			ctx := cmd.Context()
-			p, err := newProviders(ctx, cfg)
+			p, err := newProviders(ctx, &cfg)
 			if err != nil {
 				return err
 			}
+			defer p.close()
 
-			// Default to server services (static and cronjob services have no persistent process to tail)
+			services, err := expandGroup(cfg, services, group)
+			if err != nil {
+				return err
+			}
+			if err := checkServiceTypes(cfg, services, svcType); err != nil {
+				return err
+			}
+
+			// Default to server services (static and cronjob services have no
+			// persistent process to tail), unless --type cronjob asks for them.
 			targets := services
 			if len(targets) == 0 {
-				for _, name := range sortedServiceNames(cfg) {
+				for _, name := range filterServicesByType(cfg, sortedServiceNames(cfg), svcType) {
 					t := cfg.Services[name].Type
-					if t != "static" && t != "cronjob" {
+					if t == "server" || (t == "cronjob" && svcType == "cronjob") {
 						targets = append(targets, name)
 					}
 				}
@@ -50,6 +72,14 @@ func newLogsCmd() *cobra.Command {
 				}
 			}
 
+			if err := checkServicesExist(cfg, exclude); err != nil {
+				return fmt.Errorf("--exclude: %w", err)
+			}
+			targets = excludeServices(targets, exclude)
+			if len(targets) == 0 {
+				return fmt.Errorf("every selected service is excluded")
+			}
+
 			// Validate services exist
 			for _, svc := range targets {
 				if _, ok := cfg.Services[svc]; !ok {
@@ -82,49 +112,172 @@ func newLogsCmd() *cobra.Command {
 				}
 			}
 
-			// Run log tailing concurrently for all services
-			padLen := maxServiceNameLen(targets)
-			var wg sync.WaitGroup
-			errs := make(chan error, len(targets))
-			for _, svc := range targets {
-				wg.Add(1)
-				go func(svc string) {
-					defer wg.Done()
-					svcCfg := cfg.Services[svc]
-					lp := p.logs[svcCfg.Type]
-					w := os.Stdout
-					var pw *linePrefixWriter
-					if len(targets) > 1 {
-						prefix := fmt.Sprintf("[%-*s]", padLen, svc)
-						pw = newLinePrefixWriter(w, prefix)
-					}
-					var dest io.Writer = w
-					if pw != nil {
-						dest = pw
-					}
-					if err := lp.tail(ctx, svc, env, n, since, dest); err != nil {
-						errs <- fmt.Errorf("tailing logs for %s: %w", svc, err)
-					}
-					if pw != nil {
-						pw.Flush()
-					}
-				}(svc)
+			if sinceDep && (since != "" || previous) {
+				return fmt.Errorf("--since-deploy cannot be combined with --since or --previous")
 			}
-			wg.Wait()
-			close(errs)
 
-			for err := range errs {
-				return err
+			opts := logsOpts{N: n, Since: since, Follow: follow, For: forDur}
+			if grep != "" {
+				if opts.Grep, err = regexp.Compile(grep); err != nil {
+					return fmt.Errorf("--grep: %w", err)
+				}
+			}
+			if grepV != "" {
+				if opts.GrepV, err = regexp.Compile(grepV); err != nil {
+					return fmt.Errorf("--grep-v: %w", err)
+				}
+			}
+			if sinceDep {
+				// The live container was started by the deploy, so its
+				// whole log is the window; show all of it unless -n is set.
+				if err := requireLiveDeploys(ctx, cfg, p, targets, env); err != nil {
+					return err
+				}
+				if !cmd.Flags().Changed("tail") {
+					opts.N = 0
+				}
+			}
+			if previous {
+				opts.Tags, err = previousTags(ctx, cfg, p, targets, env)
+				if err != nil {
+					return err
+				}
 			}
-			return nil
+
+			return tailServices(ctx, cfg, p, targets, env, opts, os.Stdout)
 		},
 	}
 
 	cmd.Flags().StringSliceVarP(&services, "service", "s", nil, "services to show logs for (comma-separated)")
+	cmd.Flags().StringVar(&group, "group", "", "show logs for the services of this group from the config")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", nil, "services to leave out (comma-separated)")
+	cmd.Flags().StringVar(&svcType, "type", "", "only show logs for services of this type (server, static, cronjob)")
 	cmd.Flags().StringVarP(&env, "env", "e", "", "target environment")
-	cmd.Flags().IntVarP(&n, "tail", "n", 0, "number of lines to tail")
+	cmd.Flags().IntVarP(&n, "tail", "n", 100, "number of recent lines to show (0 for all)")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "keep streaming new log lines")
 	cmd.Flags().StringVar(&since, "since", "", "show logs since duration (e.g. 1h)")
-	cmd.Flags().StringVarP(&cfgPath, "config", "c", "hoist.yml", "config file path")
+	cmd.Flags().BoolVar(&sinceDep, "since-deploy", false, "show logs since the live deploy started; --since-last-deploy also works")
+	cmd.Flags().DurationVar(&forDur, "for", 0, "stop tailing after this duration (e.g. 30s)")
+	cmd.Flags().BoolVar(&previous, "previous", false, "show logs of the previously deployed container instead of the live one")
+	cmd.Flags().StringVar(&grep, "grep", "", "only show lines matching this regular expression")
+	cmd.Flags().StringVar(&grepV, "grep-v", "", "hide lines matching this regular expression (applied after --grep)")
+	cmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{"since-last-deploy": "since-deploy"}))
 
 	return cmd
 }
+
+type logsOpts struct {
+	N      int // number of recent lines; 0 means all
+	Since  string
+	Follow bool
+	For    time.Duration     // stop tailing after this long; 0 means until cancelled
+	Tags   map[string]string // per-service tag to read instead of the live container
+	Grep   *regexp.Regexp    // only lines matching this are shown; nil means all
+	GrepV  *regexp.Regexp    // lines matching this are hidden; nil means none
+}
+
+// requireLiveDeploys checks that every target has a live deploy in env, for
+// --since-deploy. Logs are read from containers started by that deploy (or,
+// for a cronjob, by a run after it), so no --since is needed to cut them.
+func requireLiveDeploys(ctx context.Context, cfg config, p providers, targets []string, env string) error {
+	for _, svc := range targets {
+		svcType := cfg.Services[svc].Type
+		hp, ok := p.history[svcType]
+		if !ok {
+			return fmt.Errorf("no history provider for service type %q", svcType)
+		}
+		cur, err := hp.current(ctx, svc, env)
+		if err != nil {
+			return fmt.Errorf("getting current deploy for %s: %w", svc, err)
+		}
+		if cur.Tag == "" {
+			return fmt.Errorf("no live deploy for %s in %s", svc, env)
+		}
+	}
+	return nil
+}
+
+// previousTags resolves the previous deploy tag of each target, for reading
+// logs of a replaced container.
+func previousTags(ctx context.Context, cfg config, p providers, targets []string, env string) (map[string]string, error) {
+	tags := make(map[string]string, len(targets))
+	for _, svc := range targets {
+		svcType := cfg.Services[svc].Type
+		if _, ok := p.logs[svcType].(tagLogsProvider); !ok {
+			return nil, fmt.Errorf("--previous is not supported for %s services (%s)", svcType, svc)
+		}
+		hp, ok := p.history[svcType]
+		if !ok {
+			return nil, fmt.Errorf("no history provider for service type %q", svcType)
+		}
+		prev, err := hp.previous(ctx, svc, env)
+		if err != nil {
+			return nil, fmt.Errorf("getting previous deploy for %s: %w", svc, err)
+		}
+		if prev.Tag == "" {
+			return nil, fmt.Errorf("no previous deploy for %s in %s", svc, env)
+		}
+		tags[svc] = prev.Tag
+	}
+	return tags, nil
+}
+
+// tailServices tails logs for all targets concurrently, prefixing lines with the
+// service name when there is more than one. If opts.For is set, tailing stops
+// after that long and the timeout is not treated as an error.
+func tailServices(ctx context.Context, cfg config, p providers, targets []string, env string, opts logsOpts, w io.Writer) error {
+	if opts.For > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.For)
+		defer cancel()
+	}
+
+	padLen := maxServiceNameLen(targets)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(targets))
+	for _, svc := range targets {
+		wg.Add(1)
+		go func(svc string) {
+			defer wg.Done()
+			svcCfg := cfg.Services[svc]
+			lp := p.logs[svcCfg.Type]
+			var pw *linePrefixWriter
+			if len(targets) > 1 {
+				prefix := fmt.Sprintf("[%-*s]", padLen, svc)
+				pw = newLinePrefixWriter(w, prefix)
+			}
+			var dest io.Writer = w
+			if pw != nil {
+				dest = pw
+			}
+			// Filter before prefixing so patterns see the raw log line.
+			var fw *lineFilterWriter
+			if opts.Grep != nil || opts.GrepV != nil {
+				fw = newLineFilterWriter(dest, opts.Grep, opts.GrepV)
+				dest = fw
+			}
+			var err error
+			if tag, ok := opts.Tags[svc]; ok {
+				err = lp.(tagLogsProvider).tailTag(ctx, svc, env, tag, opts.N, opts.Since, opts.Follow, dest)
+			} else {
+				err = lp.tail(ctx, svc, env, opts.N, opts.Since, opts.Follow, dest)
+			}
+			if err != nil && !(opts.For > 0 && errors.Is(err, context.DeadlineExceeded)) {
+				errs <- fmt.Errorf("tailing logs for %s: %w", svc, err)
+			}
+			if fw != nil {
+				fw.Flush()
+			}
+			if pw != nil {
+				pw.Flush()
+			}
+		}(svc)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		return err
+	}
+	return nil
+}
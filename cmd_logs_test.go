@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func testConfigYAML() string {
@@ -39,10 +47,23 @@ services:
 `
 }
 
+func TestLogsCommandSinceLastDeployAlias(t *testing.T) {
+	logs, _, err := newRootCmd().Find([]string{"logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.ParseFlags([]string{"--since-last-deploy"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if on, _ := logs.Flags().GetBool("since-deploy"); !on {
+		t.Error("--since-last-deploy should set --since-deploy")
+	}
+}
+
 func TestLogsCommandUnknownService(t *testing.T) {
 	cfgPath := writeTemp(t, testConfigYAML())
-	cmd := newLogsCmd()
-	cmd.SetArgs([]string{"-c", cfgPath, "-s", "nonexistent", "-e", "staging"})
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"logs", "-c", cfgPath, "-s", "nonexistent", "-e", "staging"})
 	err := cmd.Execute()
 	if err == nil {
 		t.Fatal("expected error")
@@ -54,8 +75,8 @@ func TestLogsCommandUnknownService(t *testing.T) {
 
 func TestLogsCommandEnvNotFound(t *testing.T) {
 	cfgPath := writeTemp(t, testConfigYAML())
-	cmd := newLogsCmd()
-	cmd.SetArgs([]string{"-c", cfgPath, "-s", "backend", "-e", "nonexistent"})
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"logs", "-c", cfgPath, "-s", "backend", "-e", "nonexistent"})
 	err := cmd.Execute()
 	if err == nil {
 		t.Fatal("expected error")
@@ -93,8 +114,8 @@ services:
         envfile: .env
 `
 	cfgPath := writeTemp(t, yaml)
-	cmd := newLogsCmd()
-	cmd.SetArgs([]string{"-c", cfgPath})
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"logs", "-c", cfgPath})
 	err := cmd.Execute()
 	if err == nil {
 		t.Fatal("expected error")
@@ -103,3 +124,117 @@ services:
 		t.Errorf("expected 'no common environments' error, got: %v", err)
 	}
 }
+
+type blockingLogsProvider struct{}
+
+func (blockingLogsProvider) tail(ctx context.Context, service, _ string, _ int, _ string, _ bool, w io.Writer) error {
+	fmt.Fprintf(w, "%s started\n", service)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTailServicesForDuration(t *testing.T) {
+	cfg := testConfig()
+	p := providers{logs: map[string]logsProvider{"server": blockingLogsProvider{}}}
+
+	var buf bytes.Buffer
+	start := time.Now()
+	err := tailServices(context.Background(), cfg, p, []string{"backend"}, "staging", logsOpts{For: 50 * time.Millisecond}, &buf)
+	if err != nil {
+		t.Fatalf("expected clean return after --for, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("tailing did not stop after --for (took %s)", elapsed)
+	}
+	if buf.String() != "backend started\n" {
+		t.Errorf("output = %q", buf.String())
+	}
+}
+
+type linesLogsProvider struct{ lines string }
+
+func (p linesLogsProvider) tail(_ context.Context, _, _ string, _ int, _ string, _ bool, w io.Writer) error {
+	_, err := io.WriteString(w, p.lines)
+	return err
+}
+
+func TestTailServicesGrep(t *testing.T) {
+	cfg := testConfig()
+	p := providers{logs: map[string]logsProvider{
+		"server":  linesLogsProvider{"GET /health 200\nGET /api 200\nGET /api 500\n"},
+		"cronjob": linesLogsProvider{"report /api done\n"},
+	}}
+
+	var buf bytes.Buffer
+	opts := logsOpts{Grep: regexp.MustCompile("api"), GrepV: regexp.MustCompile(`^GET .* 200$`)}
+	if err := tailServices(context.Background(), cfg, p, []string{"backend", "report"}, "staging", opts, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The prefix is added after filtering, so "^GET" still anchors to the
+	// log line.
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	slices.Sort(got)
+	want := []string{"[backend] GET /api 500", "[report ] report /api done"}
+	if !slices.Equal(got, want) {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestTailServicesCancelledWithoutFor(t *testing.T) {
+	cfg := testConfig()
+	p := providers{logs: map[string]logsProvider{"server": blockingLogsProvider{}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := tailServices(ctx, cfg, p, []string{"backend"}, "staging", logsOpts{}, io.Discard)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error without --for, got: %v", err)
+	}
+}
+
+func TestPreviousTags(t *testing.T) {
+	cfg := testConfig()
+	p := providers{
+		logs: map[string]logsProvider{"server": &serverLogsProvider{cfg: cfg}, "cronjob": &cronjobLogsProvider{cfg: cfg}},
+		history: map[string]historyProvider{
+			"server": &mockHistoryProvider{previousDeploys: map[string]deploy{
+				"backend:staging": {Tag: "main-old1234-20241231000000"},
+			}},
+			"cronjob": &mockHistoryProvider{},
+		},
+	}
+
+	tags, err := previousTags(context.Background(), cfg, p, []string{"backend"}, "staging")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tags["backend"] != "main-old1234-20241231000000" {
+		t.Errorf("tags = %v", tags)
+	}
+
+	if _, err := previousTags(context.Background(), cfg, p, []string{"backend"}, "production"); err == nil || !strings.Contains(err.Error(), "no previous deploy") {
+		t.Errorf("expected 'no previous deploy' error, got: %v", err)
+	}
+
+	if _, err := previousTags(context.Background(), cfg, p, []string{"report"}, "staging"); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expected 'not supported' error for cronjob, got: %v", err)
+	}
+}
+
+func TestRequireLiveDeploys(t *testing.T) {
+	cfg := testConfig()
+	p := providers{
+		history: map[string]historyProvider{
+			"server": &mockHistoryProvider{deploys: map[string]deploy{
+				"backend:staging": {Tag: "main-abc1234-20250101000000", Uptime: 3 * time.Hour},
+			}},
+		},
+	}
+
+	if err := requireLiveDeploys(context.Background(), cfg, p, []string{"backend"}, "staging"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := requireLiveDeploys(context.Background(), cfg, p, []string{"backend"}, "production"); err == nil || !strings.Contains(err.Error(), "no live deploy") {
+		t.Errorf("expected 'no live deploy' error, got: %v", err)
+	}
+}
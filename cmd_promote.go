@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// resolvePromoteTargets returns the services to promote from one environment
+// to another and the tag each is running in from. Without services, every
+// service with both environments is promoted. A service with nothing deployed
+// in from is an error: promoting must not silently leave one behind.
+func resolvePromoteTargets(ctx context.Context, cfg config, p providers, services []string, from, to string) ([]string, map[string]string, error) {
+	if from == to {
+		return nil, nil, fmt.Errorf("--from and --to are both %q", from)
+	}
+	targets := services
+	if len(targets) == 0 {
+		for _, name := range servicesWithEnv(cfg, from) {
+			if _, ok := cfg.Services[name].Env[to]; ok {
+				targets = append(targets, name)
+			}
+		}
+		if len(targets) == 0 {
+			return nil, nil, fmt.Errorf("no services have both %q and %q environments", from, to)
+		}
+	}
+	for _, name := range targets {
+		svc, ok := cfg.Services[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown service: %q", name)
+		}
+		for _, env := range []string{from, to} {
+			if _, ok := svc.Env[env]; !ok {
+				return nil, nil, fmt.Errorf("service %q has no environment %q", name, env)
+			}
+		}
+	}
+
+	tags := make(map[string]string)
+	var missing []string
+	for _, name := range targets {
+		hp, ok := p.history[cfg.Services[name].Type]
+		if !ok {
+			return nil, nil, fmt.Errorf("no history provider for %s", name)
+		}
+		cur, err := hp.current(ctx, name, from)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting current deploy for %s: %w", name, err)
+		}
+		if cur.Tag == "" {
+			missing = append(missing, name)
+			continue
+		}
+		tags[name] = cur.Tag
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("refusing to promote: %s not deployed in %s", strings.Join(missing, ", "), from)
+	}
+	return targets, tags, nil
+}
+
+// promote deploys the builds running in from to to. Like deploy, it refuses
+// to put a build older than the live one in place unless downgrade is set.
+func promote(ctx context.Context, cfg config, p providers, services []string, from, to string, yes, downgrade bool, w io.Writer) error {
+	targets, tags, err := resolvePromoteTargets(ctx, cfg, p, services, from, to)
+	if err != nil {
+		return err
+	}
+	for _, name := range targets {
+		fmt.Fprintf(w, "%s: %s (from %s)\n", name, tags[name], from)
+	}
+
+	return runDeploy(ctx, cfg, p, deployOpts{
+		Services:  targets,
+		Env:       to,
+		Tags:      tags,
+		Yes:       yes,
+		Downgrade: downgrade,
+	})
+}
+
+func newPromoteCmd() *cobra.Command {
+	var (
+		services  []string
+		from      string
+		to        string
+		yes       bool
+		downgrade bool
+	)
+
+	cmd := &cobra.Command{
+		Use:           "promote",
+		Short:         "Deploy the builds running in one environment to another",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" || to == "" {
+				return fmt.Errorf("--from and --to are required")
+			}
+
+			cfg, err := loadCommandConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			p, err := newProviders(ctx, &cfg)
+			if err != nil {
+				return err
+			}
+			defer p.close()
+
+			return promote(ctx, cfg, p, services, from, to, yes, downgrade, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "environment to take the running builds from")
+	cmd.Flags().StringVar(&to, "to", "", "environment to deploy them to")
+	cmd.Flags().StringSliceVarP(&services, "service", "s", nil, "services to promote (comma-separated)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	cmd.Flags().BoolVar(&downgrade, "allow-downgrade", false, "promote builds older than the live ones without asking to confirm the downgrade")
+
+	return cmd
+}
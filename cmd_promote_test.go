@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPromoteDeploysSourceTags(t *testing.T) {
+	cfg := testConfig()
+	p, md := testProviders(nil, map[string]deploy{
+		"backend:staging":    {Service: "backend", Env: "staging", Tag: "main-abc1234-20250102000000"},
+		"backend:production": {Service: "backend", Env: "production", Tag: "main-def5678-20250101000000"},
+		"report:staging":     {Service: "report", Env: "staging", Tag: "main-abc1234-20250102000000"},
+	})
+
+	targets, tags, err := resolvePromoteTargets(context.Background(), cfg, p, []string{"backend", "report"}, "staging", "production")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := runDeploy(context.Background(), cfg, p, deployOpts{Services: targets, Env: "production", Tags: tags, Yes: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 2 {
+		t.Fatalf("expected 2 deploys, got %+v", md.calls)
+	}
+	for _, c := range md.calls {
+		if c.env != "production" || c.tag != "main-abc1234-20250102000000" {
+			t.Errorf("expected %s promoted to production with the staging tag, got %+v", c.service, c)
+		}
+	}
+}
+
+func TestPromoteDowngrade(t *testing.T) {
+	cfg := testConfig()
+	p, md := testProviders(nil, map[string]deploy{
+		"backend:staging":    {Service: "backend", Env: "staging", Tag: "main-abc1234-20250101000000"},
+		"backend:production": {Service: "backend", Env: "production", Tag: "main-def5678-20250102000000"},
+	})
+
+	err := promote(context.Background(), cfg, p, []string{"backend"}, "staging", "production", true, false, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "--allow-downgrade") {
+		t.Fatalf("expected downgrade refusal, got: %v", err)
+	}
+	if len(md.calls) != 0 {
+		t.Fatalf("expected no deploys, got %+v", md.calls)
+	}
+
+	if err := promote(context.Background(), cfg, p, []string{"backend"}, "staging", "production", true, true, io.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 1 || md.calls[0].tag != "main-abc1234-20250101000000" {
+		t.Errorf("expected the older staging build promoted, got %+v", md.calls)
+	}
+}
+
+func TestPromoteRefusesUndeployedSource(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "main-abc1234-20250102000000"},
+	})
+
+	_, _, err := resolvePromoteTargets(context.Background(), cfg, p, nil, "staging", "production")
+	if err == nil || !strings.Contains(err.Error(), "refusing to promote: frontend, report not deployed in staging") {
+		t.Errorf("expected refusal naming the undeployed services, got: %v", err)
+	}
+}
+
+func TestPromoteSameEnv(t *testing.T) {
+	p, _ := testProviders(nil, nil)
+	_, _, err := resolvePromoteTargets(context.Background(), testConfig(), p, nil, "staging", "staging")
+	if err == nil {
+		t.Error("expected error promoting an environment to itself")
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/spf13/cobra"
+)
+
+func newPruneBuildsCmd() *cobra.Command {
+	var (
+		env   string
+		keep  int
+		yes   bool
+		apply bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune-builds <service>",
+		Short: "Delete old builds from S3 or ECR",
+		Long: `Delete old builds, keeping the newest --keep builds plus the live and previous builds.
+
+Static services prune builds/<tag>/ prefixes in the bucket of the -e environment
+and ask for confirmation. Server and cronjob services prune tags from the shared
+ECR repository, protecting the live and previous tag of every environment of
+every service using that repository; they only list what would be deleted
+unless --apply is given.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			service := args[0]
+
+			cfg, err := loadCommandConfig(cmd)
+			if err != nil {
+				return err
+			}
+			svc, ok := cfg.Services[service]
+			if !ok {
+				return fmt.Errorf("unknown service: %q", service)
+			}
+			if env != "" {
+				if _, ok := svc.Env[env]; !ok {
+					return fmt.Errorf("service %q has no environment %q", service, env)
+				}
+			}
+			if keep < 1 {
+				return fmt.Errorf("--keep must be at least 1")
+			}
+
+			ctx := cmd.Context()
+			awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+			if err != nil {
+				return fmt.Errorf("loading AWS config: %w", err)
+			}
+
+			out := cmd.OutOrStdout()
+			switch svc.Type {
+			case "static":
+				if env == "" {
+					return fmt.Errorf("--env is required for static services")
+				}
+				return pruneStaticBuilds(ctx, cfg, awsCfg, service, env, keep, yes, cmd.InOrStdin(), out)
+			case "server", "cronjob":
+				return pruneECRBuilds(ctx, cfg, awsCfg, service, keep, apply, out)
+			default:
+				return fmt.Errorf("service %q: prune-builds does not support type %s", service, svc.Type)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&env, "env", "e", "", "environment whose bucket to prune (static services)")
+	cmd.Flags().IntVar(&keep, "keep", 10, "number of newest builds to keep")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt (static services)")
+	cmd.Flags().BoolVar(&apply, "apply", false, "delete ECR images instead of listing them (server and cronjob services)")
+
+	return cmd
+}
+
+func pruneStaticBuilds(ctx context.Context, cfg config, awsCfg aws.Config, service, env string, keep int, yes bool, in io.Reader, out io.Writer) error {
+	bucket := cfg.Services[service].Env[env].Bucket
+	s3Client := newS3Client(awsCfg, cfg)
+
+	bp := &staticBuildsProvider{s3: s3Client, bucket: bucket}
+	builds, err := bp.listBuilds(ctx, math.MaxInt, 0)
+	if err != nil {
+		return fmt.Errorf("listing builds: %w", err)
+	}
+
+	protect, err := protectedTags(ctx, &staticHistoryProvider{cfg: cfg, s3: s3Client}, service, []string{env})
+	if err != nil {
+		return err
+	}
+
+	prune := planPrune(builds, keep, protect)
+	if len(prune) == 0 {
+		fmt.Fprintf(out, "Nothing to prune (%d builds in s3://%s).\n", len(builds), bucket)
+		return nil
+	}
+
+	fmt.Fprintf(out, "Pruning %d of %d builds in s3://%s:\n", len(prune), len(builds), bucket)
+	for _, b := range prune {
+		fmt.Fprintf(out, "  %s\n", b.Tag)
+	}
+	if !yes && !confirmPrompt(in, out, "Delete these builds?") {
+		return errCancelled
+	}
+
+	pruner := &staticPruner{s3: s3Client, bucket: bucket}
+	for _, b := range prune {
+		n, err := pruner.deleteBuild(ctx, b.Tag)
+		if err != nil {
+			return fmt.Errorf("pruning %s: %w", b.Tag, err)
+		}
+		fmt.Fprintf(out, "deleted %s (%d objects)\n", b.Tag, n)
+	}
+	return nil
+}
+
+func pruneECRBuilds(ctx context.Context, cfg config, awsCfg aws.Config, service string, keep int, apply bool, out io.Writer) error {
+	svc := cfg.Services[service]
+	repo := parseECRRepo(svc.Image)
+	ecrClient := ecr.NewFromConfig(awsCfg)
+
+	bp := &serverBuildsProvider{ecr: ecrClient, repoName: repo}
+	builds, err := bp.listBuilds(ctx, math.MaxInt, 0)
+	if err != nil {
+		return fmt.Errorf("listing builds: %w", err)
+	}
+
+	history := map[string]historyProvider{
+		"server":  &serverHistoryProvider{cfg: cfg, run: sshRun},
+		"cronjob": &cronjobHistoryProvider{cfg: cfg, run: sshRun},
+	}
+	protect, err := repoProtectedTags(ctx, cfg, history, repo)
+	if err != nil {
+		return err
+	}
+
+	prune := planPrune(builds, keep, protect)
+	if len(prune) == 0 {
+		fmt.Fprintf(out, "Nothing to prune (%d images in %s).\n", len(builds), repo)
+		return nil
+	}
+
+	tags := make([]string, len(prune))
+	for i, b := range prune {
+		tags[i] = b.Tag
+	}
+
+	if !apply {
+		fmt.Fprintf(out, "Would delete %d of %d images in %s:\n", len(prune), len(builds), repo)
+		for _, tag := range tags {
+			fmt.Fprintf(out, "  %s\n", tag)
+		}
+		fmt.Fprintln(out, "\nDry run; pass --apply to delete.")
+		return nil
+	}
+
+	fmt.Fprintf(out, "Deleting %d of %d images in %s:\n", len(prune), len(builds), repo)
+	for _, tag := range tags {
+		fmt.Fprintf(out, "  %s\n", tag)
+	}
+	pruner := &ecrPruner{ecr: ecrClient, repoName: repo}
+	if err := pruner.deleteTags(ctx, tags); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "deleted %d images\n", len(tags))
+	return nil
+}
+
+// planPrune returns the builds to delete from builds (newest first): everything
+// except the newest keep builds and any tag in protect.
+func planPrune(builds []build, keep int, protect map[string]bool) []build {
+	var prune []build
+	for i, b := range builds {
+		if i < keep || protect[b.Tag] {
+			continue
+		}
+		prune = append(prune, b)
+	}
+	return prune
+}
+
+// repoProtectedTags returns the current and previous tags, in every
+// environment, of every service whose image is in the ECR repository repo. A
+// repository can be shared by several services, such as a cronjob running
+// the server's image, so pruning for one must not delete another's builds.
+func repoProtectedTags(ctx context.Context, cfg config, history map[string]historyProvider, repo string) (map[string]bool, error) {
+	protect := make(map[string]bool)
+	for _, name := range sortedServiceNames(cfg) {
+		svc := cfg.Services[name]
+		hp, ok := history[svc.Type]
+		if !ok || parseECRRepo(svc.Image) != repo {
+			continue
+		}
+		envs := make([]string, 0, len(svc.Env))
+		for env := range svc.Env {
+			envs = append(envs, env)
+		}
+		sort.Strings(envs)
+		tags, err := protectedTags(ctx, hp, name, envs)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(protect, tags)
+	}
+	return protect, nil
+}
+
+// protectedTags returns the current and previous tags of service in each env.
+// These are never pruned so that the live build and its rollback target stay available.
+func protectedTags(ctx context.Context, hp historyProvider, service string, envs []string) (map[string]bool, error) {
+	protect := make(map[string]bool)
+	for _, env := range envs {
+		current, err := hp.current(ctx, service, env)
+		if err != nil {
+			return nil, fmt.Errorf("getting current deploy for %s in %s: %w", service, env, err)
+		}
+		previous, err := hp.previous(ctx, service, env)
+		if err != nil {
+			return nil, fmt.Errorf("getting previous deploy for %s in %s: %w", service, env, err)
+		}
+		for _, tag := range []string{current.Tag, previous.Tag} {
+			if tag != "" {
+				protect[tag] = true
+			}
+		}
+	}
+	return protect, nil
+}
+
+// confirmPrompt asks a yes/no question on w and reads the answer from r.
+// Anything other than y/yes is treated as no.
+func confirmPrompt(r io.Reader, w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
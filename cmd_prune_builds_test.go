@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPlanPrune(t *testing.T) {
+	builds := []build{
+		{Tag: "main-aaa1111-20250105000000"},
+		{Tag: "main-bbb2222-20250104000000"},
+		{Tag: "main-ccc3333-20250103000000"},
+		{Tag: "main-ddd4444-20250102000000"},
+		{Tag: "main-eee5555-20250101000000"},
+	}
+
+	tests := []struct {
+		name    string
+		keep    int
+		protect map[string]bool
+		want    []string
+	}{
+		{"keep newest", 2, nil, []string{"main-ccc3333-20250103000000", "main-ddd4444-20250102000000", "main-eee5555-20250101000000"}},
+		{"live is kept", 2, map[string]bool{"main-ddd4444-20250102000000": true}, []string{"main-ccc3333-20250103000000", "main-eee5555-20250101000000"}},
+		{"keep all", 5, nil, nil},
+		{"keep more than exist", 10, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, b := range planPrune(builds, tt.keep, tt.protect) {
+				got = append(got, b.Tag)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectedTags(t *testing.T) {
+	hp := &mockHistoryProvider{
+		deploys: map[string]deploy{
+			"backend:staging":    {Tag: "main-aaa1111-20250105000000"},
+			"backend:production": {Tag: "main-bbb2222-20250104000000"},
+		},
+		previousDeploys: map[string]deploy{
+			"backend:production": {Tag: "main-ccc3333-20250103000000"},
+		},
+	}
+
+	got, err := protectedTags(context.Background(), hp, "backend", []string{"production", "staging"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]bool{
+		"main-aaa1111-20250105000000": true,
+		"main-bbb2222-20250104000000": true,
+		"main-ccc3333-20250103000000": true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for tag := range want {
+		if !got[tag] {
+			t.Errorf("expected %s to be protected", tag)
+		}
+	}
+}
+
+func TestRepoProtectedTags(t *testing.T) {
+	cfg := testConfig()
+	// report runs the backend image; frontend is static and has no repository.
+	report := cfg.Services["report"]
+	report.Image = "123456.dkr.ecr.eu-west-1.amazonaws.com/myapp/backend"
+	cfg.Services["report"] = report
+	backend := cfg.Services["backend"]
+	backend.Image = "myapp/backend"
+	cfg.Services["backend"] = backend
+
+	hp := &mockHistoryProvider{
+		deploys: map[string]deploy{
+			"backend:staging": {Tag: "main-aaa1111-20250105000000"},
+			"report:staging":  {Tag: "main-ddd4444-20250102000000"},
+		},
+		previousDeploys: map[string]deploy{
+			"report:staging": {Tag: "main-eee5555-20250101000000"},
+		},
+	}
+	history := map[string]historyProvider{"server": hp, "cronjob": hp, "static": hp}
+
+	got, err := repoProtectedTags(context.Background(), cfg, history, "myapp/backend")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tag := range []string{"main-aaa1111-20250105000000", "main-ddd4444-20250102000000", "main-eee5555-20250101000000"} {
+		if !got[tag] {
+			t.Errorf("expected %s to be protected, got %v", tag, got)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("got %v, want only backend's and report's tags", got)
+	}
+}
+
+func TestConfirmPrompt(t *testing.T) {
+	for input, want := range map[string]bool{"y\n": true, "yes\n": true, "Y\n": true, "\n": false, "n\n": false, "": false} {
+		var out bytes.Buffer
+		if got := confirmPrompt(strings.NewReader(input), &out, "Delete?"); got != want {
+			t.Errorf("confirmPrompt(%q) = %v, want %v", input, got, want)
+		}
+		if out.String() != "Delete? [y/N] " {
+			t.Errorf("prompt = %q", out.String())
+		}
+	}
+}
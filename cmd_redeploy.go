@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// resolveRedeployTargets returns the services to redeploy in env and the tag
+// each is currently running. Services that aren't deployed are skipped.
+func resolveRedeployTargets(ctx context.Context, cfg config, p providers, services []string, env string, w io.Writer) ([]string, map[string]string, error) {
+	targets := services
+	if len(targets) == 0 {
+		targets = servicesWithEnv(cfg, env)
+	}
+	for _, name := range targets {
+		svc, ok := cfg.Services[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown service: %q", name)
+		}
+		if _, ok := svc.Env[env]; !ok {
+			return nil, nil, fmt.Errorf("service %q has no environment %q", name, env)
+		}
+	}
+
+	tags := make(map[string]string)
+	var redeploy []string
+	for _, name := range targets {
+		hp, ok := p.history[cfg.Services[name].Type]
+		if !ok {
+			continue
+		}
+		cur, err := hp.current(ctx, name, env)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting current deploy for %s: %w", name, err)
+		}
+		if cur.Tag == "" {
+			fmt.Fprintf(w, "skipping %s: not deployed\n", name)
+			continue
+		}
+		tags[name] = cur.Tag
+		redeploy = append(redeploy, name)
+	}
+	return redeploy, tags, nil
+}
+
+func newRedeployCmd() *cobra.Command {
+	var (
+		services []string
+		svcType  string
+		yes      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:           "redeploy <environment>",
+		Short:         "Redeploy the live build to pick up node or env file changes",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env := args[0]
+
+			cfg, err := loadCommandConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if err := checkServiceTypes(cfg, services, svcType); err != nil {
+				return err
+			}
+			targets := services
+			if len(targets) == 0 && svcType != "" {
+				targets = filterServicesByType(cfg, servicesWithEnv(cfg, env), svcType)
+				if len(targets) == 0 {
+					return fmt.Errorf("no %s services have environment %q", svcType, env)
+				}
+			}
+
+			ctx := cmd.Context()
+			p, err := newProviders(ctx, &cfg)
+			if err != nil {
+				return err
+			}
+			defer p.close()
+
+			targets, tags, err := resolveRedeployTargets(ctx, cfg, p, targets, env, cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+			if len(targets) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "Nothing to redeploy.")
+				return nil
+			}
+
+			return runDeploy(ctx, cfg, p, deployOpts{
+				Services: targets,
+				Env:      env,
+				Tags:     tags,
+				Yes:      yes,
+			})
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&services, "service", "s", nil, "services to redeploy (comma-separated)")
+	cmd.Flags().StringVar(&svcType, "type", "", "only redeploy services of this type (server, static, cronjob)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+
+	return cmd
+}
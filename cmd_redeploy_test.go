@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestResolveRedeployTargets(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "main-abc1234-20250101000000"},
+		"report:staging":  {Service: "report", Env: "staging", Tag: "main-def5678-20250102000000"},
+	})
+
+	var buf bytes.Buffer
+	targets, tags, err := resolveRedeployTargets(context.Background(), cfg, p, nil, "staging", &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(targets, ",") != "backend,report" {
+		t.Errorf("targets = %v, want [backend report]", targets)
+	}
+	if tags["backend"] != "main-abc1234-20250101000000" || tags["report"] != "main-def5678-20250102000000" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	if !strings.Contains(buf.String(), "skipping frontend: not deployed") {
+		t.Errorf("expected skip message for frontend, got: %s", buf.String())
+	}
+}
+
+func TestResolveRedeployTargetsMissingEnv(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, nil)
+
+	_, _, err := resolveRedeployTargets(context.Background(), cfg, p, []string{"backend"}, "qa", &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), `service "backend" has no environment "qa"`) {
+		t.Errorf("expected missing environment error, got: %v", err)
+	}
+}
+
+func TestRedeployKeepsLiveTag(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	p, md := testProviders(nil, map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: tag},
+	})
+
+	targets, tags, err := resolveRedeployTargets(context.Background(), cfg, p, []string{"backend"}, "staging", &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := runDeploy(context.Background(), cfg, p, deployOpts{Services: targets, Env: "staging", Tags: tags, Yes: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 1 || md.calls[0].tag != tag || md.calls[0].oldTag != tag {
+		t.Errorf("expected backend redeployed with tag %s over itself, got %+v", tag, md.calls)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 )
 
@@ -59,11 +60,67 @@ func resolveRollbackTargets(ctx context.Context, cfg config, p providers, servic
 	return rollbackResult{targets: rollbackTargets, tags: tags, skipped: skipped}, nil
 }
 
+// pickRollbackBuild lets the user choose a rollback target from recent builds.
+// It returns "" (keep the per-service previous tags) when there is nothing to
+// choose between or the user picks a previous build.
+func pickRollbackBuild(ctx context.Context, cfg config, p providers, res rollbackResult, env string) (string, error) {
+	bp := buildsForServices(cfg, p, res.targets)
+	if bp == nil {
+		return "", nil
+	}
+
+	previous := make(map[string]bool, len(res.tags))
+	for _, t := range res.tags {
+		previous[t] = true
+	}
+
+	builds, err := bp.listBuilds(ctx, 20, 0)
+	if err != nil {
+		return "", fmt.Errorf("listing builds: %w", err)
+	}
+	hasOther := false
+	for _, b := range builds {
+		if !previous[b.Tag] {
+			hasOther = true
+			break
+		}
+	}
+	if !hasOther {
+		return "", nil
+	}
+
+	fetchHistory := func(ctx context.Context) (map[string]bool, map[string]string, error) {
+		return currentTags(ctx, cfg, p, res.targets, env)
+	}
+	pm := newRollbackPickerModel(bp, env, fetchHistory, previous)
+	pm.blocked = blockedBuilds(cfg)
+	result, err := tea.NewProgram(pm).Run()
+	if err != nil {
+		return "", fmt.Errorf("build picker: %w", err)
+	}
+	bm := result.(buildPickerModel)
+	if bm.cancelled {
+		return "", errCancelled
+	}
+	if bm.historyErr != nil {
+		return "", bm.historyErr
+	}
+	if bm.cursor >= len(bm.builds) {
+		return "", fmt.Errorf("no build selected")
+	}
+	chosen := bm.builds[bm.cursor].Tag
+	if previous[chosen] {
+		return "", nil
+	}
+	return chosen, nil
+}
+
 func newRollbackCmd() *cobra.Command {
 	var (
 		services []string
+		svcType  string
+		build    string
 		yes      bool
-		cfgPath  string
 	)
 
 	cmd := &cobra.Command{
@@ -75,18 +132,30 @@ func newRollbackCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			env := args[0]
 
-			cfg, err := loadConfig(cfgPath)
+			cfg, err := loadCommandConfig(cmd)
 			if err != nil {
 				return err
 			}
 
 			ctx := cmd.Context()
-			p, err := newProviders(ctx, cfg)
+			p, err := newProviders(ctx, &cfg)
 			if err != nil {
 				return err
 			}
+			defer p.close()
+
+			if err := checkServiceTypes(cfg, services, svcType); err != nil {
+				return err
+			}
+			targets := services
+			if len(targets) == 0 && svcType != "" {
+				targets = filterServicesByType(cfg, servicesWithEnv(cfg, env), svcType)
+				if len(targets) == 0 {
+					return fmt.Errorf("no %s services have environment %q", svcType, env)
+				}
+			}
 
-			res, err := resolveRollbackTargets(ctx, cfg, p, services, env, cmd.OutOrStdout())
+			res, err := resolveRollbackTargets(ctx, cfg, p, targets, env, cmd.OutOrStdout())
 			if err != nil {
 				return err
 			}
@@ -96,18 +165,41 @@ func newRollbackCmd() *cobra.Command {
 				return nil
 			}
 
+			target := build
+			if target == "" && !yes {
+				target, err = pickRollbackBuild(ctx, cfg, p, res, env)
+				if err != nil {
+					return err
+				}
+			}
+			if target != "" {
+				bp := buildsForServices(cfg, p, res.targets)
+				if bp == nil {
+					return fmt.Errorf("no builds provider available")
+				}
+				tag, err := resolveBuildTag(ctx, bp, target, blockedBuilds(cfg), cfg.MaxBranchLength)
+				if err != nil {
+					return fmt.Errorf("resolving build: %w", err)
+				}
+				for _, name := range res.targets {
+					res.tags[name] = tag
+				}
+			}
+
 			return runDeploy(ctx, cfg, p, deployOpts{
 				Services: res.targets,
 				Env:      env,
 				Tags:     res.tags,
 				Yes:      yes,
+				Rollback: true,
 			})
 		},
 	}
 
 	cmd.Flags().StringSliceVarP(&services, "service", "s", nil, "services to rollback (comma-separated)")
+	cmd.Flags().StringVar(&svcType, "type", "", "only roll back services of this type (server, static, cronjob)")
+	cmd.Flags().StringVarP(&build, "build", "b", "", "roll back to this build tag or branch instead of the previous deploy")
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
-	cmd.Flags().StringVarP(&cfgPath, "config", "c", "hoist.yml", "config file path")
 
 	return cmd
 }
@@ -199,3 +199,22 @@ type testError struct {
 }
 
 func (e *testError) Error() string { return e.msg }
+
+func TestPickRollbackBuildOnlyPrevious(t *testing.T) {
+	cfg := testConfig()
+	prevTag := "main-abc1234-20250101000000"
+	bp := &mockBuildsProvider{builds: []build{{Tag: prevTag}}}
+	p := providers{builds: map[string]buildsProvider{"backend": bp}}
+	res := rollbackResult{
+		targets: []string{"backend"},
+		tags:    map[string]string{"backend": prevTag},
+	}
+
+	got, err := pickRollbackBuild(context.Background(), cfg, p, res, "staging")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "" {
+		t.Errorf("expected no picker when only the previous build exists, got %q", got)
+	}
+}
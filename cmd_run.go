@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// cronjobRunner is implemented by deployers that can run a job on demand.
+type cronjobRunner interface {
+	runNow(ctx context.Context, service, env, tag string, w io.Writer) error
+}
+
+func newRunCmd() *cobra.Command {
+	var (
+		env string
+	)
+
+	cmd := &cobra.Command{
+		Use:           "run <service>",
+		Short:         "Run a cronjob now instead of waiting for its schedule",
+		Long:          "Run a cronjob now with the currently deployed tag, streaming its output. The run uses the same container name as scheduled runs, so it replaces one that is still running.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadCommandConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			p, err := newProviders(ctx, &cfg)
+			if err != nil {
+				return err
+			}
+			defer p.close()
+			return runCronjob(ctx, cfg, p, args[0], env, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVarP(&env, "env", "e", "", "target environment (required if the job has more than one)")
+
+	return cmd
+}
+
+// runCronjob runs service's deployed tag in env once. env may be empty when
+// the service has a single environment.
+func runCronjob(ctx context.Context, cfg config, p providers, service, env string, w io.Writer) error {
+	svc, ok := cfg.Services[service]
+	if !ok {
+		return fmt.Errorf("unknown service: %q", service)
+	}
+	if svc.Type != "cronjob" {
+		return fmt.Errorf("service %q is %s, not a cronjob", service, svc.Type)
+	}
+
+	if env == "" {
+		envs := make([]string, 0, len(svc.Env))
+		for e := range svc.Env {
+			envs = append(envs, e)
+		}
+		sort.Strings(envs)
+		if len(envs) != 1 {
+			return fmt.Errorf("service %q has multiple environments (%s), use -e to pick one", service, strings.Join(envs, ", "))
+		}
+		env = envs[0]
+	}
+	if _, ok := svc.Env[env]; !ok {
+		return fmt.Errorf("service %q has no environment %q", service, env)
+	}
+
+	hp, ok := p.history[svc.Type]
+	if !ok {
+		return fmt.Errorf("no history provider for service type %q", svc.Type)
+	}
+	cur, err := hp.current(ctx, service, env)
+	if err != nil {
+		return fmt.Errorf("getting current deploy for %s: %w", service, err)
+	}
+	if cur.Tag == "" {
+		return fmt.Errorf("service %q has never been deployed to %s", service, env)
+	}
+
+	runner, ok := p.deployers[svc.Type].(cronjobRunner)
+	if !ok {
+		return fmt.Errorf("deployer for %q can't run jobs on demand", svc.Type)
+	}
+	fmt.Fprintf(w, "running %s %s (env=%s)\n", service, cur.Tag, env)
+	return runner.runNow(ctx, service, env, cur.Tag, w)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+func newRunOnCmd() *cobra.Command {
+	var (
+		all bool
+	)
+
+	cmd := &cobra.Command{
+		Use:           "run-on [node|environment] -- <command>",
+		Short:         "Run a shell command on one or more nodes",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dash := cmd.ArgsLenAtDash()
+			if dash < 0 {
+				return fmt.Errorf("missing command (usage: hoist run-on <node|env> -- <command>)")
+			}
+			targets, command := args[:dash], strings.Join(args[dash:], " ")
+			if command == "" {
+				return fmt.Errorf("missing command after --")
+			}
+
+			var target string
+			switch {
+			case all && len(targets) > 0:
+				return fmt.Errorf("--all cannot be combined with a node or environment")
+			case !all && len(targets) != 1:
+				return fmt.Errorf("expected exactly one node or environment (or --all)")
+			case !all:
+				target = targets[0]
+			}
+
+			cfg, err := loadCommandConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			nodes, err := resolveRunOnNodes(cfg, target, all)
+			if err != nil {
+				return err
+			}
+
+			return runOnNodes(cmd.Context(), cfg, dialNode, nodes, command, os.Stdout)
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "run on every node in the config")
+
+	return cmd
+}
+
+// resolveRunOnNodes returns the sorted node names for a run-on target. The
+// target is matched against node names first, then against environments, in
+// which case every node used by a service in that environment is returned.
+func resolveRunOnNodes(cfg config, target string, all bool) ([]string, error) {
+	if all {
+		nodes := make([]string, 0, len(cfg.Nodes))
+		for name := range cfg.Nodes {
+			nodes = append(nodes, name)
+		}
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("no nodes defined")
+		}
+		sort.Strings(nodes)
+		return nodes, nil
+	}
+
+	if _, ok := cfg.Nodes[target]; ok {
+		return []string{target}, nil
+	}
+
+	seen := map[string]bool{}
+	for _, svc := range cfg.Services {
+		ec, ok := svc.Env[target]
+		if !ok {
+			continue
+		}
+		for _, node := range ec.nodeNames() {
+			seen[node] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("%q is not a node or an environment with nodes", target)
+	}
+	nodes := make([]string, 0, len(seen))
+	for name := range seen {
+		nodes = append(nodes, name)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// runOnNodes runs command on each node concurrently, streaming output to w.
+// Output is prefixed with the node name when more than one node is targeted.
+func runOnNodes(ctx context.Context, cfg config, dial func(addr string) (sshRunner, error), nodes []string, command string, w io.Writer) error {
+	padLen := maxServiceNameLen(nodes)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []string
+	)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			var dest io.Writer = w
+			var pw *linePrefixWriter
+			if len(nodes) > 1 {
+				pw = newLinePrefixWriter(w, fmt.Sprintf("[%-*s]", padLen, node))
+				dest = pw
+			}
+
+			err := runOnNode(ctx, dial, cfg.Nodes[node], command, dest)
+			if pw != nil {
+				pw.Flush()
+			}
+			if err != nil {
+				mu.Lock()
+				failed = append(failed, node)
+				mu.Unlock()
+				fmt.Fprintf(os.Stderr, "%s: %v\n", node, err)
+			}
+		}(node)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("command failed on: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func runOnNode(ctx context.Context, dial func(addr string) (sshRunner, error), addr, command string, w io.Writer) error {
+	client, err := dial(addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer client.close()
+	return client.stream(ctx, command, w)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestResolveRunOnNodes(t *testing.T) {
+	cfg := testConfig()
+
+	tests := []struct {
+		name   string
+		target string
+		all    bool
+		want   []string
+	}{
+		{"node name", "web2", false, []string{"web2"}},
+		{"environment", "staging", false, []string{"web1"}},
+		{"all nodes", "", true, []string{"web1", "web2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRunOnNodes(cfg, tt.target, tt.all)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRunOnNodesUnknown(t *testing.T) {
+	_, err := resolveRunOnNodes(testConfig(), "nonexistent", false)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "not a node or an environment") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunOnNodesPrefixesOutput(t *testing.T) {
+	cfg := testConfig()
+	var mu sync.Mutex
+	var dialed []string
+	dial := func(addr string) (sshRunner, error) {
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		return &mockSSHRunner{responses: []mockRunResult{{output: "ok\n"}}}, nil
+	}
+
+	var buf bytes.Buffer
+	err := runOnNodes(context.Background(), cfg, dial, []string{"web1", "web2"}, "apt update", &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(dialed) != 2 {
+		t.Fatalf("expected 2 dials, got %d", len(dialed))
+	}
+	out := buf.String()
+	if !strings.Contains(out, "[web1] ok") || !strings.Contains(out, "[web2] ok") {
+		t.Errorf("expected prefixed output, got %q", out)
+	}
+}
+
+func TestRunOnNodesReportsFailures(t *testing.T) {
+	cfg := testConfig()
+	dial := func(addr string) (sshRunner, error) {
+		if addr == "10.0.0.2" {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return &mockSSHRunner{}, nil
+	}
+
+	var buf bytes.Buffer
+	err := runOnNodes(context.Background(), cfg, dial, []string{"web1", "web2"}, "docker login", &buf)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "web2") || strings.Contains(err.Error(), "web1") {
+		t.Errorf("expected only web2 in error, got: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunCronjob(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	p, _ := testProviders(nil, map[string]deploy{
+		"report:production": {Service: "report", Env: "production", Tag: tag},
+	})
+	mock := &mockSSHRunner{responses: []mockRunResult{{output: "report done\n"}}}
+	var dialed string
+	p.deployers["cronjob"] = &cronjobDeployer{cfg: cfg, dial: func(addr string) (sshRunner, error) {
+		dialed = addr
+		return mock, nil
+	}}
+
+	var buf bytes.Buffer
+	if err := runCronjob(context.Background(), cfg, p, "report", "production", &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dialed != "10.0.0.2" {
+		t.Errorf("dialed %q, want 10.0.0.2", dialed)
+	}
+	if len(mock.commands) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(mock.commands))
+	}
+	want := buildCronCommand(cfg.Project, cfg.Region, "report", "production", tag, cfg.Services["report"], cfg.Services["report"].Env["production"])
+	if mock.commands[0] != want {
+		t.Errorf("command = %q, want %q", mock.commands[0], want)
+	}
+	if strings.Contains(mock.commands[0], "0 0 * * *") {
+		t.Errorf("command should not include the schedule: %s", mock.commands[0])
+	}
+	if !strings.Contains(buf.String(), "report done") {
+		t.Errorf("expected job output, got: %s", buf.String())
+	}
+}
+
+func TestRunCronjobErrors(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, nil)
+
+	tests := []struct {
+		name    string
+		service string
+		env     string
+		wantErr string
+	}{
+		{"not a cronjob", "backend", "staging", `service "backend" is server, not a cronjob`},
+		{"unknown service", "nope", "staging", `unknown service: "nope"`},
+		{"ambiguous env", "report", "", "use -e to pick one"},
+		{"never deployed", "report", "staging", `service "report" has never been deployed to staging`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runCronjob(context.Background(), cfg, p, tt.service, tt.env, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
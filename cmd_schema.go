@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:           "schema",
+		Short:         "Print a JSON Schema for hoist.yml",
+		Long:          "Print a JSON Schema for hoist.yml, for editor validation and autocompletion (e.g. with the YAML language server).",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := json.MarshalIndent(configSchema(), "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(out))
+			return nil
+		},
+	}
+}
+
+// configSchema builds a JSON Schema for the config struct by reflecting over
+// its yaml tags, so it stays in sync as fields are added. Fields can refine
+// their schema with a `schema:"..."` tag holding comma-separated options:
+// "required" and "enum=a|b|c".
+func configSchema() map[string]any {
+	s := typeSchema(reflect.TypeOf(config{}))
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "hoist.yml"
+	return s
+}
+
+var (
+	stringListType  = reflect.TypeOf(stringList{})
+	nodesConfigType = reflect.TypeOf(nodesConfig{})
+	webhookListType = reflect.TypeOf(webhookList{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+)
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch t {
+	case stringListType:
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		}}
+	case nodesConfigType:
+		return map[string]any{"type": "object", "additionalProperties": map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			structSchema(reflect.TypeOf(nodeConfig{})),
+		}}}
+	case webhookListType:
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"oneOf": []any{
+				map[string]any{"type": "string"},
+				structSchema(reflect.TypeOf(webhookConfig{})),
+			}}},
+		}}
+	case durationType:
+		return map[string]any{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		fs := typeSchema(f.Type)
+		for _, opt := range strings.Split(f.Tag.Get("schema"), ",") {
+			switch {
+			case opt == "required":
+				required = append(required, name)
+			case strings.HasPrefix(opt, "enum="):
+				var values []any
+				for _, v := range strings.Split(strings.TrimPrefix(opt, "enum="), "|") {
+					values = append(values, v)
+				}
+				fs["enum"] = values
+			}
+		}
+		props[name] = fs
+	}
+
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
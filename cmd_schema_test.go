@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestConfigSchema(t *testing.T) {
+	s := configSchema()
+
+	props := s["properties"].(map[string]any)
+	for _, key := range []string{"project", "nodes", "services", "hooks", "branch_env_map"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("missing top-level property %q", key)
+		}
+	}
+	if req, _ := s["required"].([]string); len(req) != 2 || req[0] != "project" || req[1] != "services" {
+		t.Errorf("required = %v, want [project services]", s["required"])
+	}
+
+	svc := props["services"].(map[string]any)["additionalProperties"].(map[string]any)
+	svcProps := svc["properties"].(map[string]any)
+	typ := svcProps["type"].(map[string]any)
+	if enum, _ := typ["enum"].([]any); len(enum) != 3 {
+		t.Errorf("service type enum = %v, want 3 values", typ["enum"])
+	}
+
+	env := svcProps["env"].(map[string]any)["additionalProperties"].(map[string]any)
+	cf := env["properties"].(map[string]any)["cloudfront"].(map[string]any)
+	if _, ok := cf["oneOf"]; !ok {
+		t.Errorf("cloudfront should accept a string or a list, got %v", cf)
+	}
+	if env["additionalProperties"] != false {
+		t.Error("env config should reject unknown keys")
+	}
+}
+
+func TestSchemaCommandOutputsJSON(t *testing.T) {
+	var out bytes.Buffer
+	cmd := newSchemaCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if decoded["title"] != "hoist.yml" {
+		t.Errorf("title = %v", decoded["title"])
+	}
+}
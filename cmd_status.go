@@ -2,14 +2,22 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 func newStatusCmd() *cobra.Command {
 	var (
-		env     string
-		cfgPath string
+		env         string
+		group       string
+		exclude     []string
+		svcType     string
+		output      string
+		concurrency int
+		offline     bool
+		utc         bool
 	)
 
 	cmd := &cobra.Command{
@@ -18,27 +26,113 @@ func newStatusCmd() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := loadConfig(cfgPath)
+			cfg, err := loadCommandConfig(cmd)
 			if err != nil {
 				return err
 			}
-
-			ctx := cmd.Context()
-			p, err := newProviders(ctx, cfg)
-			if err != nil {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("invalid --output %q (must be \"table\" or \"json\")", output)
+			}
+			if err := checkServiceTypes(cfg, nil, svcType); err != nil {
 				return err
 			}
-			rows, err := getStatus(ctx, cfg, p, env)
+			services, err := expandGroup(cfg, nil, group)
 			if err != nil {
 				return err
 			}
+			if err := checkServicesExist(cfg, exclude); err != nil {
+				return fmt.Errorf("--exclude: %w", err)
+			}
+			if len(exclude) > 0 {
+				if services == nil {
+					services = sortedServiceNames(cfg)
+				}
+				services = excludeServices(services, exclude)
+				if len(services) == 0 {
+					return fmt.Errorf("every service is excluded")
+				}
+			}
+
+			var rows []statusRow
+			var stale time.Time
+			if offline {
+				rows, stale, err = statusFromCache(cfg.Project, env, svcType, services)
+				if err != nil {
+					return err
+				}
+			} else {
+				ctx := cmd.Context()
+				p, err := newProviders(ctx, &cfg)
+				if err != nil {
+					return err
+				}
+				defer p.close()
+				rows, err = getStatus(ctx, cfg, p, env, svcType, services, concurrency)
+				if err != nil {
+					cachedRows, seen, cacheErr := statusFromCache(cfg.Project, env, svcType, services)
+					if cacheErr != nil {
+						return err
+					}
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+					rows, stale = cachedRows, seen
+				} else if dir, err := statusCacheDir(); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				} else if err := saveStatusCache(dir, cfg.Project, rows, time.Now()); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: caching status: %v\n", err)
+				}
+			}
+
+			if !stale.IsZero() {
+				// Keep stdout parseable for -o json.
+				banner := os.Stdout
+				if output == "json" {
+					banner = os.Stderr
+				}
+				loc := time.Local
+				if utc {
+					loc = time.UTC
+				}
+				fmt.Fprint(banner, formatStaleBanner(stale, loc, time.Now()))
+			}
+			if output == "json" {
+				out, err := formatStatusJSON(rows)
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(out))
+				return nil
+			}
 			fmt.Print(formatStatusTable(rows))
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&env, "env", "e", "", "filter by environment")
-	cmd.Flags().StringVarP(&cfgPath, "config", "c", "hoist.yml", "config file path")
+	cmd.Flags().StringVar(&group, "group", "", "only show the services of this group from the config")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", nil, "services to leave out (comma-separated)")
+	cmd.Flags().StringVar(&svcType, "type", "", "filter by service type (server, static, cronjob)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format (table, json)")
+	cmd.Flags().IntVar(&concurrency, "concurrency", defaultStatusConcurrency, "maximum number of status queries to run at once")
+	cmd.Flags().BoolVar(&offline, "offline", false, "show the last cached status instead of querying nodes")
+	cmd.Flags().BoolVar(&utc, "utc", false, "show times in UTC instead of local time")
 
 	return cmd
 }
+
+// statusFromCache returns the cached status rows matching the filters and when
+// the oldest was seen. It fails if nothing matching was ever cached.
+func statusFromCache(project, env, svcType string, services []string) ([]statusRow, time.Time, error) {
+	dir, err := statusCacheDir()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	cached, err := loadStatusCache(dir, project)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	rows, seen := cachedStatus(cached, time.Now(), env, svcType, services)
+	if len(rows) == 0 {
+		return nil, time.Time{}, fmt.Errorf("no cached status for %s", project)
+	}
+	return rows, seen, nil
+}
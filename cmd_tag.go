@@ -11,7 +11,9 @@ import (
 )
 
 func newTagCmd() *cobra.Command {
-	var attempt int
+	var (
+		attempt int
+	)
 	cmd := &cobra.Command{
 		Use:           "tag",
 		Short:         "Generate a build tag from git state",
@@ -22,7 +24,18 @@ func newTagCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			t := generateTag(branch, sha, time.Now(), attempt)
+			// The config is optional here: CI may tag builds from a checkout
+			// without one, and then the default branch length applies.
+			maxBranchLen := 0
+			cfgPath, _ := cmd.Flags().GetString("config")
+			if _, err := os.Stat(cfgPath); err == nil {
+				cfg, err := loadCommandConfig(cmd)
+				if err != nil {
+					return err
+				}
+				maxBranchLen = cfg.MaxBranchLength
+			}
+			t := generateTag(branch, sha, time.Now(), attempt, maxBranchLen)
 			fmt.Fprintln(cmd.OutOrStdout(), t)
 			return nil
 		},
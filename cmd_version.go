@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Dirty     bool   `json:"dirty"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+func newVersionCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:           "version",
+		Short:         "Print hoist version and build metadata",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !asJSON {
+				fmt.Fprintln(cmd.OutOrStdout(), buildVersion())
+				return nil
+			}
+			info, _ := debug.ReadBuildInfo()
+			out, err := json.MarshalIndent(readVersionInfo(info), "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(out))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output build metadata as JSON")
+	return cmd
+}
+
+// readVersionInfo collects build metadata from ldflags and the embedded VCS
+// settings. info may be nil when build info is unavailable.
+func readVersionInfo(info *debug.BuildInfo) versionInfo {
+	v := versionInfo{
+		Version:   buildVersion(),
+		BuildTime: buildTime,
+	}
+	if info == nil {
+		return v
+	}
+	v.GoVersion = info.GoVersion
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Revision = s.Value
+		case "vcs.modified":
+			v.Dirty = s.Value == "true"
+		case "vcs.time":
+			if v.BuildTime == "" {
+				v.BuildTime = s.Value
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestReadVersionInfo(t *testing.T) {
+	info := &debug.BuildInfo{
+		GoVersion: "go1.24.5",
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc1234def5678"},
+			{Key: "vcs.modified", Value: "true"},
+			{Key: "vcs.time", Value: "2025-01-01T00:00:00Z"},
+		},
+	}
+
+	v := readVersionInfo(info)
+	if v.Revision != "abc1234def5678" {
+		t.Errorf("revision = %q, want full SHA", v.Revision)
+	}
+	if !v.Dirty {
+		t.Error("expected dirty=true")
+	}
+	if v.GoVersion != "go1.24.5" {
+		t.Errorf("goVersion = %q, want go1.24.5", v.GoVersion)
+	}
+	if v.BuildTime != "2025-01-01T00:00:00Z" {
+		t.Errorf("buildTime = %q, want vcs.time fallback", v.BuildTime)
+	}
+	if v.Version == "" {
+		t.Error("expected non-empty version")
+	}
+}
+
+func TestReadVersionInfoNil(t *testing.T) {
+	v := readVersionInfo(nil)
+	if v.Revision != "" || v.Dirty || v.GoVersion != "" {
+		t.Errorf("expected empty VCS fields, got %+v", v)
+	}
+}
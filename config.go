@@ -2,53 +2,295 @@ package main
 
 import (
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type config struct {
-	Project  string                   `yaml:"project"`
-	Nodes    map[string]string        `yaml:"nodes"`
-	Services map[string]serviceConfig `yaml:"services"`
-	Hooks    hooksConfig              `yaml:"hooks"`
+	Project         string                   `yaml:"project" schema:"required"`
+	Nodes           nodesConfig              `yaml:"nodes"`
+	Services        map[string]serviceConfig `yaml:"services" schema:"required"`
+	Hooks           hooksConfig              `yaml:"hooks"`
+	PrePull         string                   `yaml:"pre_pull"`          // command run on the node before docker pull (server + cronjob)
+	Region          string                   `yaml:"region"`            // AWS region for awslogs; defaults to the AWS SDK's region
+	BranchEnvMap    map[string]string        `yaml:"branch_env_map"`    // git branch -> default environment
+	Groups          map[string][]string      `yaml:"groups"`            // named service sets for --group
+	BlockedBuilds   []string                 `yaml:"blocked_builds"`    // build tags that must never be deployed
+	Registry        *registryConfig          `yaml:"registry"`          // log nodes into the image registry before pulling
+	S3Endpoint      string                   `yaml:"s3_endpoint"`       // custom S3 endpoint (MinIO, localstack); disables CloudFront
+	MaxBranchLength int                      `yaml:"max_branch_length"` // longest branch name kept in tags, 0 means 40
+	Retries         int                      `yaml:"retries"`           // default for deploy --retries: extra attempts after a dial or pull failure
+	ContainerName   string                   `yaml:"container_name"`    // server container name template with {service}, {env} and {tag}; defaults to "{service}-{env}-{tag}"
+	EnvOrder        []string                 `yaml:"env_order"`         // order environments are listed and deployed in by --all-envs; unlisted ones follow by name
+}
+
+type registryConfig struct {
+	Type string `yaml:"type" schema:"required,enum=ecr"`
 }
 
 type hooksConfig struct {
-	PostDeploy string `yaml:"post_deploy"`
+	PreDeploy        string        `yaml:"pre_deploy"`                                  // webhook called before deploying; a non-2xx response aborts the deploy
+	PostDeploy       webhookList   `yaml:"post_deploy"`                                 // webhooks notified after each deploy and rollback, fired concurrently
+	PostDeployFormat string        `yaml:"post_deploy_format" schema:"enum=json|slack"` // body format for post_deploy hooks without their own; "json" (default) or "slack"
+	SmokeTest        string        `yaml:"smoke_test"`                                  // local command run after a successful deploy; failure offers rollback
+	Secret           string        `yaml:"secret"`                                      // signs pre_deploy and post_deploy bodies (X-Hoist-Signature); ${VAR} references are expanded from the environment, $$ is a literal $
+	Timeout          time.Duration `yaml:"timeout"`                                     // per-attempt pre_deploy and post_deploy request timeout, 0 means 5s
+	Wait             bool          `yaml:"wait"`                                        // wait for post_deploy hooks, retries included, before exiting (deploy --wait-hooks)
 }
 
 type serviceConfig struct {
-	Type        string               `yaml:"type"`
-	Image       string               `yaml:"image"`
-	Port        int                  `yaml:"port"`
-	Healthcheck string               `yaml:"healthcheck"`
-	Schedule    string               `yaml:"schedule"` // cron expression (cronjob only)
-	Command     string               `yaml:"command"`  // container command override (optional, server + cronjob)
-	Env         map[string]envConfig `yaml:"env"`
+	Type                 string               `yaml:"type" schema:"required,enum=server|static|cronjob"`
+	Image                string               `yaml:"image"`
+	Port                 int                  `yaml:"port"`
+	Healthcheck          string               `yaml:"healthcheck"`
+	HealthcheckType      string               `yaml:"healthcheck_type" schema:"enum=http|tcp"` // "http" (default) curls healthcheck; "tcp" only connects to port
+	ReadinessCommand     string               `yaml:"readiness_command"`                       // run in the container with docker exec instead of the healthcheck; ready on exit 0 (server only)
+	HealthcheckSuccesses int                  `yaml:"healthcheck_successes"`                   // consecutive passes required before cutover (server only, 0 means 1)
+	HealthcheckInterval  time.Duration        `yaml:"healthcheck_interval"`                    // time between healthchecks during deploy (server only, 0 means 2s)
+	HealthcheckTimeout   time.Duration        `yaml:"healthcheck_timeout"`                     // how long to wait for the new container to pass (server only, 0 means 120s)
+	RemovalDelay         time.Duration        `yaml:"removal_delay"`                           // pause between removing old containers after cutover (server only, 0 means none)
+	Schedule             string               `yaml:"schedule"`                                // cron expression (cronjob only)
+	Concurrency          string               `yaml:"concurrency" schema:"enum=allow|forbid"`  // "allow" (default) replaces a still-running run; "forbid" skips the new one (cronjob only)
+	Timezone             string               `yaml:"timezone"`                                // IANA zone the schedule is read in, via CRON_TZ; default is the node's local time (cronjob only)
+	Memory               string               `yaml:"memory"`                                  // docker --memory limit, e.g. 512m or 2g (server + cronjob)
+	CPUs                 float64              `yaml:"cpus"`                                    // docker --cpus limit, e.g. 0.5 (server + cronjob)
+	Command              string               `yaml:"command"`                                 // container command override (optional, server + cronjob)
+	PrePull              string               `yaml:"pre_pull"`                                // overrides the top-level pre_pull for this service
+	ConflictsWith        []string             `yaml:"conflicts_with"`                          // services never deployed at the same time as this one
+	DependsOn            []string             `yaml:"depends_on"`                              // services deployed before this one when deployed together
+	BuildLabels          bool                 `yaml:"build_labels"`                            // read OCI labels from ECR images for build details (server + cronjob)
+	Env                  map[string]envConfig `yaml:"env" schema:"required"`
 }
 
 type envConfig struct {
 	// Server + cronjob fields
-	Node    string `yaml:"node"`
-	Host    string `yaml:"host"` // server only
-	EnvFile string `yaml:"envfile"`
+	Node         string     `yaml:"node"`
+	Nodes        []string   `yaml:"nodes"`         // server only: deploy to each of these nodes instead of node
+	NodeRollback bool       `yaml:"node_rollback"` // server only: if a node fails, roll back the nodes that succeeded
+	Host         string     `yaml:"host"`          // server only
+	EnvFile      stringList `yaml:"envfile"`       // one path or a list, passed to docker run in order
 	// Static fields
-	Bucket     string `yaml:"bucket"`
-	CloudFront string `yaml:"cloudfront"`
+	Bucket            string            `yaml:"bucket"`
+	CloudFront        stringList        `yaml:"cloudfront"`         // one distribution ID or a list
+	InvalidationPaths []string          `yaml:"invalidation_paths"` // paths invalidated on each deploy, default /*
+	DeployLog         bool              `yaml:"deploy_log"`         // append each deploy to deploys.log in the bucket
+	Prune             bool              `yaml:"prune"`              // delete objects in current/ the new build doesn't have
+	CacheControl      map[string]string `yaml:"cache_control"`      // glob -> Cache-Control header set on objects copied to current/
+}
+
+// nodeNames returns the nodes the environment runs on: nodes if set,
+// otherwise node.
+func (ec envConfig) nodeNames() []string {
+	if len(ec.Nodes) > 0 {
+		return ec.Nodes
+	}
+	if ec.Node == "" {
+		return nil
+	}
+	return []string{ec.Node}
+}
+
+// defaultInvalidationPath is invalidated on every static deploy unless the
+// environment lists invalidation_paths, since any file may change.
+const defaultInvalidationPath = "/*"
+
+// invalidationPaths returns the CloudFront paths a static deploy invalidates.
+func (ec envConfig) invalidationPaths() []string {
+	if len(ec.InvalidationPaths) > 0 {
+		return ec.InvalidationPaths
+	}
+	return []string{defaultInvalidationPath}
+}
+
+// webhookConfig is one post_deploy webhook.
+type webhookConfig struct {
+	URL    string `yaml:"url" schema:"required"`
+	Format string `yaml:"format" schema:"enum=json|slack"` // request body; "json" posts the deploy event, "slack" a Slack message; defaults to hooks.post_deploy_format
+}
+
+// webhookList is a list of webhooks. Each entry is a URL or a mapping with url
+// and format, and a single URL may be given without a list.
+type webhookList []webhookConfig
+
+func (l *webhookList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = nil
+		if value.Value != "" {
+			*l = webhookList{{URL: value.Value}}
+		}
+		return nil
+	}
+	var raw []yaml.Node
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	hooks := make(webhookList, 0, len(raw))
+	for _, v := range raw {
+		if v.Kind == yaml.ScalarNode {
+			hooks = append(hooks, webhookConfig{URL: v.Value})
+			continue
+		}
+		var wc webhookConfig
+		if err := v.Decode(&wc); err != nil {
+			return err
+		}
+		hooks = append(hooks, wc)
+	}
+	*l = hooks
+	return nil
+}
+
+// stringList is a list of strings that also accepts a single scalar in YAML.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = nil
+		if value.Value != "" {
+			*l = stringList{value.Value}
+		}
+		return nil
+	}
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// nodesConfig maps node names to SSH addresses. Each node is either a plain
+// address string ("ubuntu@10.0.0.1", "10.0.0.1:2222") or a mapping with host,
+// user and port, which is normalized to the same address form. Host names are
+// resolved by DNS when dialing. A mapping with exec instead names a command
+// that prints the address, stored as execNodePrefix+command and run when the
+// node is first dialed (see resolveNodeAddr).
+type nodesConfig map[string]string
+
+// nodeConfig is the structured form of a node entry.
+type nodeConfig struct {
+	Host string `yaml:"host"`
+	User string `yaml:"user"` // default root
+	Port int    `yaml:"port"` // default 22
+	Exec string `yaml:"exec"` // command printing the address, instead of host
+}
+
+func (n *nodesConfig) UnmarshalYAML(value *yaml.Node) error {
+	var raw map[string]yaml.Node
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	nodes := make(nodesConfig, len(raw))
+	for name, v := range raw {
+		if v.Kind == yaml.ScalarNode {
+			nodes[name] = v.Value
+			continue
+		}
+		var nc nodeConfig
+		if err := v.Decode(&nc); err != nil {
+			return fmt.Errorf("node %q: %w", name, err)
+		}
+		if nc.Exec != "" {
+			if nc.Host != "" || nc.User != "" || nc.Port != 0 {
+				return fmt.Errorf("node %q: exec can't be combined with host, user or port; print them from the command", name)
+			}
+			nodes[name] = execNodePrefix + nc.Exec
+			continue
+		}
+		if nc.Host == "" {
+			return fmt.Errorf("node %q: missing host or exec", name)
+		}
+		if nc.Port < 0 || nc.Port > 65535 {
+			return fmt.Errorf("node %q: invalid port %d", name, nc.Port)
+		}
+		nodes[name] = formatSSHAddr(nc.User, nc.Host, nc.Port)
+	}
+	*n = nodes
+	return nil
+}
+
+// dependencyCycle returns a depends_on cycle as the services along it, ending
+// with the one it started from, or nil if there is none. Unknown services are
+// left to validateConfig.
+func dependencyCycle(cfg config) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(cfg.Services))
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(path, name)
+			return append(slices.Clone(path[start:]), name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range cfg.Services[name].DependsOn {
+			if _, ok := cfg.Services[dep]; !ok {
+				continue
+			}
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range sortedServiceNames(cfg) {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
 }
 
 func loadConfig(path string) (config, error) {
+	return loadConfigWithOverlay(path, "")
+}
+
+// loadConfigWithOverlay loads the base config at path and, if overlay is set,
+// deep-merges the overlay file on top of it before validating. Mappings are
+// merged key by key; any other overlay value replaces the base value.
+func loadConfigWithOverlay(path, overlay string) (config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return config{}, fmt.Errorf("reading config: %w", err)
 	}
 
+	if overlay != "" {
+		data, err = mergeOverlay(data, overlay)
+		if err != nil {
+			return config{}, err
+		}
+	}
+
 	var cfg config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return config{}, fmt.Errorf("parsing config: %w", err)
 	}
 
+	if err := expandConfigEnv(&cfg); err != nil {
+		return config{}, err
+	}
+	if ep := os.Getenv("HOIST_S3_ENDPOINT"); ep != "" {
+		cfg.S3Endpoint = ep
+	}
+
 	if err := validateConfig(cfg); err != nil {
 		return config{}, err
 	}
@@ -56,6 +298,111 @@ func loadConfig(path string) (config, error) {
 	return cfg, nil
 }
 
+// expandConfigEnv expands ${VAR} and $VAR references in the fields that tend
+// to differ per team or developer: node addresses, images, each environment's
+// node, host, env files, bucket and CloudFront distributions, and the hook
+// secret. Exec nodes are left alone, as their command is for the shell.
+// $$ stands for a literal $, for values such as secrets that contain one.
+// Referencing an unset variable is an error, as is a secret that expands to
+// nothing, which would silently turn signing off.
+func expandConfigEnv(cfg *config) error {
+	missing := make(map[string]bool)
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				missing[name] = true
+			}
+			return v
+		})
+	}
+	expandList := func(list []string) {
+		for i := range list {
+			list[i] = expand(list[i])
+		}
+	}
+
+	for name, addr := range cfg.Nodes {
+		if !strings.HasPrefix(addr, execNodePrefix) {
+			cfg.Nodes[name] = expand(addr)
+		}
+	}
+	for name, svc := range cfg.Services {
+		svc.Image = expand(svc.Image)
+		for env, ec := range svc.Env {
+			ec.Node = expand(ec.Node)
+			expandList(ec.Nodes)
+			ec.Host = expand(ec.Host)
+			expandList(ec.EnvFile)
+			ec.Bucket = expand(ec.Bucket)
+			expandList(ec.CloudFront)
+			svc.Env[env] = ec
+		}
+		cfg.Services[name] = svc
+	}
+	secret := expand(cfg.Hooks.Secret)
+
+	if len(missing) > 0 {
+		names := slices.Sorted(maps.Keys(missing))
+		return fmt.Errorf("config references unset environment variables: %s", strings.Join(names, ", "))
+	}
+	if secret == "" && cfg.Hooks.Secret != "" {
+		return fmt.Errorf("hooks.secret: %q expands to an empty value", cfg.Hooks.Secret)
+	}
+	cfg.Hooks.Secret = secret
+	return nil
+}
+
+// validWebhookFormat reports whether format is a post_deploy body format;
+// empty means the default.
+func validWebhookFormat(format string) bool {
+	return format == "" || format == webhookFormatJSON || format == webhookFormatSlack
+}
+
+func mergeOverlay(base []byte, overlayPath string) ([]byte, error) {
+	overlayData, err := os.ReadFile(overlayPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading overlay: %w", err)
+	}
+
+	var baseMap, overlayMap map[string]any
+	if err := yaml.Unmarshal(base, &baseMap); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if err := yaml.Unmarshal(overlayData, &overlayMap); err != nil {
+		return nil, fmt.Errorf("parsing overlay: %w", err)
+	}
+
+	merged, err := yaml.Marshal(deepMerge(baseMap, overlayMap))
+	if err != nil {
+		return nil, fmt.Errorf("merging overlay: %w", err)
+	}
+	return merged, nil
+}
+
+// deepMerge merges src into dst, recursing into nested mappings. Values in src win.
+func deepMerge(dst, src map[string]any) map[string]any {
+	if dst == nil {
+		dst = make(map[string]any, len(src))
+	}
+	for k, sv := range src {
+		srcMap, srcIsMap := sv.(map[string]any)
+		dstMap, dstIsMap := dst[k].(map[string]any)
+		if srcIsMap && dstIsMap {
+			dst[k] = deepMerge(dstMap, srcMap)
+			continue
+		}
+		dst[k] = sv
+	}
+	return dst
+}
+
+// validMemory matches the memory sizes docker run --memory accepts.
+var validMemory = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
 func validateConfig(cfg config) error {
 	if cfg.Project == "" {
 		return fmt.Errorf("missing project name")
@@ -65,7 +412,69 @@ func validateConfig(cfg config) error {
 		return fmt.Errorf("no services defined")
 	}
 
+	for _, hook := range cfg.Hooks.PostDeploy {
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("hooks.post_deploy: invalid URL %q", hook.URL)
+		}
+		if !validWebhookFormat(hook.Format) {
+			return fmt.Errorf("hooks.post_deploy: unknown format %q for %s (must be \"json\" or \"slack\")", hook.Format, hook.URL)
+		}
+	}
+	if !validWebhookFormat(cfg.Hooks.PostDeployFormat) {
+		return fmt.Errorf("hooks.post_deploy_format: unknown format %q (must be \"json\" or \"slack\")", cfg.Hooks.PostDeployFormat)
+	}
+
+	if cfg.Registry != nil && cfg.Registry.Type != "ecr" {
+		return fmt.Errorf("registry: unknown type %q (must be \"ecr\")", cfg.Registry.Type)
+	}
+
+	for group, members := range cfg.Groups {
+		if len(members) == 0 {
+			return fmt.Errorf("group %q: no services", group)
+		}
+		for _, name := range members {
+			if _, ok := cfg.Services[name]; !ok {
+				return fmt.Errorf("group %q: unknown service %q", group, name)
+			}
+		}
+	}
+
+	for i, env := range cfg.EnvOrder {
+		if slices.Contains(cfg.EnvOrder[:i], env) {
+			return fmt.Errorf("env_order: %q listed twice", env)
+		}
+		known := false
+		for _, svc := range cfg.Services {
+			if _, ok := svc.Env[env]; ok {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("env_order: no service has environment %q", env)
+		}
+	}
+
+	if cycle := dependencyCycle(cfg); cycle != nil {
+		return fmt.Errorf("depends_on cycle: %s", strings.Join(cycle, " -> "))
+	}
+
 	for name, svc := range cfg.Services {
+		for _, other := range svc.ConflictsWith {
+			if _, ok := cfg.Services[other]; !ok {
+				return fmt.Errorf("service %q: conflicts_with unknown service %q", name, other)
+			}
+			if other == name {
+				return fmt.Errorf("service %q: conflicts_with itself", name)
+			}
+		}
+		for _, dep := range svc.DependsOn {
+			if _, ok := cfg.Services[dep]; !ok {
+				return fmt.Errorf("service %q: depends_on unknown service %q", name, dep)
+			}
+		}
+
 		if svc.Type != "server" && svc.Type != "static" && svc.Type != "cronjob" {
 			return fmt.Errorf("service %q: unknown type %q (must be \"server\", \"static\", or \"cronjob\")", name, svc.Type)
 		}
@@ -78,8 +487,39 @@ func validateConfig(cfg config) error {
 			if svc.Port == 0 {
 				return fmt.Errorf("service %q: missing port", name)
 			}
-			if svc.Healthcheck == "" {
-				return fmt.Errorf("service %q: missing healthcheck", name)
+			switch svc.HealthcheckType {
+			case "", "http":
+				if svc.ReadinessCommand != "" {
+					if svc.Healthcheck != "" {
+						return fmt.Errorf("service %q: healthcheck and readiness_command are mutually exclusive", name)
+					}
+					break
+				}
+				if svc.Healthcheck == "" {
+					return fmt.Errorf("service %q: missing healthcheck", name)
+				}
+				if !strings.HasPrefix(svc.Healthcheck, "/") {
+					return fmt.Errorf("service %q: healthcheck %q must be a path starting with \"/\"", name, svc.Healthcheck)
+				}
+			case "tcp":
+				// A leftover healthcheck path is ignored: tcp only connects.
+				if svc.ReadinessCommand != "" {
+					return fmt.Errorf("service %q: readiness_command is not used with healthcheck_type tcp", name)
+				}
+			default:
+				return fmt.Errorf("service %q: unknown healthcheck_type %q (must be \"http\" or \"tcp\")", name, svc.HealthcheckType)
+			}
+			if svc.HealthcheckSuccesses < 0 {
+				return fmt.Errorf("service %q: healthcheck_successes must not be negative", name)
+			}
+			if svc.HealthcheckInterval < 0 {
+				return fmt.Errorf("service %q: healthcheck_interval must not be negative", name)
+			}
+			if svc.HealthcheckTimeout < 0 {
+				return fmt.Errorf("service %q: healthcheck_timeout must not be negative", name)
+			}
+			if svc.RemovalDelay < 0 {
+				return fmt.Errorf("service %q: removal_delay must not be negative", name)
 			}
 		case "cronjob":
 			if svc.Image == "" {
@@ -88,6 +528,19 @@ func validateConfig(cfg config) error {
 			if svc.Schedule == "" {
 				return fmt.Errorf("service %q: missing schedule", name)
 			}
+			if err := validateSchedule(svc.Schedule); err != nil {
+				return fmt.Errorf("service %q: invalid schedule: %w", name, err)
+			}
+			switch svc.Concurrency {
+			case "", "allow", "forbid":
+			default:
+				return fmt.Errorf("service %q: unknown concurrency %q (must be \"allow\" or \"forbid\")", name, svc.Concurrency)
+			}
+			if svc.Timezone != "" {
+				if _, err := time.LoadLocation(svc.Timezone); err != nil {
+					return fmt.Errorf("service %q: invalid timezone: %w", name, err)
+				}
+			}
 			if svc.Port != 0 {
 				return fmt.Errorf("service %q: cronjob must not have port", name)
 			}
@@ -95,6 +548,26 @@ func validateConfig(cfg config) error {
 				return fmt.Errorf("service %q: cronjob must not have healthcheck", name)
 			}
 		}
+		if svc.Memory != "" || svc.CPUs != 0 {
+			if svc.Type == "static" {
+				return fmt.Errorf("service %q: memory and cpus are not supported for static services", name)
+			}
+			if svc.Memory != "" && !validMemory.MatchString(svc.Memory) {
+				return fmt.Errorf("service %q: invalid memory %q (must be a number with an optional b, k, m or g suffix, e.g. 512m)", name, svc.Memory)
+			}
+			if svc.CPUs < 0 {
+				return fmt.Errorf("service %q: cpus must be positive", name)
+			}
+		}
+		if svc.Concurrency != "" && svc.Type != "cronjob" {
+			return fmt.Errorf("service %q: concurrency is only supported for cronjob services", name)
+		}
+		if svc.Timezone != "" && svc.Type != "cronjob" {
+			return fmt.Errorf("service %q: timezone is only supported for cronjob services", name)
+		}
+		if svc.ReadinessCommand != "" && svc.Type != "server" {
+			return fmt.Errorf("service %q: readiness_command is only supported for server services", name)
+		}
 
 		if len(svc.Env) == 0 {
 			return fmt.Errorf("service %q: no environments defined", name)
@@ -103,25 +576,53 @@ func validateConfig(cfg config) error {
 		for envName, env := range svc.Env {
 			switch svc.Type {
 			case "server":
-				if env.Node == "" {
+				if env.Node != "" && len(env.Nodes) > 0 {
+					return fmt.Errorf("service %q env %q: set node or nodes, not both", name, envName)
+				}
+				if len(env.nodeNames()) == 0 {
 					return fmt.Errorf("service %q env %q: missing node", name, envName)
 				}
-				if _, ok := cfg.Nodes[env.Node]; !ok {
-					return fmt.Errorf("service %q env %q: node %q not defined in nodes", name, envName, env.Node)
+				for _, node := range env.nodeNames() {
+					if _, ok := cfg.Nodes[node]; !ok {
+						return fmt.Errorf("service %q env %q: node %q not defined in nodes", name, envName, node)
+					}
 				}
 				if env.Host == "" {
 					return fmt.Errorf("service %q env %q: missing host", name, envName)
 				}
-				if env.EnvFile == "" {
+				if len(env.EnvFile) == 0 {
 					return fmt.Errorf("service %q env %q: missing envfile", name, envName)
 				}
+				for _, f := range env.EnvFile {
+					if f == "" {
+						return fmt.Errorf("service %q env %q: empty envfile path", name, envName)
+					}
+				}
 			case "static":
 				if env.Bucket == "" {
 					return fmt.Errorf("service %q env %q: missing bucket", name, envName)
 				}
-				if env.CloudFront == "" {
+				if len(env.CloudFront) == 0 && cfg.S3Endpoint == "" {
 					return fmt.Errorf("service %q env %q: missing cloudfront", name, envName)
 				}
+				for _, id := range env.CloudFront {
+					if id == "" {
+						return fmt.Errorf("service %q env %q: empty cloudfront distribution ID", name, envName)
+					}
+				}
+				for _, p := range env.InvalidationPaths {
+					if !strings.HasPrefix(p, "/") {
+						return fmt.Errorf("service %q env %q: invalidation path %q must start with \"/\"", name, envName, p)
+					}
+				}
+				for pattern, value := range env.CacheControl {
+					if _, err := path.Match(pattern, ""); err != nil {
+						return fmt.Errorf("service %q env %q: invalid cache_control pattern %q", name, envName, pattern)
+					}
+					if value == "" {
+						return fmt.Errorf("service %q env %q: empty cache_control for %q", name, envName, pattern)
+					}
+				}
 			case "cronjob":
 				if env.Node == "" {
 					return fmt.Errorf("service %q env %q: missing node", name, envName)
@@ -129,9 +630,41 @@ func validateConfig(cfg config) error {
 				if _, ok := cfg.Nodes[env.Node]; !ok {
 					return fmt.Errorf("service %q env %q: node %q not defined in nodes", name, envName, env.Node)
 				}
-				if env.EnvFile == "" {
+				if len(env.EnvFile) == 0 {
 					return fmt.Errorf("service %q env %q: missing envfile", name, envName)
 				}
+				for _, f := range env.EnvFile {
+					if f == "" {
+						return fmt.Errorf("service %q env %q: empty envfile path", name, envName)
+					}
+				}
+			}
+		}
+	}
+
+	if cfg.MaxBranchLength != 0 && cfg.MaxBranchLength <= branchHashLen {
+		return fmt.Errorf("max_branch_length must be more than %d", branchHashLen)
+	}
+	if cfg.Hooks.Timeout < 0 {
+		return fmt.Errorf("hooks.timeout must not be negative")
+	}
+	if cfg.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	if cfg.ContainerName != "" {
+		if err := validateContainerName(cfg.ContainerName); err != nil {
+			return err
+		}
+	}
+
+	if len(cfg.BranchEnvMap) > 0 {
+		envs := map[string]bool{}
+		for _, e := range allEnvironments(cfg) {
+			envs[e] = true
+		}
+		for branch, env := range cfg.BranchEnvMap {
+			if !envs[env] {
+				return fmt.Errorf("branch_env_map: branch %q maps to unknown environment %q", branch, env)
 			}
 		}
 	}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -67,7 +70,7 @@ services:
 			"staging1": "10.0.0.2",
 		},
 		Hooks: hooksConfig{
-			PostDeploy: "http://hooks.internal/deploy",
+			PostDeploy: webhookList{{URL: "http://hooks.internal/deploy"}},
 		},
 		Services: map[string]serviceConfig{
 			"api": {
@@ -76,15 +79,15 @@ services:
 				Port:        8080,
 				Healthcheck: "/health",
 				Env: map[string]envConfig{
-					"production": {Node: "prod1", Host: "api.example.com", EnvFile: ".env.prod"},
-					"staging":    {Node: "staging1", Host: "api.staging.example.com", EnvFile: ".env.staging"},
+					"production": {Node: "prod1", Host: "api.example.com", EnvFile: stringList{".env.prod"}},
+					"staging":    {Node: "staging1", Host: "api.staging.example.com", EnvFile: stringList{".env.staging"}},
 				},
 			},
 			"web": {
 				Type: "static",
 				Env: map[string]envConfig{
-					"production": {Bucket: "my-bucket-prod", CloudFront: "EPROD123"},
-					"staging":    {Bucket: "my-bucket-staging", CloudFront: "ESTAGING456"},
+					"production": {Bucket: "my-bucket-prod", CloudFront: stringList{"EPROD123"}},
+					"staging":    {Bucket: "my-bucket-staging", CloudFront: stringList{"ESTAGING456"}},
 				},
 			},
 		},
@@ -95,6 +98,251 @@ services:
 	}
 }
 
+func TestLoadConfigHealthcheckDurations(t *testing.T) {
+	yaml := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck: /health
+    healthcheck_interval: 5s
+    healthcheck_timeout: 5m
+    env:
+      prod:
+        node: n1
+        host: api.com
+        envfile: .env
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	svc := cfg.Services["api"]
+	if svc.HealthcheckInterval != 5*time.Second || svc.HealthcheckTimeout != 5*time.Minute {
+		t.Errorf("interval, timeout = %s, %s; want 5s, 5m", svc.HealthcheckInterval, svc.HealthcheckTimeout)
+	}
+
+	d := &serverDeployer{cfg: cfg}
+	if interval, timeout := d.pollSettings(svc); interval != 5*time.Second || timeout != 5*time.Minute {
+		t.Errorf("pollSettings = %s, %s; want 5s, 5m", interval, timeout)
+	}
+	if interval, timeout := d.pollSettings(serviceConfig{}); interval != 2*time.Second || timeout != 120*time.Second {
+		t.Errorf("default pollSettings = %s, %s; want 2s, 2m0s", interval, timeout)
+	}
+}
+
+func TestLoadConfigTCPHealthcheckWithoutPath(t *testing.T) {
+	yaml := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+services:
+  grpc:
+    type: server
+    image: grpc:latest
+    port: 50051
+    healthcheck_type: tcp
+    env:
+      prod:
+        node: n1
+        host: grpc.example.com
+        envfile: .env
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Services["grpc"].HealthcheckType; got != "tcp" {
+		t.Errorf("healthcheck_type = %q, want tcp", got)
+	}
+}
+
+func TestLoadConfigTCPHealthcheckIgnoresPath(t *testing.T) {
+	yaml := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+services:
+  grpc:
+    type: server
+    image: grpc:latest
+    port: 50051
+    healthcheck: /health
+    healthcheck_type: tcp
+    env:
+      prod:
+        node: n1
+        host: grpc.example.com
+        envfile: .env
+`
+	if _, err := loadConfig(writeTemp(t, yaml)); err != nil {
+		t.Fatalf("a leftover healthcheck path should be accepted with tcp, got: %v", err)
+	}
+}
+
+func TestLoadConfigReadinessCommand(t *testing.T) {
+	base := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+services:
+  db:
+    type: server
+    image: db:latest
+    port: 5432
+    readiness_command: pg_isready
+%s    env:
+      prod:
+        node: n1
+        host: db.example.com
+        envfile: .env
+`
+	cfg, err := loadConfig(writeTemp(t, fmt.Sprintf(base, "")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Services["db"].ReadinessCommand; got != "pg_isready" {
+		t.Errorf("readiness_command = %q, want pg_isready", got)
+	}
+
+	for extra, want := range map[string]string{
+		"    healthcheck: /health\n":  "mutually exclusive",
+		"    healthcheck_type: tcp\n": "not used with healthcheck_type tcp",
+	} {
+		_, err := loadConfig(writeTemp(t, fmt.Sprintf(base, extra)))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("with %q: expected %q error, got: %v", extra, want, err)
+		}
+	}
+}
+
+func TestLoadConfigResourceLimits(t *testing.T) {
+	base := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck: /health
+    memory: %s
+    cpus: 1.5
+    env:
+      prod:
+        node: n1
+        host: api.example.com
+        envfile: .env
+`
+	cfg, err := loadConfig(writeTemp(t, fmt.Sprintf(base, "512m")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if svc := cfg.Services["api"]; svc.Memory != "512m" || svc.CPUs != 1.5 {
+		t.Errorf("memory, cpus = %q, %v; want 512m, 1.5", svc.Memory, svc.CPUs)
+	}
+
+	for _, memory := range []string{"512mb", "2 g", "-1g", "lots"} {
+		_, err := loadConfig(writeTemp(t, fmt.Sprintf(base, strconv.Quote(memory))))
+		if err == nil || !strings.Contains(err.Error(), "invalid memory") {
+			t.Errorf("memory %q: expected invalid memory error, got: %v", memory, err)
+		}
+	}
+}
+
+func TestLoadConfigMaxBranchLengthTooShort(t *testing.T) {
+	yaml := `
+project: myapp
+max_branch_length: 5
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: web-prod
+        cloudfront: E123
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil || !strings.Contains(err.Error(), "max_branch_length must be more than 7") {
+		t.Errorf("expected max_branch_length error, got: %v", err)
+	}
+}
+
+func TestLoadConfigStructuredNodes(t *testing.T) {
+	yaml := `
+project: myapp
+nodes:
+  plain: ubuntu@10.0.0.1
+  custom:
+    host: 10.0.0.2
+    user: deploy
+    port: 2222
+  defaults:
+    host: web3.example.com
+  v6:
+    host: "2001:db8::1"
+  fleet:
+    exec: ./scripts/current-web-node
+services:
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck: /health
+    env:
+      production:
+        node: custom
+        host: api.example.com
+        envfile: .env
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := nodesConfig{
+		"plain":    "ubuntu@10.0.0.1",
+		"custom":   "deploy@10.0.0.2:2222",
+		"defaults": "web3.example.com:22",
+		"v6":       "[2001:db8::1]:22",
+		"fleet":    "exec:./scripts/current-web-node",
+	}
+	if diff := cmp.Diff(want, cfg.Nodes); diff != "" {
+		t.Errorf("nodes mismatch (-want +got):\n%s", diff)
+	}
+
+	user, hostport := parseSSHAddr(cfg.Nodes["custom"])
+	if user != "deploy" || hostport != "10.0.0.2:2222" {
+		t.Errorf("parseSSHAddr(custom) = %q, %q", user, hostport)
+	}
+}
+
+func TestLoadConfigStructuredNodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    string
+		wantErr string
+	}{
+		{"missing host", "{user: deploy}", `node "n1": missing host`},
+		{"bad port", "{host: 10.0.0.1, port: 70000}", `node "n1": invalid port 70000`},
+		{"exec with host", "{exec: ./node, host: 10.0.0.1}", `node "n1": exec can't be combined with host`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := "project: test\nnodes:\n  n1: " + tt.node + "\nservices: {}\n"
+			_, err := loadConfig(writeTemp(t, yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestLoadConfigServerMissingFields(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -158,6 +406,90 @@ services:
 `,
 			wantErr: "missing healthcheck",
 		},
+		{
+			name: "healthcheck without leading slash",
+			yaml: `
+project: test
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck: health
+    env:
+      prod:
+        node: n1
+        host: api.com
+        envfile: .env
+`,
+			wantErr: `healthcheck "health" must be a path starting with "/"`,
+		},
+		{
+			name: "conflicts_with unknown service",
+			yaml: `
+project: test
+nodes:
+  n1: 10.0.0.1
+services:
+  site:
+    type: static
+    conflicts_with: [api]
+    env:
+      prod:
+        bucket: site-prod
+`,
+			wantErr: `service "site": conflicts_with unknown service "api"`,
+		},
+		{
+			name: "unknown registry type",
+			yaml: `
+project: test
+registry:
+  type: gcr
+services:
+  site:
+    type: static
+    env:
+      prod:
+        bucket: site-prod
+        cloudfront: E123
+`,
+			wantErr: `registry: unknown type "gcr"`,
+		},
+		{
+			name: "env_order with unknown environment",
+			yaml: `
+project: test
+env_order: [staging, prod]
+services:
+  site:
+    type: static
+    env:
+      prod:
+        bucket: site-prod
+        cloudfront: E123
+`,
+			wantErr: `env_order: no service has environment "staging"`,
+		},
+		{
+			name: "group with unknown service",
+			yaml: `
+project: test
+nodes:
+  n1: 10.0.0.1
+groups:
+  web: [site, api]
+services:
+  site:
+    type: static
+    env:
+      prod:
+        bucket: site-prod
+`,
+			wantErr: `group "web": unknown service "api"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -218,7 +550,7 @@ services:
 			wantErr: "missing host",
 		},
 		{
-			name: "missing envfile",
+			name: "negative healthcheck_successes",
 			yaml: `
 project: test
 nodes:
@@ -229,30 +561,18 @@ services:
     image: api:latest
     port: 8080
     healthcheck: /health
+    healthcheck_successes: -1
     env:
       prod:
         node: n1
         host: api.com
+        envfile: .env
 `,
-			wantErr: "missing envfile",
+			wantErr: "healthcheck_successes must not be negative",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			_, err := loadConfig(writeTemp(t, tt.yaml))
-			if err == nil {
-				t.Fatal("expected error, got nil")
-			}
-			if !strings.Contains(err.Error(), tt.wantErr) {
-				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
-			}
-		})
-	}
-}
-
-func TestLoadConfigUndefinedNode(t *testing.T) {
-	yaml := `
+		{
+			name: "negative healthcheck_timeout",
+			yaml: `
 project: test
 nodes:
   n1: 10.0.0.1
@@ -262,27 +582,143 @@ services:
     image: api:latest
     port: 8080
     healthcheck: /health
+    healthcheck_timeout: -5m
     env:
       prod:
-        node: nonexistent
+        node: n1
         host: api.com
         envfile: .env
-`
-	_, err := loadConfig(writeTemp(t, yaml))
-	if err == nil {
-		t.Fatal("expected error, got nil")
-	}
-	if !strings.Contains(err.Error(), "not defined in nodes") {
-		t.Errorf("error = %q, want it to contain %q", err.Error(), "not defined in nodes")
-	}
-}
-
-func TestLoadConfigUnknownServiceType(t *testing.T) {
-	yaml := `
+`,
+			wantErr: "healthcheck_timeout must not be negative",
+		},
+		{
+			name: "unknown healthcheck_type",
+			yaml: `
 project: test
+nodes:
+  n1: 10.0.0.1
 services:
-  fn:
-    type: lambda
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck_type: grpc
+    env:
+      prod:
+        node: n1
+        host: api.com
+        envfile: .env
+`,
+			wantErr: `unknown healthcheck_type "grpc"`,
+		},
+		{
+			name: "node and nodes",
+			yaml: `
+project: test
+nodes:
+  n1: 10.0.0.1
+  n2: 10.0.0.2
+services:
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck: /health
+    env:
+      prod:
+        node: n1
+        nodes: [n1, n2]
+        host: api.com
+        envfile: .env
+`,
+			wantErr: "set node or nodes, not both",
+		},
+		{
+			name: "undefined entry in nodes",
+			yaml: `
+project: test
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck: /health
+    env:
+      prod:
+        nodes: [n1, n2]
+        host: api.com
+        envfile: .env
+`,
+			wantErr: `node "n2" not defined in nodes`,
+		},
+		{
+			name: "missing envfile",
+			yaml: `
+project: test
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck: /health
+    env:
+      prod:
+        node: n1
+        host: api.com
+`,
+			wantErr: "missing envfile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(writeTemp(t, tt.yaml))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigUndefinedNode(t *testing.T) {
+	yaml := `
+project: test
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api:latest
+    port: 8080
+    healthcheck: /health
+    env:
+      prod:
+        node: nonexistent
+        host: api.com
+        envfile: .env
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "not defined in nodes") {
+		t.Errorf("error = %q, want it to contain %q", err.Error(), "not defined in nodes")
+	}
+}
+
+func TestLoadConfigUnknownServiceType(t *testing.T) {
+	yaml := `
+project: test
+services:
+  fn:
+    type: lambda
     env:
       prod:
         bucket: b
@@ -427,6 +863,57 @@ services:
 	}
 }
 
+func TestLoadConfigCronjobInvalidSchedule(t *testing.T) {
+	yaml := `
+project: test
+nodes:
+  n1: 10.0.0.1
+services:
+  report:
+    type: cronjob
+    image: myapp/report
+    schedule: "0 25 * * *"
+    env:
+      prod:
+        node: n1
+        envfile: /etc/report/prod.env
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil || !strings.Contains(err.Error(), `service "report": invalid schedule: hour field "25"`) {
+		t.Errorf("expected invalid schedule error, got: %v", err)
+	}
+}
+
+func TestLoadConfigCronjobTimezone(t *testing.T) {
+	base := `
+project: test
+nodes:
+  n1: 10.0.0.1
+services:
+  report:
+    type: cronjob
+    image: myapp/report
+    schedule: "0 9 * * *"
+    timezone: %s
+    env:
+      prod:
+        node: n1
+        envfile: /etc/report/prod.env
+`
+	cfg, err := loadConfig(writeTemp(t, fmt.Sprintf(base, "America/New_York")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Services["report"].Timezone; got != "America/New_York" {
+		t.Errorf("timezone = %q, want America/New_York", got)
+	}
+
+	_, err = loadConfig(writeTemp(t, fmt.Sprintf(base, "Mars/Olympus_Mons")))
+	if err == nil || !strings.Contains(err.Error(), `service "report": invalid timezone`) {
+		t.Errorf("expected invalid timezone error, got: %v", err)
+	}
+}
+
 func TestLoadConfigCronjobMissingFields(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -611,3 +1098,552 @@ services:
 		t.Fatal("expected error, got nil")
 	}
 }
+
+func TestLoadConfigWithOverlay(t *testing.T) {
+	base := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api
+    port: 8080
+    healthcheck: /health
+    env:
+      staging:
+        node: n1
+        host: api.staging.example.com
+        envfile: .env.staging
+      production:
+        node: n1
+        host: api.example.com
+        envfile: .env.prod
+`
+	overlay := `
+nodes:
+  n2: 10.0.0.2
+services:
+  api:
+    port: 9090
+    env:
+      production:
+        node: n2
+`
+	cfg, err := loadConfigWithOverlay(writeTemp(t, base), writeTemp(t, overlay))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Nodes["n1"] != "10.0.0.1" || cfg.Nodes["n2"] != "10.0.0.2" {
+		t.Errorf("expected merged nodes, got %v", cfg.Nodes)
+	}
+	api := cfg.Services["api"]
+	if api.Port != 9090 {
+		t.Errorf("port = %d, want overlay value 9090", api.Port)
+	}
+	if api.Image != "api" {
+		t.Errorf("image = %q, want base value kept", api.Image)
+	}
+	want := envConfig{Node: "n2", Host: "api.example.com", EnvFile: stringList{".env.prod"}}
+	if diff := cmp.Diff(want, api.Env["production"]); diff != "" {
+		t.Errorf("production env mismatch (-want +got):\n%s", diff)
+	}
+	if api.Env["staging"].Node != "n1" {
+		t.Errorf("staging node = %q, want n1", api.Env["staging"].Node)
+	}
+}
+
+func TestLoadConfigEnvFileList(t *testing.T) {
+	yaml := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api
+    port: 8080
+    healthcheck: /health
+    env:
+      production:
+        node: n1
+        host: api.example.com
+        envfile:
+          - /etc/shared.env
+          - /etc/api/secrets.env
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := stringList{"/etc/shared.env", "/etc/api/secrets.env"}
+	if diff := cmp.Diff(want, cfg.Services["api"].Env["production"].EnvFile); diff != "" {
+		t.Errorf("envfile mismatch (-want +got):\n%s", diff)
+	}
+
+	empty := strings.Replace(yaml, "envfile:\n          - /etc/shared.env\n          - /etc/api/secrets.env", "envfile: []", 1)
+	if _, err := loadConfig(writeTemp(t, empty)); err == nil || !strings.Contains(err.Error(), "missing envfile") {
+		t.Errorf("expected missing envfile error for an empty list, got: %v", err)
+	}
+}
+
+func TestLoadConfigWithOverlayValidatesMerged(t *testing.T) {
+	base := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+services:
+  api:
+    type: server
+    image: api
+    port: 8080
+    healthcheck: /health
+    env:
+      production:
+        node: n1
+        host: api.example.com
+        envfile: .env.prod
+`
+	overlay := `
+services:
+  api:
+    env:
+      production:
+        node: missing
+`
+	_, err := loadConfigWithOverlay(writeTemp(t, base), writeTemp(t, overlay))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "not defined in nodes") {
+		t.Errorf("error = %q, want it to mention undefined node", err.Error())
+	}
+}
+
+func TestLoadConfigOverlayNotFound(t *testing.T) {
+	base := `
+project: myapp
+services: {}
+`
+	_, err := loadConfigWithOverlay(writeTemp(t, base), "/nonexistent/hoist.production.yml")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "reading overlay") {
+		t.Errorf("error = %q, want reading overlay error", err.Error())
+	}
+}
+
+func TestLoadConfigBranchEnvMap(t *testing.T) {
+	base := `
+project: myapp
+nodes:
+  n1: 10.0.0.1
+branch_env_map:
+  main: production
+  develop: %s
+services:
+  api:
+    type: server
+    image: api
+    port: 8080
+    healthcheck: /health
+    env:
+      production:
+        node: n1
+        host: api.example.com
+        envfile: .env.prod
+      staging:
+        node: n1
+        host: api.staging.example.com
+        envfile: .env.staging
+`
+	cfg, err := loadConfig(writeTemp(t, fmt.Sprintf(base, "staging")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BranchEnvMap["main"] != "production" || cfg.BranchEnvMap["develop"] != "staging" {
+		t.Errorf("unexpected branch_env_map: %v", cfg.BranchEnvMap)
+	}
+
+	_, err = loadConfig(writeTemp(t, fmt.Sprintf(base, "qa")))
+	if err == nil {
+		t.Fatal("expected error for unknown mapped environment")
+	}
+	if !strings.Contains(err.Error(), `maps to unknown environment "qa"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadConfigCloudFrontList(t *testing.T) {
+	yaml := `
+project: test
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+        cloudfront: [EUS123, EEU456]
+      staging:
+        bucket: my-bucket-staging
+        cloudfront: ESTAGING789
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	web := cfg.Services["web"]
+	if diff := cmp.Diff(stringList{"EUS123", "EEU456"}, web.Env["prod"].CloudFront); diff != "" {
+		t.Errorf("prod cloudfront mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(stringList{"ESTAGING789"}, web.Env["staging"].CloudFront); diff != "" {
+		t.Errorf("staging cloudfront mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadConfigCloudFrontEmptyList(t *testing.T) {
+	yaml := `
+project: test
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+        cloudfront: []
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "missing cloudfront") {
+		t.Errorf("error = %q, want missing cloudfront", err.Error())
+	}
+}
+
+func TestLoadConfigInvalidationPathWithoutSlash(t *testing.T) {
+	yaml := `
+project: test
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+        cloudfront: E123
+        invalidation_paths: [/index.html, assets/*]
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), `invalidation path "assets/*" must start with "/"`) {
+		t.Errorf("error = %q, want invalidation path error", err.Error())
+	}
+}
+
+func TestLoadConfigInvalidCacheControlPattern(t *testing.T) {
+	yaml := `
+project: test
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+        cloudfront: E123
+        cache_control:
+          "[.html": no-cache
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), `invalid cache_control pattern "[.html"`) {
+		t.Errorf("error = %q, want invalid cache_control pattern", err.Error())
+	}
+}
+
+func TestLoadConfigPostDeployList(t *testing.T) {
+	yaml := `
+project: test
+hooks:
+  post_deploy:
+    - https://hooks.slack.com/services/T000/B000/XXX
+    - url: https://audit.internal/deploys
+      format: json
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+        cloudfront: E123
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := webhookList{
+		{URL: "https://hooks.slack.com/services/T000/B000/XXX"},
+		{URL: "https://audit.internal/deploys", Format: "json"},
+	}
+	if diff := cmp.Diff(want, cfg.Hooks.PostDeploy); diff != "" {
+		t.Errorf("post_deploy mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadConfigPostDeployInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   string
+		wantErr string
+	}{
+		{"not a URL", "[hooks.internal/deploy]", `invalid URL "hooks.internal/deploy"`},
+		{"unknown format", "[{url: https://hooks.internal, format: xml}]", `unknown format "xml"`},
+		{"unknown default format", "https://hooks.internal\n  post_deploy_format: teams", `hooks.post_deploy_format: unknown format "teams"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml := `
+project: test
+hooks:
+  post_deploy: ` + tt.hooks + `
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+        cloudfront: E123
+`
+			_, err := loadConfig(writeTemp(t, yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDependsOnCycle(t *testing.T) {
+	yaml := `
+project: test
+services:
+  api:
+    type: static
+    depends_on: [migrate]
+    env:
+      prod:
+        bucket: api
+        cloudfront: E1
+  migrate:
+    type: static
+    depends_on: [web]
+    env:
+      prod:
+        bucket: migrate
+        cloudfront: E2
+  web:
+    type: static
+    depends_on: [api]
+    env:
+      prod:
+        bucket: web
+        cloudfront: E3
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil || !strings.Contains(err.Error(), "depends_on cycle: api -> migrate -> web -> api") {
+		t.Errorf("error = %v, want depends_on cycle", err)
+	}
+}
+
+func TestLoadConfigDependsOnUnknown(t *testing.T) {
+	yaml := `
+project: test
+services:
+  web:
+    type: static
+    depends_on: [migrate]
+    env:
+      prod:
+        bucket: web
+        cloudfront: E1
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil || !strings.Contains(err.Error(), `depends_on unknown service "migrate"`) {
+		t.Errorf("error = %v, want unknown service", err)
+	}
+}
+
+func TestLoadConfigS3EndpointSkipsCloudFront(t *testing.T) {
+	yaml := `
+project: test
+s3_endpoint: http://localhost:9000
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.S3Endpoint != "http://localhost:9000" {
+		t.Errorf("S3Endpoint = %q", cfg.S3Endpoint)
+	}
+}
+
+func TestLoadConfigS3EndpointFromEnv(t *testing.T) {
+	t.Setenv("HOIST_S3_ENDPOINT", "http://minio:9000")
+	yaml := `
+project: test
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.S3Endpoint != "http://minio:9000" {
+		t.Errorf("S3Endpoint = %q, want value from HOIST_S3_ENDPOINT", cfg.S3Endpoint)
+	}
+}
+
+func TestLoadConfigHookSecretFromEnv(t *testing.T) {
+	yaml := `
+project: test
+hooks:
+  post_deploy: https://hooks.internal/deploy
+  secret: ${HOIST_TEST_HOOK_SECRET}
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: my-bucket
+        cloudfront: E123
+`
+	path := writeTemp(t, yaml)
+
+	t.Setenv("HOIST_TEST_HOOK_SECRET", "s3cret")
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Hooks.Secret != "s3cret" {
+		t.Errorf("secret = %q, want it read from the environment", cfg.Hooks.Secret)
+	}
+
+	t.Setenv("HOIST_TEST_HOOK_SECRET", "")
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), "hooks.secret") {
+		t.Errorf("expected empty secret error, got: %v", err)
+	}
+
+	os.Unsetenv("HOIST_TEST_HOOK_SECRET")
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), "unset environment variables: HOIST_TEST_HOOK_SECRET") {
+		t.Errorf("expected unset variable error, got: %v", err)
+	}
+}
+
+func TestLoadConfigExpandsEnv(t *testing.T) {
+	t.Setenv("HOIST_TEST_NODE", "10.0.0.9")
+	t.Setenv("HOIST_TEST_REGISTRY", "123456.dkr.ecr.eu-west-1.amazonaws.com")
+	t.Setenv("HOIST_TEST_DOMAIN", "example.com")
+	t.Setenv("HOIST_TEST_STAGE", "prod")
+	yaml := `
+project: test
+nodes:
+  web1: ${HOIST_TEST_NODE}
+  build: {exec: "echo $HOME"}
+services:
+  api:
+    type: server
+    image: ${HOIST_TEST_REGISTRY}/api
+    port: 8080
+    healthcheck: /health
+    env:
+      prod:
+        node: web1
+        host: api.$HOIST_TEST_DOMAIN
+        envfile: ["/etc/api/${HOIST_TEST_STAGE}.env"]
+  web:
+    type: static
+    env:
+      prod:
+        bucket: web-${HOIST_TEST_STAGE}
+        cloudfront: ["E${HOIST_TEST_STAGE}"]
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Nodes["web1"] != "10.0.0.9" {
+		t.Errorf("node = %q", cfg.Nodes["web1"])
+	}
+	if !strings.HasSuffix(cfg.Nodes["build"], "echo $HOME") {
+		t.Errorf("exec node should not be expanded, got %q", cfg.Nodes["build"])
+	}
+	api := cfg.Services["api"]
+	if api.Image != "123456.dkr.ecr.eu-west-1.amazonaws.com/api" {
+		t.Errorf("image = %q", api.Image)
+	}
+	if ec := api.Env["prod"]; ec.Host != "api.example.com" || ec.EnvFile[0] != "/etc/api/prod.env" {
+		t.Errorf("host = %q, envfile = %v", ec.Host, ec.EnvFile)
+	}
+	if ec := cfg.Services["web"].Env["prod"]; ec.Bucket != "web-prod" || ec.CloudFront[0] != "Eprod" {
+		t.Errorf("bucket = %q, cloudfront = %v", ec.Bucket, ec.CloudFront)
+	}
+}
+
+func TestLoadConfigEscapedDollar(t *testing.T) {
+	t.Setenv("HOIST_TEST_STAGE", "prod")
+	yaml := `
+project: test
+hooks:
+  post_deploy: https://example.com/hook
+  secret: "a$$b$$$$c$$HOIST_TEST_STAGE"
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: web-$${HOIST_TEST_STAGE}-${HOIST_TEST_STAGE}
+        cloudfront: E123
+`
+	cfg, err := loadConfig(writeTemp(t, yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Hooks.Secret != "a$b$$c$HOIST_TEST_STAGE" {
+		t.Errorf("secret = %q", cfg.Hooks.Secret)
+	}
+	if b := cfg.Services["web"].Env["prod"].Bucket; b != "web-${HOIST_TEST_STAGE}-prod" {
+		t.Errorf("bucket = %q", b)
+	}
+}
+
+func TestLoadConfigUnsetEnv(t *testing.T) {
+	yaml := `
+project: test
+services:
+  web:
+    type: static
+    env:
+      prod:
+        bucket: ${HOIST_TEST_UNSET_BUCKET}
+        cloudfront: $HOIST_TEST_UNSET_CF
+`
+	_, err := loadConfig(writeTemp(t, yaml))
+	if err == nil || !strings.Contains(err.Error(), "unset environment variables: HOIST_TEST_UNSET_BUCKET, HOIST_TEST_UNSET_CF") {
+		t.Errorf("expected unset variable error, got: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// defaultContainerName names server containers when the config has no
+// container_name. It includes the environment so one node can run several
+// environments of a service without their containers colliding.
+const defaultContainerName = "{service}-{env}-{tag}"
+
+// validContainerName matches the names docker accepts.
+var validContainerName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// validateContainerName checks a container_name template: only the
+// {service}, {env} and {tag} placeholders, {service} somewhere, and {tag}
+// last so the tag can be read back from a running container's name.
+func validateContainerName(tmpl string) error {
+	if !strings.Contains(tmpl, "{service}") {
+		return fmt.Errorf("container_name %q must contain {service}", tmpl)
+	}
+	if !strings.HasSuffix(tmpl, "{tag}") || strings.Count(tmpl, "{tag}") != 1 {
+		return fmt.Errorf("container_name %q must end with {tag} and use it once", tmpl)
+	}
+	name := strings.NewReplacer("{service}", "svc", "{env}", "env", "{tag}", "tag").Replace(tmpl)
+	if !validContainerName.MatchString(name) {
+		return fmt.Errorf("container_name %q: only {service}, {env} and {tag} may be used, with letters, digits, '_', '.' and '-' around them", tmpl)
+	}
+	return nil
+}
+
+// containerPrefix returns the part of the container name for service in env
+// that comes before the tag.
+func containerPrefix(cfg config, service, env string) string {
+	tmpl := strings.TrimSuffix(cmp.Or(cfg.ContainerName, defaultContainerName), "{tag}")
+	return strings.NewReplacer("{service}", service, "{env}", env).Replace(tmpl)
+}
+
+// containerName returns the name of the server container running tag of
+// service in env.
+func containerName(cfg config, service, env, tag string) string {
+	return containerPrefix(cfg, service, env) + tag
+}
+
+// legacyContainerPrefix is the prefix of containers started before
+// container_name existed, which were named "<service>-<tag>". They are
+// still recognized so the first deploy after an upgrade finds and replaces
+// them.
+func legacyContainerPrefix(service string) string {
+	return service + "-"
+}
+
+// parseContainerTag extracts the tag from the name of a container of service
+// in env, under either the configured or the legacy naming. Returns empty
+// string if the container isn't one of them.
+func parseContainerTag(cfg config, service, env, name string) string {
+	if prefix := containerPrefix(cfg, service, env); strings.HasPrefix(name, prefix) {
+		return name[len(prefix):]
+	}
+	legacy := legacyContainerPrefix(service)
+	if !strings.HasPrefix(name, legacy) {
+		return ""
+	}
+	// "backend-" also matches "backend-staging-..." and "backend-worker-...",
+	// which belong to another environment or service.
+	for other, svc := range cfg.Services {
+		for e := range svc.Env {
+			if strings.HasPrefix(name, containerPrefix(cfg, other, e)) {
+				return ""
+			}
+		}
+	}
+	return name[len(legacy):]
+}
+
+// findContainer returns the first of names that is a container of service in
+// env, preferring names in the configured scheme: on a node that hosts
+// several environments, a legacy "<service>-<tag>" container may belong to
+// any of them.
+func findContainer(cfg config, service, env string, names []string) string {
+	prefix := containerPrefix(cfg, service, env)
+	var legacy string
+	for _, name := range names {
+		if parseContainerTag(cfg, service, env, name) == "" {
+			continue
+		}
+		if strings.HasPrefix(name, prefix) {
+			return name
+		}
+		if legacy == "" {
+			legacy = name
+		}
+	}
+	return legacy
+}
+
+// sharedNode reports whether another environment of service also runs on
+// node, so a legacy "<service>-<tag>" container there can't be told apart.
+func sharedNode(cfg config, service, env, node string) bool {
+	for other, ec := range cfg.Services[service].Env {
+		if other != env && slices.Contains(ec.nodeNames(), node) {
+			return true
+		}
+	}
+	return false
+}
+
+// containerFilter returns the docker ps filters that match service's
+// containers in env. Docker's name filter is a substring match, so results
+// still need checking with parseContainerTag.
+func containerFilter(cfg config, service, env string) string {
+	prefix := containerPrefix(cfg, service, env)
+	legacy := legacyContainerPrefix(service)
+	if strings.Contains(prefix, legacy) {
+		// The legacy filter matches the configured names too.
+		return fmt.Sprintf(`--filter "name=%s"`, legacy)
+	}
+	return fmt.Sprintf(`--filter "name=%s" --filter "name=%s"`, prefix, legacy)
+}
+
+// listServiceContainers returns the names of all running containers of
+// service in env. This catches orphaned containers from previous deploys.
+func listServiceContainers(ctx context.Context, client sshRunner, cfg config, service, env string) ([]string, error) {
+	out, err := client.run(ctx, fmt.Sprintf(`docker ps %s --format "{{.Names}}"`, containerFilter(cfg, service, env)))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && parseContainerTag(cfg, service, env, line) != "" {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
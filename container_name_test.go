@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestContainerName(t *testing.T) {
+	cfg := testConfig()
+	if got := containerName(cfg, "backend", "staging", "main-abc1234-20250101000000"); got != "backend-staging-main-abc1234-20250101000000" {
+		t.Errorf("default name = %q", got)
+	}
+
+	cfg.ContainerName = "{env}_{service}_{tag}"
+	if got := containerName(cfg, "backend", "staging", "main-abc1234-20250101000000"); got != "staging_backend_main-abc1234-20250101000000" {
+		t.Errorf("templated name = %q", got)
+	}
+}
+
+func TestParseContainerTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		env     string
+		input   string
+		want    string
+	}{
+		{"current scheme", "backend", "staging", "backend-staging-main-abc1234-20250101000000", "main-abc1234-20250101000000"},
+		{"branch with hyphens", "backend", "staging", "backend-staging-feat-login-abc1234-20250101000000", "feat-login-abc1234-20250101000000"},
+		{"legacy name", "backend", "staging", "backend-main-abc1234-20250101000000", "main-abc1234-20250101000000"},
+		{"other environment", "backend", "staging", "backend-production-main-abc1234-20250101000000", ""},
+		{"no prefix match", "backend", "staging", "unrelated", ""},
+		{"service name only", "backend", "staging", "backend-", ""},
+		{"different service", "api", "staging", "backend-staging-main-abc1234-20250101000000", ""},
+	}
+
+	cfg := testConfig()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseContainerTag(cfg, tt.service, tt.env, tt.input)
+			if got != tt.want {
+				t.Errorf("parseContainerTag(%q, %q, %q) = %q, want %q", tt.service, tt.env, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindContainer(t *testing.T) {
+	cfg := testConfig()
+	names := []string{
+		"backend-main-old1234-20241231000000",
+		"backend-production-main-abc1234-20250101000000",
+		"backend-staging-feat-def5678-20250102000000",
+	}
+	if got := findContainer(cfg, "backend", "staging", names); got != "backend-staging-feat-def5678-20250102000000" {
+		t.Errorf("staging = %q, want the env-named container over the legacy one", got)
+	}
+	if got := findContainer(cfg, "backend", "staging", names[:2]); got != "backend-main-old1234-20241231000000" {
+		t.Errorf("staging = %q, want the legacy container when no env-named one runs", got)
+	}
+	if got := findContainer(cfg, "backend", "staging", names[1:2]); got != "" {
+		t.Errorf("staging = %q, want none", got)
+	}
+}
+
+func TestContainerFilter(t *testing.T) {
+	cfg := testConfig()
+	if got := containerFilter(cfg, "backend", "staging"); got != `--filter "name=backend-"` {
+		t.Errorf("default filter = %q", got)
+	}
+
+	cfg.ContainerName = "{env}.{service}.{tag}"
+	if got := containerFilter(cfg, "backend", "staging"); got != `--filter "name=staging.backend." --filter "name=backend-"` {
+		t.Errorf("templated filter = %q", got)
+	}
+}
+
+func TestValidateContainerName(t *testing.T) {
+	for _, tmpl := range []string{"{service}-{env}-{tag}", "{service}-{tag}", "hoist.{env}.{service}_{tag}"} {
+		if err := validateContainerName(tmpl); err != nil {
+			t.Errorf("validateContainerName(%q) = %v", tmpl, err)
+		}
+	}
+	for _, tmpl := range []string{"{env}-{tag}", "{service}-{tag}-x", "{tag}-{service}-{tag}", "{service}-{node}-{tag}", "{service} {tag}"} {
+		if err := validateContainerName(tmpl); err == nil {
+			t.Errorf("validateContainerName(%q) should fail", tmpl)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// cronField describes one of the five fields of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names accepted in place of numbers, starting at min
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// cronMacros are the "@" shorthands cron accepts in place of the five fields.
+var cronMacros = []string{"@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly"}
+
+// validateSchedule checks that schedule is a cron expression the remote
+// crontab will accept: five fields of *, numbers, names, ranges, steps and
+// lists, or one of the @ macros.
+func validateSchedule(schedule string) error {
+	if strings.HasPrefix(schedule, "@") {
+		if !slices.Contains(cronMacros, schedule) {
+			return fmt.Errorf("unknown macro %q", schedule)
+		}
+		return nil
+	}
+	fields := strings.Fields(schedule)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("expected %d fields, got %d", len(cronFields), len(fields))
+	}
+	for i, f := range cronFields {
+		if err := f.validate(fields[i]); err != nil {
+			return fmt.Errorf("%s field %q: %w", f.name, fields[i], err)
+		}
+	}
+	return nil
+}
+
+// validate checks one field: a comma-separated list of "*", "n" or "n-m",
+// each optionally followed by "/step".
+func (f cronField) validate(field string) error {
+	for _, part := range strings.Split(field, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		if hasStep {
+			n, err := strconv.Atoi(step)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step %q", step)
+			}
+		}
+		if rng == "*" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(rng, "-")
+		start, err := f.value(lo)
+		if err != nil {
+			return err
+		}
+		if !isRange {
+			continue
+		}
+		end, err := f.value(hi)
+		if err != nil {
+			return err
+		}
+		if start > end {
+			return fmt.Errorf("range %q is backwards", rng)
+		}
+	}
+	return nil
+}
+
+// value parses a single number or name in the field.
+func (f cronField) value(s string) (int, error) {
+	if i := slices.Index(f.names, strings.ToLower(s)); i >= 0 {
+		return f.min + i, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("%d out of range %d-%d", n, f.min, f.max)
+	}
+	return n, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	for _, schedule := range []string{
+		"0 0 * * *",
+		"*/15 * * * *",
+		"0 9-17 * * 1-5",
+		"0 0,12 1 */2 *",
+		"30 2 * jan-mar mon-fri",
+		"0 0 * * 7",
+		"@daily",
+	} {
+		if err := validateSchedule(schedule); err != nil {
+			t.Errorf("validateSchedule(%q) = %v, want nil", schedule, err)
+		}
+	}
+
+	for schedule, want := range map[string]string{
+		"banana":       "expected 5 fields, got 1",
+		"0 0 * *":      "expected 5 fields, got 4",
+		"60 * * * *":   `minute field "60": 60 out of range 0-59`,
+		"0 24 * * *":   `hour field "24"`,
+		"0 0 0 * *":    `day of month field "0"`,
+		"0 0 * 13 *":   `month field "13"`,
+		"0 0 * * 8":    `day of week field "8"`,
+		"*/0 * * * *":  `invalid step "0"`,
+		"0 17-9 * * *": `range "17-9" is backwards`,
+		"0 x * * *":    `invalid value "x"`,
+		"0 1,,2 * * *": `invalid value ""`,
+		"@sometimes":   `unknown macro "@sometimes"`,
+	} {
+		err := validateSchedule(schedule)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("validateSchedule(%q) = %v, want error containing %q", schedule, err, want)
+		}
+	}
+}
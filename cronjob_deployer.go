@@ -3,12 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 )
 
 type cronjobDeployer struct {
-	cfg  config
-	dial func(addr string) (sshRunner, error)
+	cfg      config
+	dial     func(addr string) (sshRunner, error)
+	registry registryAuth // nil means no registry login
 }
 
 func (d *cronjobDeployer) deploy(ctx context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error {
@@ -19,15 +21,22 @@ func (d *cronjobDeployer) deploy(ctx context.Context, service, env, tag, oldTag
 	logf("connecting to %s (%s)", ec.Node, addr)
 	client, err := d.dial(addr)
 	if err != nil {
-		return fmt.Errorf("connecting to %s: %w", addr, err)
+		return dialFailed(addr, err)
 	}
 	defer client.close()
 
+	if err := runRegistryLogin(ctx, client, d.registry, logf); err != nil {
+		return err
+	}
+	if err := runPrePull(ctx, client, d.cfg, service, logf); err != nil {
+		return err
+	}
+
 	// Pull image.
 	pullCmd := fmt.Sprintf("docker pull %s:%s", svc.Image, tag)
 	logf("$ %s", pullCmd)
 	if _, err := client.run(ctx, pullCmd); err != nil {
-		return fmt.Errorf("pulling image: %w", err)
+		return pullFailed(err)
 	}
 	logf("image pulled")
 
@@ -45,7 +54,22 @@ func (d *cronjobDeployer) deploy(ctx context.Context, service, env, tag, oldTag
 	}
 
 	// Build the new block.
-	cronLine := buildCronLine(d.cfg.Project, service, env, tag, svc, ec)
+	cronLine := buildCronLine(d.cfg.Project, d.cfg.Region, service, env, tag, svc, ec)
+	if svc.Timezone != "" {
+		// CRON_TZ stays in effect for every line after it, so end the block
+		// by putting back the zone the lines below it were read in.
+		zone := crontabZoneBefore(crontab, blockID)
+		if zone == "" {
+			out, err := client.run(ctx, nodeTimezoneCommand)
+			if err != nil {
+				return fmt.Errorf("reading timezone of %s: %w", ec.Node, err)
+			}
+			if zone = strings.TrimSpace(out); zone == "" {
+				return fmt.Errorf("reading timezone of %s: no zone found", ec.Node)
+			}
+		}
+		cronLine += "\n" + cronTZResetMarker + "\nCRON_TZ=" + zone
+	}
 	newBlock := fmt.Sprintf("# hoist:begin %s\n# hoist:tag=%s\n# hoist:previous=%s\n%s\n# hoist:end %s", blockID, tag, previous, cronLine, blockID)
 	crontab = replaceCrontabBlock(crontab, blockID, newBlock)
 
@@ -60,22 +84,92 @@ func (d *cronjobDeployer) deploy(ctx context.Context, service, env, tag, oldTag
 	return nil
 }
 
-func buildCronLine(project, service, env, tag string, svc serviceConfig, ec envConfig) string {
+// plan logs what deploy would change, without connecting to the node.
+func (d *cronjobDeployer) plan(_ context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error {
+	svc := d.cfg.Services[service]
+	ec := svc.Env[env]
+
+	logf("would connect to %s (%s)", ec.Node, d.cfg.Nodes[ec.Node])
+	if d.registry != nil {
+		logf("would log in to the %s registry", d.cfg.Registry.Type)
+	}
+	if command := prePullCommand(d.cfg, service); command != "" {
+		logf("$ %s", command)
+	}
+	logf("$ docker pull %s:%s", svc.Image, tag)
+	logf("would write crontab entry %s-%s:", service, env)
+	for _, line := range strings.Split(buildCronLine(d.cfg.Project, d.cfg.Region, service, env, tag, svc, ec), "\n") {
+		logf("  %s", line)
+	}
+	if svc.Timezone != "" {
+		logf("  (followed by a CRON_TZ line putting back the zone in effect before the entry)")
+	}
+	return nil
+}
+
+// runNow runs the job once on its node, as cron would, streaming its output
+// to w.
+func (d *cronjobDeployer) runNow(ctx context.Context, service, env, tag string, w io.Writer) error {
+	svc := d.cfg.Services[service]
+	ec := svc.Env[env]
+	addr := d.cfg.Nodes[ec.Node]
+
+	client, err := d.dial(addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer client.close()
+
+	if err := client.stream(ctx, buildCronCommand(d.cfg.Project, d.cfg.Region, service, env, tag, svc, ec), w); err != nil {
+		return fmt.Errorf("running %s: %w", service, err)
+	}
+	return nil
+}
+
+// buildCronLine returns the crontab entry for the job. With a timezone, a
+// CRON_TZ line comes first so cron reads the schedule in that zone; deploy
+// ends the block with a second CRON_TZ line undoing it for the lines below.
+func buildCronLine(project, region, service, env, tag string, svc serviceConfig, ec envConfig) string {
+	line := svc.Schedule + " " + buildCronCommand(project, region, service, env, tag, svc, ec)
+	if svc.Timezone != "" {
+		line = "CRON_TZ=" + svc.Timezone + "\n" + line
+	}
+	return line
+}
+
+// buildCronCommand returns the shell command a cron line runs: it removes the
+// previous run's container and starts a new one in the foreground. With
+// concurrency "forbid", a previous run that is still going is left alone and
+// this run is skipped instead.
+func buildCronCommand(project, region, service, env, tag string, svc serviceConfig, ec envConfig) string {
 	containerName := service + "-" + env
 
 	var parts []string
-	parts = append(parts, svc.Schedule)
-	parts = append(parts, fmt.Sprintf("docker rm -f %s 2>/dev/null;", containerName))
+	if svc.Concurrency == "forbid" {
+		parts = append(parts,
+			fmt.Sprintf(`if [ "$(docker inspect -f '{{.State.Running}}' %s 2>/dev/null)" = true ]; then echo '%s is still running, skipping this run' >&2; exit 0; fi;`, containerName, containerName),
+			fmt.Sprintf("docker rm %s 2>/dev/null;", containerName),
+		)
+	} else {
+		parts = append(parts, fmt.Sprintf("docker rm -f %s 2>/dev/null;", containerName))
+	}
 
 	runArgs := []string{
 		"docker", "run",
 		"--name", containerName,
-		"--env-file", ec.EnvFile,
-		"--log-driver=awslogs",
-		"--log-opt", fmt.Sprintf("awslogs-region=us-east-1"),
+	}
+	for _, f := range ec.EnvFile {
+		runArgs = append(runArgs, "--env-file", f)
+	}
+	runArgs = append(runArgs, resourceArgs(svc)...)
+	runArgs = append(runArgs, "--log-driver=awslogs")
+	if region != "" {
+		runArgs = append(runArgs, "--log-opt", "awslogs-region="+region)
+	}
+	runArgs = append(runArgs,
 		"--log-opt", fmt.Sprintf("awslogs-group=/%s/%s/%s", project, env, service),
 		fmt.Sprintf("%s:%s", svc.Image, tag),
-	}
+	)
 
 	if svc.Command != "" {
 		runArgs = append(runArgs, svc.Command)
@@ -103,6 +197,31 @@ func shellQuote(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
+// cronTZResetMarker comes right before the CRON_TZ line that ends a block
+// with a timezone, so drift checks can tell it from the job's own lines.
+const cronTZResetMarker = "# hoist:reset-tz"
+
+// nodeTimezoneCommand prints the node's local zone, which cron reads
+// schedules in when no CRON_TZ is set.
+const nodeTimezoneCommand = "timedatectl show -p Timezone --value 2>/dev/null || cat /etc/timezone 2>/dev/null || readlink /etc/localtime | sed 's|.*/zoneinfo/||'"
+
+// crontabZoneBefore returns the CRON_TZ in effect where blockID's block is,
+// or where it would be appended: the value of the last CRON_TZ line before
+// it, or "" if there is none.
+func crontabZoneBefore(crontab, blockID string) string {
+	beginMarker := "# hoist:begin " + blockID
+	zone := ""
+	for _, line := range strings.Split(crontab, "\n") {
+		if line == beginMarker {
+			break
+		}
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "CRON_TZ="); ok {
+			zone = strings.Trim(v, `"'`)
+		}
+	}
+	return zone
+}
+
 // extractCrontabBlock returns the content between the begin/end markers for blockID,
 // or empty string if not found.
 func extractCrontabBlock(crontab, blockID string) string {
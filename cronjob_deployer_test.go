@@ -22,7 +22,7 @@ func cronjobTestConfig() config {
 				Env: map[string]envConfig{
 					"prod": {
 						Node:    "web1",
-						EnvFile: "/etc/report/prod.env",
+						EnvFile: stringList{"/etc/report/prod.env"},
 					},
 				},
 			},
@@ -227,6 +227,78 @@ func TestCronjobDeployReplacesBlock(t *testing.T) {
 	}
 }
 
+func TestCronjobDeployTimezone(t *testing.T) {
+	cfg := cronjobTestConfig()
+	svc := cfg.Services["report"]
+	svc.Timezone = "America/New_York"
+	cfg.Services["report"] = svc
+
+	// The live block already has a CRON_TZ line from an earlier deploy.
+	existingCrontab := "# hoist:begin report-prod\n# hoist:tag=old-tag\n# hoist:previous=\nCRON_TZ=America/New_York\n0 0 * * * docker run old\n# hoist:end report-prod\n"
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: ""},              // docker pull
+			{output: existingCrontab}, // crontab -l
+			{output: "Etc/UTC\n"},     // node timezone
+			{output: ""},              // printf | crontab -
+		},
+	}
+	d := &cronjobDeployer{
+		cfg:  cfg,
+		dial: func(_ string) (sshRunner, error) { return mock, nil },
+	}
+
+	if err := d.deploy(context.Background(), "report", "prod", "main-abc1234-20250101000000", "", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mock.commands[2] != nodeTimezoneCommand {
+		t.Errorf("expected the node timezone read, got: %s", mock.commands[2])
+	}
+	writeCmd := mock.commands[3]
+	if !strings.Contains(writeCmd, "# hoist:previous=old-tag\nCRON_TZ=America/New_York\n0 0 * * * docker rm -f report-prod") {
+		t.Errorf("expected CRON_TZ right before the cron line, got: %s", writeCmd)
+	}
+	if !strings.Contains(writeCmd, "\n# hoist:reset-tz\nCRON_TZ=Etc/UTC\n# hoist:end report-prod") {
+		t.Errorf("expected CRON_TZ reset to the node zone at the end of the block, got: %s", writeCmd)
+	}
+	if n := strings.Count(writeCmd, "CRON_TZ="); n != 2 {
+		t.Errorf("expected the old CRON_TZ line replaced, got %d in: %s", n, writeCmd)
+	}
+}
+
+func TestCronjobDeployTimezoneRestoresEarlierZone(t *testing.T) {
+	cfg := cronjobTestConfig()
+	svc := cfg.Services["report"]
+	svc.Timezone = "America/New_York"
+	cfg.Services["report"] = svc
+
+	// A CRON_TZ the user set above the block must be in effect again after it.
+	existingCrontab := "CRON_TZ=Europe/Paris\n0 1 * * * backup\n"
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: ""},              // docker pull
+			{output: existingCrontab}, // crontab -l
+			{output: ""},              // printf | crontab -
+		},
+	}
+	d := &cronjobDeployer{
+		cfg:  cfg,
+		dial: func(_ string) (sshRunner, error) { return mock, nil },
+	}
+
+	if err := d.deploy(context.Background(), "report", "prod", "main-abc1234-20250101000000", "", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(mock.commands) != 3 {
+		t.Fatalf("expected 3 commands, got %d: %v", len(mock.commands), mock.commands)
+	}
+	if !strings.Contains(mock.commands[2], "# hoist:reset-tz\nCRON_TZ=Europe/Paris\n# hoist:end report-prod") {
+		t.Errorf("expected CRON_TZ reset to Europe/Paris, got: %s", mock.commands[2])
+	}
+}
+
 func TestCronjobDeployPullFailure(t *testing.T) {
 	cfg := cronjobTestConfig()
 	mock := &mockSSHRunner{
@@ -277,10 +349,10 @@ func TestBuildCronLine(t *testing.T) {
 		Command:  "/run-report",
 	}
 	ec := envConfig{
-		EnvFile: "/etc/report/prod.env",
+		EnvFile: stringList{"/etc/report/prod.env"},
 	}
 
-	line := buildCronLine("myapp", "report", "prod", "main-abc1234-20250101000000", svc, ec)
+	line := buildCronLine("myapp", "eu-west-1", "report", "prod", "main-abc1234-20250101000000", svc, ec)
 
 	checks := []string{
 		"0 0 * * *",
@@ -289,6 +361,7 @@ func TestBuildCronLine(t *testing.T) {
 		"--name report-prod",
 		"--env-file /etc/report/prod.env",
 		"--log-driver=awslogs",
+		"--log-opt awslogs-region=eu-west-1",
 		"awslogs-group=/myapp/prod/report",
 		"myapp/report:main-abc1234-20250101000000",
 		"/run-report",
@@ -304,6 +377,25 @@ func TestBuildCronLine(t *testing.T) {
 	if strings.Contains(line, " root ") {
 		t.Errorf("cron line should not contain root user field, got: %s", line)
 	}
+
+	// With concurrency forbid, a run still in flight is never killed.
+	svc.Concurrency = "forbid"
+	line = buildCronLine("myapp", "eu-west-1", "report", "prod", "main-abc1234-20250101000000", svc, ec)
+	want := `0 0 * * * if [ "$(docker inspect -f '{{.State.Running}}' report-prod 2>/dev/null)" = true ]; then echo 'report-prod is still running, skipping this run' >&2; exit 0; fi; docker rm report-prod 2>/dev/null; docker run --name report-prod`
+	if !strings.HasPrefix(line, want) {
+		t.Errorf("expected forbid guard before docker run, got: %s", line)
+	}
+	if strings.Contains(line, "docker rm -f") {
+		t.Errorf("forbid must not force-remove the running container, got: %s", line)
+	}
+}
+
+func TestBuildCronLineResourceLimits(t *testing.T) {
+	svc := serviceConfig{Image: "myapp/report", Schedule: "0 0 * * *", Memory: "2g", CPUs: 0.5}
+	line := buildCronLine("myapp", "", "report", "prod", "main-abc1234-20250101000000", svc, envConfig{})
+	if !strings.Contains(line, "--memory 2g --cpus 0.5 --log-driver=awslogs") {
+		t.Errorf("expected --memory and --cpus flags, got: %s", line)
+	}
 }
 
 func TestBuildCronLineNoCommand(t *testing.T) {
@@ -312,15 +404,29 @@ func TestBuildCronLineNoCommand(t *testing.T) {
 		Schedule: "0 0 * * *",
 	}
 	ec := envConfig{
-		EnvFile: "/etc/report/prod.env",
+		EnvFile: stringList{"/etc/report/prod.env"},
 	}
 
-	line := buildCronLine("myapp", "report", "prod", "main-abc1234-20250101000000", svc, ec)
+	line := buildCronLine("myapp", "", "report", "prod", "main-abc1234-20250101000000", svc, ec)
 
 	// Image:tag should be the last thing on the line (no command after it).
 	if !strings.HasSuffix(line, "myapp/report:main-abc1234-20250101000000") {
 		t.Errorf("expected cron line to end with image:tag when no command, got: %s", line)
 	}
+	// Without a region the awslogs driver falls back to the daemon's.
+	if strings.Contains(line, "awslogs-region") {
+		t.Errorf("expected no awslogs-region when region is empty, got: %s", line)
+	}
+}
+
+func TestBuildCronLineMultipleEnvFiles(t *testing.T) {
+	svc := serviceConfig{Image: "myapp/report", Schedule: "0 0 * * *"}
+	ec := envConfig{EnvFile: stringList{"/etc/shared.env", "/etc/report/secrets.env"}}
+
+	line := buildCronLine("myapp", "", "report", "prod", "main-abc1234-20250101000000", svc, ec)
+	if !strings.Contains(line, "--env-file /etc/shared.env --env-file /etc/report/secrets.env") {
+		t.Errorf("expected one --env-file per entry in order, got: %s", line)
+	}
 }
 
 func TestParseCronfileTag(t *testing.T) {
@@ -409,3 +515,27 @@ func TestReplaceCrontabBlock(t *testing.T) {
 		}
 	})
 }
+
+func TestCronjobDeployServicePrePullOverridesGlobal(t *testing.T) {
+	cfg := testConfig()
+	cfg.PrePull = "global-prep"
+	svc := cfg.Services["report"]
+	svc.PrePull = "report-prep"
+	cfg.Services["report"] = svc
+	cluster := newFakeCluster()
+
+	d := &cronjobDeployer{cfg: cfg, dial: cluster.dial}
+	if err := d.deploy(context.Background(), "report", "staging", "main-abc1234-20250101000000", "", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cmds := cluster.node("10.0.0.1").recorded()
+	if len(cmds) == 0 || cmds[0] != "report-prep" {
+		t.Fatalf("expected service pre_pull first, got %v", cmds)
+	}
+	for _, cmd := range cmds {
+		if cmd == "global-prep" {
+			t.Error("top-level pre_pull should be replaced by the service's")
+		}
+	}
+}
@@ -97,3 +97,65 @@ func parseContainerFinishInfo(s string) (time.Duration, int) {
 
 	return time.Since(finished), exitCode
 }
+
+// cronDrift compares a cronjob's live crontab block with the line its config
+// renders for the same tag.
+type cronDrift struct {
+	Service string
+	Tag     string   // tag in the live block
+	Live    []string // cron lines in the live block; nil if there is no block
+	Want    string   // the lines the config renders for Tag
+}
+
+// drifted reports whether the live block no longer matches the config.
+func (d cronDrift) drifted() bool {
+	return d.Live != nil && strings.Join(d.Live, "\n") != d.Want
+}
+
+// crontabDiff reads the crontab of each service's node in env, once per node,
+// and compares every service's hoist block with what its config renders.
+func (p *cronjobHistoryProvider) crontabDiff(ctx context.Context, services []string, env string) ([]cronDrift, error) {
+	crontabs := make(map[string]string)
+	var drifts []cronDrift
+	for _, service := range services {
+		svc := p.cfg.Services[service]
+		ec := svc.Env[env]
+		addr := p.cfg.Nodes[ec.Node]
+
+		crontab, ok := crontabs[addr]
+		if !ok {
+			// crontab -l fails when the user has no crontab, which is
+			// just an empty one here.
+			out, err := p.run(ctx, addr, "crontab -l 2>/dev/null || true")
+			if err != nil {
+				return nil, fmt.Errorf("reading crontab on %s: %w", ec.Node, err)
+			}
+			crontab = out
+			crontabs[addr] = out
+		}
+
+		d := cronDrift{Service: service}
+		if block := extractCrontabBlock(crontab, service+"-"+env); block != "" {
+			d.Tag = parseCronfileTag(block, "tag")
+			d.Live = []string{}
+			reset := false
+			for _, line := range strings.Split(block, "\n") {
+				if line == cronTZResetMarker {
+					reset = true
+					continue
+				}
+				if line != "" && !strings.HasPrefix(line, "#") {
+					// The CRON_TZ line after the reset marker depends on
+					// the node, not the config.
+					if !reset {
+						d.Live = append(d.Live, line)
+					}
+					reset = false
+				}
+			}
+			d.Want = buildCronLine(p.cfg.Project, p.cfg.Region, service, env, d.Tag, svc, ec)
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, nil
+}
@@ -12,7 +12,7 @@ type cronjobLogsProvider struct {
 	dial func(addr string) (sshRunner, error)
 }
 
-func (p *cronjobLogsProvider) tail(ctx context.Context, service, env string, n int, since string, w io.Writer) error {
+func (p *cronjobLogsProvider) tail(ctx context.Context, service, env string, n int, since string, follow bool, w io.Writer) error {
 	svc := p.cfg.Services[service]
 	ec := svc.Env[env]
 	addr := p.cfg.Nodes[ec.Node]
@@ -25,8 +25,9 @@ func (p *cronjobLogsProvider) tail(ctx context.Context, service, env string, n i
 
 	containerName := service + "-" + env
 
-	// Check container exists (including exited ones).
-	psCmd := fmt.Sprintf(`docker ps -a --filter "name=^%s$" --format "{{.Names}}"`, containerName)
+	// Check container exists (including exited ones). Each run recreates it,
+	// so docker ps -a lists the most recent run first.
+	psCmd := fmt.Sprintf(`docker ps -a --filter "name=^%s$" --format "{{.Names}}\t{{.State}}"`, containerName)
 	out, err := client.run(ctx, psCmd)
 	if err != nil {
 		return fmt.Errorf("listing containers: %w", err)
@@ -34,9 +35,15 @@ func (p *cronjobLogsProvider) tail(ctx context.Context, service, env string, n i
 	if out == "" {
 		return fmt.Errorf("no runs yet for %s in %s", service, env)
 	}
-	container := strings.SplitN(out, "\n", 2)[0]
+	container, state, _ := strings.Cut(strings.SplitN(out, "\n", 2)[0], "\t")
+
+	// Following an exited run would return straight away; say so rather
+	// than look like the job has gone quiet.
+	if follow && state != "" && state != "running" {
+		fmt.Fprintf(w, "%s is not running (%s); showing its last run\n", container, state)
+		follow = false
+	}
 
-	follow := n == 0 && since == ""
 	args := dockerLogsArgs(container, since, n, follow)
 	cmd := "docker " + strings.Join(args, " ")
 
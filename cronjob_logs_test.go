@@ -23,7 +23,7 @@ func TestCronjobLogsTailHappyPath(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	err := lp.tail(context.Background(), "report", "prod", 100, "", &buf)
+	err := lp.tail(context.Background(), "report", "prod", 100, "", false, &buf)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -50,6 +50,54 @@ func TestCronjobLogsTailHappyPath(t *testing.T) {
 	}
 }
 
+func TestCronjobLogsTailSinceAndTail(t *testing.T) {
+	cfg := cronjobTestConfig()
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: "report-prod\trunning"}, // docker ps -a
+			{},                               // docker logs
+		},
+	}
+
+	lp := &cronjobLogsProvider{
+		cfg:  cfg,
+		dial: func(_ string) (sshRunner, error) { return mock, nil },
+	}
+
+	if err := lp.tail(context.Background(), "report", "prod", 50, "1h", true, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "docker logs --tail 50 --since 1h -f report-prod"; len(mock.commands) != 2 || mock.commands[1] != want {
+		t.Errorf("commands = %q, want %q last", mock.commands, want)
+	}
+}
+
+func TestCronjobLogsFollowExitedRun(t *testing.T) {
+	cfg := cronjobTestConfig()
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: "report-prod\texited"}, // docker ps -a
+			{output: "done\n"},              // docker logs
+		},
+	}
+
+	lp := &cronjobLogsProvider{
+		cfg:  cfg,
+		dial: func(_ string) (sshRunner, error) { return mock, nil },
+	}
+
+	var buf bytes.Buffer
+	if err := lp.tail(context.Background(), "report", "prod", 50, "1h", true, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "docker logs --tail 50 --since 1h report-prod"; len(mock.commands) != 2 || mock.commands[1] != want {
+		t.Errorf("commands = %q, want %q last", mock.commands, want)
+	}
+	if want := "report-prod is not running (exited); showing its last run\ndone\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
 func TestCronjobLogsTailNoContainer(t *testing.T) {
 	cfg := cronjobTestConfig()
 	mock := &mockSSHRunner{
@@ -64,7 +112,7 @@ func TestCronjobLogsTailNoContainer(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	err := lp.tail(context.Background(), "report", "prod", 100, "", &buf)
+	err := lp.tail(context.Background(), "report", "prod", 100, "", false, &buf)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -84,7 +132,7 @@ func TestCronjobLogsTailDialFailure(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	err := lp.tail(context.Background(), "report", "prod", 100, "", &buf)
+	err := lp.tail(context.Background(), "report", "prod", 100, "", false, &buf)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -17,6 +21,10 @@ import (
 
 var errCancelled = errors.New("cancelled")
 
+// errDeployFailed is returned when a deploy left failed services in place:
+// nothing was rolled back, or there was nothing to roll back to.
+var errDeployFailed = errors.New("deploy failed")
+
 type build struct {
 	Tag     string
 	Branch  string
@@ -31,12 +39,32 @@ type deploy struct {
 	Env      string
 	Tag      string
 	Uptime   time.Duration
-	ExitCode int // cronjob: last run exit code
+	ExitCode int               // cronjob: last run exit code
+	Health   string            // server: "healthy", "unhealthy" or "unknown"
+	NodeTags map[string]string // server on several nodes: tag running on each ("" if none)
+	Running  int               // server: most containers running at once on one node; more than 1 means a deploy didn't clean up
+}
+
+// drifted reports whether the nodes of a multi-node deploy disagree on the
+// running tag.
+func (d deploy) drifted() bool {
+	first := true
+	var tag string
+	for _, t := range d.NodeTags {
+		if first {
+			tag, first = t, false
+			continue
+		}
+		if t != tag {
+			return true
+		}
+	}
+	return false
 }
 
 func buildFromTag(t tag) build {
 	return build{
-		Tag:    generateTag(t.Branch, t.SHA, t.Time, t.Attempt),
+		Tag:    t.String(),
 		Branch: t.Branch,
 		SHA:    t.SHA,
 		Time:   t.Time,
@@ -57,7 +85,13 @@ type historyProvider interface {
 }
 
 type logsProvider interface {
-	tail(ctx context.Context, service, env string, n int, since string, w io.Writer) error
+	tail(ctx context.Context, service, env string, n int, since string, follow bool, w io.Writer) error
+}
+
+// tagLogsProvider is implemented by logs providers that can read the logs of
+// the container for a specific build tag, such as a replaced previous version.
+type tagLogsProvider interface {
+	tailTag(ctx context.Context, service, env, tag string, n int, since string, follow bool, w io.Writer) error
 }
 
 type providers struct {
@@ -65,40 +99,96 @@ type providers struct {
 	deployers map[string]deployer
 	history   map[string]historyProvider
 	logs      map[string]logsProvider
+	pool      *sshPool // connections shared by the history providers; nil in tests
+}
+
+// close releases the connections the providers hold open.
+func (p providers) close() error {
+	if p.pool == nil {
+		return nil
+	}
+	return p.pool.close()
 }
 
 type deployOpts struct {
-	Services []string
-	Env      string
-	Build    string
-	Tags     map[string]string // pre-resolved per-service tags (skips build select)
-	Yes      bool
+	Services     []string
+	Exclude      []string // services removed from the selection
+	Type         string   // restrict service selection to this type
+	Env          string
+	Build        string
+	Tags         map[string]string // pre-resolved per-service tags (skips build select)
+	Yes          bool
+	Pick         bool              // always show the build picker, even for a single build
+	Rollback     bool              // confirm with rollback wording
+	Downgrade    bool              // deploy builds older than the live ones without the extra confirmation
+	AllEnvs      bool              // deploy the build to every environment the services share, one after another
+	ParallelEnvs bool              // with AllEnvs, deploy to every environment at once
+	Continue     bool              // with AllEnvs, go on to the next environment after one fails
+	ShowCmds     bool              // show the rendered docker run command / crontab line before deploying
+	Parallel     int               // max services deployed at once; 0 means all, 1 deploys one at a time
+	DryRun       bool              // log what each deployer would do instead of deploying
+	Timestamps   bool              // start each deploy log line with the time since the deploy began
+	Format       string            // dry-run output: "" for the deployers' plans, "markdown" for a table
+	Previous     map[string]string // per-service previous tags overriding history (recorded as hoist.previous)
+	OnFailure    string            // rollback policy on deploy or smoke test failure (see rollbackPolicy*)
+	ResultFile   string            // write the final deploy result as JSON to this path
+	Output       io.Writer         // where deploy progress goes; nil means os.Stdout
 }
 
-// deployResult holds the outcome of a parallel deploy.
+// deployResult holds the outcome of a parallel deploy. Skipped services were
+// not attempted because a dependency failed; errors holds why for both.
 type deployResult struct {
-	failed []string
-	errors map[string]error
+	failed  []string
+	skipped []string
+	errors  map[string]error
 }
 
 type rollbackChoice int
 
 const (
-	rollbackAll    rollbackChoice = iota
+	rollbackAll rollbackChoice = iota
 	rollbackNone
 	rollbackFailed
 )
 
+// newServiceLogf returns a logf that prefixes lines with the service name.
+// Messages pass through redactSecrets, since deployers log the commands they run.
 func newServiceLogf(w io.Writer, mu *sync.Mutex, service string, padLen int) func(string, ...any) {
 	prefix := fmt.Sprintf("[%-*s]", padLen, service)
 	return func(format string, args ...any) {
-		msg := fmt.Sprintf(format, args...)
+		msg := redactSecrets(fmt.Sprintf(format, args...))
 		mu.Lock()
 		defer mu.Unlock()
 		fmt.Fprintf(w, "%s %s\n", prefix, msg)
 	}
 }
 
+// newSequentialLogf returns a logf for sequential deploys, where only one
+// service writes at a time and lines need neither a prefix nor a lock.
+func newSequentialLogf(w io.Writer) func(string, ...any) {
+	return func(format string, args ...any) {
+		fmt.Fprintf(w, "    %s\n", redactSecrets(fmt.Sprintf(format, args...)))
+	}
+}
+
+// isTerminal reports whether w writes to a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// withElapsed wraps logf to start each message with the time since start,
+// e.g. "+3.2s pulling image". The service prefix logf adds stays in front.
+func withElapsed(logf func(string, ...any), start time.Time) func(string, ...any) {
+	return func(format string, args ...any) {
+		logf("+%.1fs %s", time.Since(start).Seconds(), fmt.Sprintf(format, args...))
+	}
+}
+
 func maxServiceNameLen(services []string) int {
 	n := 0
 	for _, s := range services {
@@ -109,6 +199,34 @@ func maxServiceNameLen(services []string) int {
 	return n
 }
 
+// Rollback policies for --rollback, applied when a deploy or its smoke test fails.
+const (
+	rollbackPolicyPrompt = "prompt" // ask interactively
+	rollbackPolicyAuto   = "auto"   // roll back all services without asking
+	rollbackPolicyNever  = "never"  // leave the failed deploy in place
+)
+
+func checkRollbackPolicy(policy string) error {
+	switch policy {
+	case "", rollbackPolicyPrompt, rollbackPolicyAuto, rollbackPolicyNever:
+		return nil
+	}
+	return fmt.Errorf("invalid --rollback %q (must be \"prompt\", \"auto\", or \"never\")", policy)
+}
+
+// chooseRollback applies policy, prompting on r only for the prompt policy.
+func chooseRollback(policy string, r io.Reader, w io.Writer) rollbackChoice {
+	switch policy {
+	case rollbackPolicyAuto:
+		fmt.Fprintln(w, "Rolling back automatically (--rollback=auto).")
+		return rollbackAll
+	case rollbackPolicyNever:
+		fmt.Fprintln(w, "Leaving deploy in place (--rollback=never).")
+		return rollbackNone
+	}
+	return promptRollback(r)
+}
+
 func promptRollback(r io.Reader) rollbackChoice {
 	fmt.Print("Rollback? [Y/n/s] (Y=all, n=leave, s=failed only) ")
 	scanner := bufio.NewScanner(r)
@@ -129,12 +247,37 @@ func promptRollback(r io.Reader) rollbackChoice {
 }
 
 func runDeploy(ctx context.Context, cfg config, p providers, opts deployOpts) error {
+	// -s backend,backend names one service, not two deploys of it.
+	opts.Services = uniqueServices(opts.Services)
+	if !opts.AllEnvs && (opts.ParallelEnvs || opts.Continue) {
+		return fmt.Errorf("--parallel-envs and --continue require --all-envs")
+	}
+	if opts.AllEnvs {
+		return runDeployAllEnvs(ctx, cfg, p, opts)
+	}
+
 	env := opts.Env
 	if env == "" {
+		// With -s, only the environments every chosen service has are candidates,
+		// so a single-env service resolves without a prompt.
 		envs := allEnvironments(cfg)
-		if len(envs) == 1 {
+		if len(opts.Services) > 0 {
+			for _, svc := range opts.Services {
+				if _, ok := cfg.Services[svc]; !ok {
+					return fmt.Errorf("unknown service: %q", svc)
+				}
+			}
+			envs = envIntersection(cfg, opts.Services)
+			if len(envs) == 0 {
+				return fmt.Errorf("services %s have no environment in common", strings.Join(opts.Services, ", "))
+			}
+		}
+		switch {
+		case len(envs) == 1:
 			env = envs[0]
-		} else {
+		case opts.Yes:
+			return fmt.Errorf("multiple environments available (%s), use -e to pick one", strings.Join(envs, ", "))
+		default:
 			result, err := tea.NewProgram(newSingleSelectModel("Select environment:", envs)).Run()
 			if err != nil {
 				return err
@@ -147,10 +290,23 @@ func runDeploy(ctx context.Context, cfg config, p providers, opts deployOpts) er
 		}
 	}
 
-	services := opts.Services
+	if err := checkServiceTypes(cfg, opts.Services, opts.Type); err != nil {
+		return err
+	}
+	if err := checkServicesExist(cfg, opts.Exclude); err != nil {
+		return fmt.Errorf("--exclude: %w", err)
+	}
+
+	services := excludeServices(opts.Services, opts.Exclude)
+	if len(opts.Services) > 0 && len(services) == 0 {
+		return fmt.Errorf("every selected service is excluded")
+	}
 	if len(services) == 0 {
-		names := servicesWithEnv(cfg, env)
+		names := excludeServices(filterServicesByType(cfg, servicesWithEnv(cfg, env), opts.Type), opts.Exclude)
 		if len(names) == 0 {
+			if opts.Type != "" {
+				return fmt.Errorf("no %s services have environment %q", opts.Type, env)
+			}
 			return fmt.Errorf("no services have environment %q", env)
 		}
 		if len(names) == 1 {
@@ -178,24 +334,7 @@ func runDeploy(ctx context.Context, cfg config, p providers, opts deployOpts) er
 	}
 
 	fetchHistory := func(ctx context.Context) (map[string]bool, map[string]string, error) {
-		liveTags := make(map[string]bool)
-		previousTags := make(map[string]string)
-		for _, svc := range services {
-			svcCfg := cfg.Services[svc]
-			hp, ok := p.history[svcCfg.Type]
-			if !ok {
-				continue
-			}
-			cur, err := hp.current(ctx, svc, env)
-			if err != nil {
-				return nil, nil, fmt.Errorf("getting current deploy for %s: %w", svc, err)
-			}
-			if cur.Tag != "" {
-				liveTags[cur.Tag] = true
-				previousTags[svc] = cur.Tag
-			}
-		}
-		return liveTags, previousTags, nil
+		return currentTags(ctx, cfg, p, services, env)
 	}
 
 	// Resolve per-service tags: either pre-provided, from --build flag, or interactive
@@ -212,7 +351,18 @@ func runDeploy(ctx context.Context, cfg config, p providers, opts deployOpts) er
 		bp := buildsForServices(cfg, p, services)
 
 		var buildTag string
-		if opts.Build != "" {
+		var single []build
+		if opts.Build == "" && !opts.Pick {
+			// Skip the picker when there is nothing to choose between.
+			var err error
+			single, err = bp.listBuilds(ctx, 2, 0)
+			if err != nil {
+				return fmt.Errorf("listing builds: %w", err)
+			}
+		}
+
+		switch {
+		case opts.Build != "":
 			// Non-interactive: need history before resolving build.
 			liveTags, prevTags, err := fetchHistory(ctx)
 			if err != nil {
@@ -221,12 +371,22 @@ func runDeploy(ctx context.Context, cfg config, p providers, opts deployOpts) er
 			_ = liveTags
 			previousTags = prevTags
 
-			buildTag, err = resolveBuildTag(ctx, bp, opts.Build)
+			buildTag, err = resolveBuildTag(ctx, bp, opts.Build, blockedBuilds(cfg), cfg.MaxBranchLength)
 			if err != nil {
 				return fmt.Errorf("resolving build: %w", err)
 			}
-		} else {
-			result, err := tea.NewProgram(newBuildPickerModel(bp, env, fetchHistory)).Run()
+		case len(single) == 1:
+			_, prevTags, err := fetchHistory(ctx)
+			if err != nil {
+				return err
+			}
+			previousTags = prevTags
+			buildTag = single[0].Tag
+			fmt.Printf("Only one build available: %s\n", buildTag)
+		default:
+			pm := newBuildPickerModel(bp, env, fetchHistory)
+			pm.blocked = blockedBuilds(cfg)
+			result, err := tea.NewProgram(pm).Run()
 			if err != nil {
 				return fmt.Errorf("build picker: %w", err)
 			}
@@ -250,6 +410,56 @@ func runDeploy(ctx context.Context, cfg config, p providers, opts deployOpts) er
 		}
 	}
 
+	// A rollback is a downgrade by design.
+	var downgraded []serviceChange
+	if !opts.Rollback && !opts.Downgrade && !opts.DryRun {
+		downgraded = downgradedServices(services, tags, previousTags)
+	}
+
+	// --previous only changes what the new deploy records as its previous
+	// build. previousTags stays what is live: it is still what a failed
+	// deploy rolls back to and what the changes are shown against.
+	oldTags := previousTags
+	if len(opts.Previous) > 0 {
+		merged, err := applyPreviousOverrides(previousTags, services, opts.Previous)
+		if err != nil {
+			return err
+		}
+		oldTags = merged
+	}
+
+	blocked := blockedBuilds(cfg)
+	for _, svc := range services {
+		if blocked[tags[svc]] {
+			return blockedBuildError(tags[svc])
+		}
+	}
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if opts.Yes && opts.ShowCmds {
+		for _, svc := range services {
+			if command := renderDeployCommand(cfg, svc, env, tags[svc], oldTags[svc]); command != "" {
+				fmt.Fprintf(out, "%s: %s\n", svc, redactSecrets(command))
+			}
+		}
+	}
+
+	if opts.DryRun && opts.Format == planFormatMarkdown {
+		fmt.Print(formatPlanMarkdown(cfg, services, env, tags, previousTags))
+		return nil
+	}
+
+	// The commit ranges show in the confirm view and in the deploy's result
+	// file and hook events; skip the git lookups when nothing will show them.
+	var commits map[string]*commitRange
+	if !opts.Yes || (!opts.DryRun && (opts.ResultFile != "" || cfg.Hooks.PreDeploy != "" || len(cfg.Hooks.PostDeploy) > 0)) {
+		commits = lookupCommitRanges(ctx, services, tags, previousTags)
+	}
+
 	if !opts.Yes {
 		var changes []serviceChange
 		for _, svc := range services {
@@ -257,64 +467,342 @@ func runDeploy(ctx context.Context, cfg config, p providers, opts deployOpts) er
 				service: svc,
 				oldTag:  previousTags[svc],
 				newTag:  tags[svc],
+				commits: commits[svc],
+				command: redactSecrets(renderDeployCommand(cfg, svc, env, tags[svc], oldTags[svc])),
 			})
 		}
-		result, err := tea.NewProgram(newConfirmModel(env, changes)).Run()
+		cm := newConfirmModel(env, changes)
+		if opts.Rollback {
+			cm = newRollbackConfirmModel(env, changes)
+		}
+		cm.showCommands = opts.ShowCmds
+		result, err := tea.NewProgram(cm).Run()
 		if err != nil {
 			return fmt.Errorf("confirm: %w", err)
 		}
-		cm := result.(confirmModel)
-		if cm.result != confirmAccepted {
+		if result.(confirmModel).result != confirmAccepted {
+			return errCancelled
+		}
+	}
+
+	if len(downgraded) > 0 {
+		fmt.Fprintln(out, "This is a DOWNGRADE: the build is older than what is live for:")
+		var names []string
+		for _, c := range downgraded {
+			fmt.Fprintf(out, "  %s: %s -> %s\n", c.service, c.oldTag, c.newTag)
+			names = append(names, c.service)
+		}
+		if opts.Yes {
+			return fmt.Errorf("refusing to downgrade %s without --allow-downgrade", strings.Join(names, ", "))
+		}
+		if !confirmPrompt(os.Stdin, out, "Deploy the older build anyway?") {
 			return errCancelled
 		}
 	}
 
-	return deployAllWithLog(ctx, cfg, p, services, env, tags, previousTags, os.Stdout, os.Stdin)
+	if opts.DryRun {
+		return dryRunDeploy(ctx, cfg, p, services, env, tags, oldTags, out, opts.Parallel)
+	}
+	return deployAllWithLog(ctx, cfg, p, services, env, tags, previousTags, oldTags, commits, out, os.Stdin, opts.ResultFile, opts.OnFailure, opts.Parallel, opts.Timestamps)
+}
+
+// runDeployAllEnvs resolves opts.Build once and deploys it to every
+// environment the selected services share, in env_order, after a single
+// confirmation covering all of them. A build older than what any environment
+// runs is refused before deploying anywhere, unless opts.Downgrade is set. It
+// stops at the first environment that fails unless opts.Continue is set. With opts.ParallelEnvs, the environments
+// deploy at once, each line of output prefixed with its environment. Each
+// environment writes its own result file (see envResultFile).
+func runDeployAllEnvs(ctx context.Context, cfg config, p providers, opts deployOpts) error {
+	switch {
+	case opts.Env != "":
+		return fmt.Errorf("--all-envs and --env are mutually exclusive")
+	case opts.Build == "":
+		return fmt.Errorf("--all-envs requires --build")
+	case opts.DryRun:
+		return fmt.Errorf("--all-envs does not support --dry-run")
+	case opts.ParallelEnvs && opts.OnFailure != rollbackPolicyAuto && opts.OnFailure != rollbackPolicyNever:
+		// Environments deploying at once can't share the rollback prompt.
+		return fmt.Errorf("--parallel-envs requires --rollback auto or never")
+	}
+	if err := checkServicesExist(cfg, opts.Services); err != nil {
+		return err
+	}
+	if err := checkServiceTypes(cfg, opts.Services, opts.Type); err != nil {
+		return err
+	}
+	if err := checkServicesExist(cfg, opts.Exclude); err != nil {
+		return fmt.Errorf("--exclude: %w", err)
+	}
+	services := excludeServices(opts.Services, opts.Exclude)
+	if len(services) == 0 {
+		return fmt.Errorf("--all-envs requires -s")
+	}
+	envs := envIntersection(cfg, services)
+	if len(envs) == 0 {
+		return fmt.Errorf("services %s have no environment in common", strings.Join(services, ", "))
+	}
+
+	tag, err := resolveBuildTag(ctx, buildsForServices(cfg, p, services), opts.Build, blockedBuilds(cfg), cfg.MaxBranchLength)
+	if err != nil {
+		return fmt.Errorf("resolving build: %w", err)
+	}
+	tags := make(map[string]string, len(services))
+	for _, svc := range services {
+		tags[svc] = tag
+	}
+
+	how := "one environment at a time"
+	if opts.ParallelEnvs {
+		how = "all environments at once"
+	}
+	fmt.Printf("Deploying %s to %s, %s:\n", tag, strings.Join(envs, ", "), how)
+	var downgraded []string
+	for _, env := range envs {
+		_, live, err := currentTags(ctx, cfg, p, services, env)
+		if err != nil {
+			return err
+		}
+		for _, svc := range services {
+			fmt.Printf("  %s %s: %s -> %s\n", env, svc, cmp.Or(live[svc], "(none)"), tag)
+		}
+		for _, c := range downgradedServices(services, tags, live) {
+			downgraded = append(downgraded, env+" "+c.service)
+		}
+	}
+	// Refuse before any environment is touched, rather than stopping part
+	// way through when one of them turns out to run a newer build.
+	if len(downgraded) > 0 && !opts.Downgrade {
+		return fmt.Errorf("refusing to downgrade %s to the older %s without --allow-downgrade", strings.Join(downgraded, ", "), tag)
+	}
+	if !opts.Yes && !confirmPrompt(os.Stdin, os.Stdout, fmt.Sprintf("Deploy to all %d environments?", len(envs))) {
+		return errCancelled
+	}
+
+	deployEnv := func(env string, w io.Writer) error {
+		envOpts := opts
+		envOpts.AllEnvs = false
+		envOpts.ParallelEnvs = false
+		envOpts.Continue = false
+		envOpts.Env = env
+		envOpts.Services = services
+		envOpts.Exclude = nil
+		envOpts.Tags = tags
+		envOpts.Yes = true
+		envOpts.Output = w
+		if opts.ResultFile != "" {
+			envOpts.ResultFile = envResultFile(opts.ResultFile, env)
+		}
+		if err := runDeploy(ctx, cfg, p, envOpts); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		return nil
+	}
+
+	if opts.ParallelEnvs {
+		errs := make([]error, len(envs))
+		var wg sync.WaitGroup
+		for i, env := range envs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := newLinePrefixWriter(os.Stdout, "["+env+"]")
+				errs[i] = deployEnv(env, w)
+				w.Flush()
+			}()
+		}
+		wg.Wait()
+		return errors.Join(errs...)
+	}
+
+	var errs []error
+	for _, env := range envs {
+		fmt.Printf("==> %s\n", env)
+		if err := deployEnv(env, os.Stdout); err != nil {
+			if !opts.Continue {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// envResultFile returns where --all-envs writes env's result: path with the
+// environment before its extension, e.g. result.staging.json.
+func envResultFile(path, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// downgradedServices returns the services whose new tag was built before the
+// live one, with the live tag they were compared against as oldTag. Tags that
+// don't parse, and services with nothing live, are never downgrades.
+func downgradedServices(services []string, tags, liveTags map[string]string) []serviceChange {
+	var downgraded []serviceChange
+	for _, svc := range services {
+		live, err := parseTag(liveTags[svc])
+		if err != nil {
+			continue
+		}
+		next, err := parseTag(tags[svc])
+		if err != nil {
+			continue
+		}
+		if next.Time.Before(live.Time) {
+			downgraded = append(downgraded, serviceChange{service: svc, oldTag: liveTags[svc], newTag: tags[svc]})
+		}
+	}
+	return downgraded
+}
+
+// renderDeployCommand returns what deploying tag will run on the node: the
+// docker run command for a server, the crontab line for a cronjob. Static
+// services have no command and return "".
+func renderDeployCommand(cfg config, service, env, tag, oldTag string) string {
+	svc := cfg.Services[service]
+	ec := svc.Env[env]
+	switch svc.Type {
+	case "server":
+		return "docker run " + shellJoin(buildDockerRunArgs(cfg.Project, cfg.Region, containerName(cfg, service, env, tag), service, tag, oldTag, svc, ec, env))
+	case "cronjob":
+		return buildCronLine(cfg.Project, cfg.Region, service, env, tag, svc, ec)
+	}
+	return ""
+}
+
+// currentTags looks up what is live for each service in env. It returns the set
+// of live tags and the live tag per service.
+func currentTags(ctx context.Context, cfg config, p providers, services []string, env string) (map[string]bool, map[string]string, error) {
+	liveTags := make(map[string]bool)
+	previousTags := make(map[string]string)
+	for _, svc := range services {
+		svcCfg := cfg.Services[svc]
+		hp, ok := p.history[svcCfg.Type]
+		if !ok {
+			continue
+		}
+		cur, err := hp.current(ctx, svc, env)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting current deploy for %s: %w", svc, err)
+		}
+		if cur.Tag != "" {
+			liveTags[cur.Tag] = true
+			previousTags[svc] = cur.Tag
+		}
+	}
+	return liveTags, previousTags, nil
 }
 
 // deployAllWithLog runs parallel deploys with plain log output.
-func deployAllWithLog(ctx context.Context, cfg config, p providers, services []string, env string, tags map[string]string, previousTags map[string]string, w io.Writer, promptIn io.Reader) error {
+// When resultFile is set, the final deploy (and rollback, if any) events are
+// written there as JSON before returning, whether or not the deploy succeeded.
+// If the deploy or its smoke test fails, onFailure decides whether to roll back.
+// previousTags are what is live, the rollback target; oldTags are what each
+// service's deploy records as its previous build (nil means previousTags).
+// commits are the services' commit ranges reported in the deploy's events.
+func deployAllWithLog(ctx context.Context, cfg config, p providers, services []string, env string, tags map[string]string, previousTags, oldTags map[string]string, commits map[string]*commitRange, w io.Writer, promptIn io.Reader, resultFile, onFailure string, parallel int, timestamps bool) (err error) {
 	padLen := maxServiceNameLen(services)
 	var mu sync.Mutex
 
+	// Registered first so a deploy that stops early still leaves a result.
+	pending := buildPreDeployEvent(cfg.Project, env, services, tags, previousTags, commits)
+	report := deployReport{Deploy: pending}
+	if resultFile != "" {
+		defer func() {
+			if err != nil && report.Deploy.Result == "pending" {
+				report.Deploy.recordAbort(err)
+			}
+			if werr := writeDeployReport(resultFile, report); werr != nil && err == nil {
+				err = fmt.Errorf("writing result file: %w", werr)
+			}
+		}()
+	}
+
+	if cfg.Hooks.PreDeploy != "" {
+		if err := firePreDeployHook(ctx, cfg.Hooks, pending); err != nil {
+			return fmt.Errorf("aborting deploy: %w", err)
+		}
+	}
+
+	if oldTags == nil {
+		oldTags = previousTags
+	}
 	start := time.Now()
-	result, err := deployAll(ctx, cfg, p, services, env, tags, previousTags, w, &mu, padLen)
+	result, err := deployAll(ctx, cfg, p, services, env, tags, oldTags, w, &mu, padLen, parallel, timestamps)
 	if err != nil {
 		return err
 	}
 	duration := time.Since(start)
 
-	if len(result.failed) == 0 {
+	// Hooks run in the background so a slow endpoint doesn't hold up the
+	// rollback prompt, but we wait for them before returning so hoist doesn't
+	// exit with events still in flight.
+	var hooks []<-chan struct{}
+	hookWait := hookWaitTimeout(cfg.Hooks)
+	if cfg.Hooks.Wait {
+		hookWait = 0
+	}
+	defer func() { waitForHooks(hooks, hookWait) }()
+
+	var smokeErr error
+	if len(result.failed) == 0 && cfg.Hooks.SmokeTest != "" {
+		fmt.Fprintln(w, "Running smoke test...")
+		smokeErr = runSmokeTest(ctx, cfg.Hooks.SmokeTest, cfg.Project, env, services, tags, previousTags, w)
+	}
+
+	event := buildDeployEvent(cfg.Project, env, services, tags, previousTags, commits, result, duration, false)
+	if cfg.Hooks.SmokeTest != "" && len(result.failed) == 0 {
+		event.recordSmokeTest(smokeErr)
+	}
+	report.Deploy = event
+
+	if len(result.failed) == 0 && smokeErr == nil {
 		fmt.Fprintln(w, "Deploy complete!")
-		if cfg.Hooks.PostDeploy != "" {
-			event := buildDeployEvent(cfg.Project, env, services, tags, previousTags, result, duration, false)
-			firePostDeployHook(cfg.Hooks.PostDeploy, event)
-		}
+		hooks = append(hooks, goPostDeployHooks(cfg.Hooks, event)...)
 		return nil
 	}
 
 	fmt.Fprintln(w)
-	fmt.Fprintln(w, "Deploy failed!")
-	for _, svc := range result.failed {
-		fmt.Fprintf(w, "  %s: %v\n", svc, result.errors[svc])
+	if smokeErr != nil {
+		fmt.Fprintf(w, "Deploy succeeded but %v\n", smokeErr)
+	} else {
+		fmt.Fprintln(w, "Deploy failed!")
+		for _, svc := range result.failed {
+			fmt.Fprintf(w, "  %s: %v\n", svc, result.errors[svc])
+		}
+		for _, svc := range result.skipped {
+			fmt.Fprintf(w, "  %s: %v\n", svc, result.errors[svc])
+		}
 	}
 	fmt.Fprintln(w)
 
-	if cfg.Hooks.PostDeploy != "" {
-		event := buildDeployEvent(cfg.Project, env, services, tags, previousTags, result, duration, false)
-		firePostDeployHook(cfg.Hooks.PostDeploy, event)
+	hooks = append(hooks, goPostDeployHooks(cfg.Hooks, event)...)
+
+	failure := fmt.Errorf("%w: %s", errDeployFailed, strings.Join(slices.Concat(result.failed, result.skipped), ", "))
+	if smokeErr != nil {
+		failure = fmt.Errorf("%w: %v", errDeployFailed, smokeErr)
 	}
 
-	choice := promptRollback(promptIn)
+	choice := chooseRollback(onFailure, promptIn, w)
 
 	var rollbackServices []string
 	switch choice {
 	case rollbackAll:
-		rollbackServices = services
+		// Skipped services never left what was live.
+		for _, svc := range services {
+			if !slices.Contains(result.skipped, svc) {
+				rollbackServices = append(rollbackServices, svc)
+			}
+		}
 	case rollbackFailed:
 		rollbackServices = result.failed
+		if smokeErr != nil {
+			// Every service deployed; the smoke test can't tell which one broke.
+			rollbackServices = services
+		}
 	case rollbackNone:
-		return nil
+		return failure
 	}
 
 	rollbackTags := make(map[string]string, len(rollbackServices))
@@ -327,7 +815,7 @@ func deployAllWithLog(ctx context.Context, cfg config, p providers, services []s
 	}
 	if len(rollbackTags) == 0 {
 		fmt.Fprintln(w, "Nothing to roll back.")
-		return nil
+		return failure
 	}
 
 	var rollbackTargets []string
@@ -337,66 +825,249 @@ func deployAllWithLog(ctx context.Context, cfg config, p providers, services []s
 
 	fmt.Fprintf(w, "Rolling back %d service(s)...\n", len(rollbackTargets))
 	rbStart := time.Now()
-	rbResult, err := deployAll(ctx, cfg, p, rollbackTargets, env, rollbackTags, tags, w, &mu, padLen)
+	rbResult, err := deployAll(ctx, cfg, p, rollbackTargets, env, rollbackTags, tags, w, &mu, padLen, parallel, timestamps)
 	if err != nil {
 		return fmt.Errorf("rollback: %w", err)
 	}
+	rbEvent := buildDeployEvent(cfg.Project, env, rollbackTargets, rollbackTags, tags, nil, rbResult, time.Since(rbStart), true)
+	report.Rollback = &rbEvent
 	if len(rbResult.failed) > 0 {
 		return fmt.Errorf("rollback failed for: %v", rbResult.failed)
 	}
 	fmt.Fprintln(w, "Rollback complete.")
 
-	if cfg.Hooks.PostDeploy != "" {
-		rbDuration := time.Since(rbStart)
-		event := buildDeployEvent(cfg.Project, env, rollbackTargets, rollbackTags, tags, rbResult, rbDuration, true)
-		firePostDeployHook(cfg.Hooks.PostDeploy, event)
-	}
+	hooks = append(hooks, goPostDeployHooks(cfg.Hooks, rbEvent)...)
 
 	return nil
 }
 
-// deployAll runs parallel deploys with log output. Returns results for the caller to handle.
-func deployAll(ctx context.Context, cfg config, p providers, services []string, env string, tags map[string]string, previousTags map[string]string, w io.Writer, mu *sync.Mutex, padLen int) (deployResult, error) {
+// deployAll deploys services concurrently, at most parallel at a time (0
+// means no limit), and returns results for the caller to handle. A service
+// waits for the services it depends_on that are part of the same deploy, and
+// is skipped if any of them didn't deploy. With parallel 1 services deploy one
+// after another in dependency order, and each one's output is streamed under
+// a header instead of being prefixed.
+func deployAll(ctx context.Context, cfg config, p providers, services []string, env string, tags map[string]string, previousTags map[string]string, w io.Writer, mu *sync.Mutex, padLen int, parallel int, timestamps bool) (deployResult, error) {
 	type result struct {
 		service string
 		err     error
+		skipped bool
 	}
 
-	results := make(chan result, len(services))
-	var wg sync.WaitGroup
-
+	services = uniqueServices(services)
+	// Each service's outcome is written before its done channel is closed,
+	// and only read by dependents after it is.
+	outcome := make(map[string]*result, len(services))
+	done := make(map[string]chan struct{}, len(services))
 	for _, svc := range services {
-		wg.Add(1)
-		go func(svc string) {
-			defer wg.Done()
-			logf := newServiceLogf(w, mu, svc, padLen)
-			oldTag := previousTags[svc]
-			logf("deploying %s -> %s (env=%s)", oldTag, tags[svc], env)
-			err := deployService(ctx, cfg, p, svc, env, tags[svc], oldTag, logf)
-			if err != nil {
-				logf("FAILED: %v", err)
-			} else {
-				logf("done")
+		outcome[svc] = &result{service: svc}
+		done[svc] = make(chan struct{})
+	}
+	// blockedBy waits for the dependencies of svc in this deploy and returns
+	// the first one that didn't deploy, or "".
+	blockedBy := func(svc string) string {
+		for _, dep := range cfg.Services[svc].DependsOn {
+			ch, ok := done[dep]
+			if !ok {
+				continue
+			}
+			<-ch
+			if outcome[dep].err != nil {
+				return dep
 			}
-			results <- result{service: svc, err: err}
-		}(svc)
+		}
+		return ""
 	}
 
-	wg.Wait()
+	// In CI logs, where output isn't a terminal, a running count shows how
+	// far along a multi-service deploy is.
+	progress := len(services) > 1 && !isTerminal(w)
+	completed := 0
+
+	results := make(chan result, len(services))
+	finish := func(r result) {
+		*outcome[r.service] = r
+		close(done[r.service])
+		results <- r
+		if progress {
+			mu.Lock()
+			completed++
+			fmt.Fprintf(w, "(%d/%d services complete)\n", completed, len(services))
+			mu.Unlock()
+		}
+	}
+
+	start := time.Now()
+	deployOne := func(svc string, logf func(string, ...any)) result {
+		if timestamps {
+			logf = withElapsed(logf, start)
+		}
+		if dep := blockedBy(svc); dep != "" {
+			err := fmt.Errorf("skipped: dependency %s did not deploy", dep)
+			logf("%v", err)
+			return result{service: svc, err: err, skipped: true}
+		}
+		oldTag := previousTags[svc]
+		logf("deploying %s -> %s (env=%s)", oldTag, tags[svc], env)
+		err := deployService(ctx, cfg, p, svc, env, tags[svc], oldTag, logf)
+		if err != nil {
+			logf("FAILED: %v", err)
+		} else {
+			logf("done")
+		}
+		return result{service: svc, err: err}
+	}
+
+	if parallel == 1 {
+		for _, svc := range dependencyOrder(cfg, services) {
+			fmt.Fprintf(w, "==> %s\n", svc)
+			finish(deployOne(svc, newSequentialLogf(w)))
+		}
+	} else {
+		limit := parallel
+		if limit <= 0 {
+			limit = len(services)
+		}
+		sem := make(chan struct{}, limit)
+		locks := conflictLocks(cfg, services)
+		var wg sync.WaitGroup
+		for _, svc := range services {
+			wg.Add(1)
+			go func(svc string) {
+				defer wg.Done()
+				// Wait for dependencies before taking a conflict lock or a
+				// slot, so a waiting service holds neither.
+				blockedBy(svc)
+				// Take the conflict lock before a slot, so services waiting
+				// on a conflict don't hold slots others could use.
+				locks[svc].Lock()
+				defer locks[svc].Unlock()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				finish(deployOne(svc, newServiceLogf(w, mu, svc, padLen)))
+			}(svc)
+		}
+		wg.Wait()
+	}
 	close(results)
 
-	var failed []string
+	var failed, skipped []string
 	errs := make(map[string]error)
 	for r := range results {
-		if r.err != nil {
+		switch {
+		case r.skipped:
+			skipped = append(skipped, r.service)
+		case r.err != nil:
 			failed = append(failed, r.service)
-			errs[r.service] = r.err
+		default:
+			continue
 		}
+		errs[r.service] = r.err
 	}
 
-	return deployResult{failed: failed, errors: errs}, nil
+	return deployResult{failed: failed, skipped: skipped, errors: errs}, nil
 }
 
+// dependencyOrder sorts services so each comes after the services it
+// depends_on among them, otherwise keeping the given order. depends_on is
+// checked for cycles when the config is loaded.
+func dependencyOrder(cfg config, services []string) []string {
+	inDeploy := make(map[string]bool, len(services))
+	for _, svc := range services {
+		inDeploy[svc] = true
+	}
+	placed := make(map[string]bool, len(services))
+	ordered := make([]string, 0, len(services))
+	for len(ordered) < len(services) {
+		progressed := false
+		for _, svc := range services {
+			if placed[svc] {
+				continue
+			}
+			ready := true
+			for _, dep := range cfg.Services[svc].DependsOn {
+				if inDeploy[dep] && !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[svc] = true
+				ordered = append(ordered, svc)
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			// Duplicates or a dependency cycle: keep the rest in the
+			// given order rather than looping forever.
+			for _, svc := range services {
+				if !placed[svc] {
+					placed[svc] = true
+					ordered = append(ordered, svc)
+				}
+			}
+			break
+		}
+	}
+	return ordered
+}
+
+// uniqueServices returns services without repeats, keeping the first
+// occurrence of each.
+func uniqueServices(services []string) []string {
+	seen := make(map[string]bool, len(services))
+	var out []string
+	for _, svc := range services {
+		if !seen[svc] {
+			seen[svc] = true
+			out = append(out, svc)
+		}
+	}
+	return out
+}
+
+// conflictLocks returns a lock per service such that services linked by
+// conflicts_with, directly or through each other, share one lock and so
+// deploy one at a time. Conflicts are symmetric.
+func conflictLocks(cfg config, services []string) map[string]*sync.Mutex {
+	parent := make(map[string]string, len(services))
+	for _, svc := range services {
+		parent[svc] = svc
+	}
+	var find func(string) string
+	find = func(s string) string {
+		if parent[s] != s {
+			parent[s] = find(parent[s])
+		}
+		return parent[s]
+	}
+	for _, svc := range services {
+		for _, other := range cfg.Services[svc].ConflictsWith {
+			if _, ok := parent[other]; ok {
+				parent[find(svc)] = find(other)
+			}
+		}
+	}
+
+	roots := make(map[string]*sync.Mutex)
+	locks := make(map[string]*sync.Mutex, len(services))
+	for _, svc := range services {
+		root := find(svc)
+		if roots[root] == nil {
+			roots[root] = &sync.Mutex{}
+		}
+		locks[svc] = roots[root]
+	}
+	return locks
+}
+
+// deployRetryBackoff is the wait before the first retry of a transient
+// deploy failure; it doubles with each retry.
+var deployRetryBackoff = 2 * time.Second
+
+// deployService deploys one service, retrying it up to cfg.Retries times with
+// exponential backoff when it fails transiently.
 func deployService(ctx context.Context, cfg config, p providers, service, env, tag, oldTag string, logf func(string, ...any)) error {
 	svc := cfg.Services[service]
 
@@ -405,12 +1076,178 @@ func deployService(ctx context.Context, cfg config, p providers, service, env, t
 		return fmt.Errorf("no deployer for service type %q", svc.Type)
 	}
 
-	return d.deploy(ctx, service, env, tag, oldTag, logf)
+	backoff := deployRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := d.deploy(ctx, service, env, tag, oldTag, logf)
+		if err == nil || attempt >= cfg.Retries || ctx.Err() != nil || !isTransientDeployError(err) {
+			return err
+		}
+		logf("attempt %d of %d failed (%v), retrying in %s", attempt+1, cfg.Retries+1, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// transientError marks a deploy failure that happened before anything on the
+// node changed and may pass on a retry: a network failure dialing the node
+// or pulling the image.
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string { return e.err.Error() }
+func (e transientError) Unwrap() error { return e.err }
+
+// isTransientDeployError reports whether err is worth retrying. Joined errors,
+// from multi-node deploys, must all be transient.
+func isTransientDeployError(err error) bool {
+	switch e := err.(type) {
+	case transientError:
+		return true
+	case interface{ Unwrap() []error }:
+		errs := e.Unwrap()
+		for _, err := range errs {
+			if !isTransientDeployError(err) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	case interface{ Unwrap() error }:
+		return isTransientDeployError(e.Unwrap())
+	}
+	return false
+}
+
+// dialFailed wraps a failure to connect to addr, marking it transient only
+// when the network is to blame. A host key that doesn't verify or a missing
+// SSH agent will fail the same way on every attempt.
+func dialFailed(addr string, err error) error {
+	err = fmt.Errorf("connecting to %s: %w", addr, err)
+	var hkErr *hostKeyError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &hkErr):
+		return err
+	case errors.As(err, &opErr) && opErr.Net == "unix":
+		// The local SSH agent socket, not the node.
+		return err
+	case isNetworkError(err):
+		return transientError{err}
+	}
+	return err
+}
+
+// pullRetryMarkers are substrings of docker pull errors that point at the
+// network or the registry being briefly unavailable, not at the image.
+var pullRetryMarkers = []string{
+	"timeout",
+	"connection reset",
+	"connection refused",
+	"no such host",
+	"temporary failure in name resolution",
+	"EOF",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+	"toomanyrequests",
+}
+
+// pullFailed wraps a failed docker pull, marking it transient only when it
+// looks like a network failure: a missing tag ("manifest unknown") or denied
+// access won't be fixed by pulling again.
+func pullFailed(err error) error {
+	wrapped := fmt.Errorf("pulling image: %w", err)
+	if isNetworkError(err) {
+		return transientError{wrapped}
+	}
+	msg := err.Error()
+	for _, m := range pullRetryMarkers {
+		if strings.Contains(msg, m) {
+			return transientError{wrapped}
+		}
+	}
+	return wrapped
+}
+
+// isNetworkError reports whether err comes from the connection itself: a
+// network error, or the SSH connection closing under us.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// deployPlanner is implemented by deployers that can describe a deploy without
+// performing it, for --dry-run.
+type deployPlanner interface {
+	plan(ctx context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error
+}
+
+// dryRunDeployer stands in for a deployer under --dry-run and logs its plan.
+type dryRunDeployer struct {
+	d deployer
+}
+
+func (r dryRunDeployer) deploy(ctx context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error {
+	pl, ok := r.d.(deployPlanner)
+	if !ok {
+		logf("dry run: no plan available for this service type")
+		return nil
+	}
+	return pl.plan(ctx, service, env, tag, oldTag, logf)
 }
 
+// dryRunDeploy runs deployAll with every deployer swapped for its plan. No
+// hooks or smoke tests run and nothing is rolled back.
+func dryRunDeploy(ctx context.Context, cfg config, p providers, services []string, env string, tags, previousTags map[string]string, w io.Writer, parallel int) error {
+	deployers := make(map[string]deployer, len(p.deployers))
+	for typ, d := range p.deployers {
+		deployers[typ] = dryRunDeployer{d: d}
+	}
+	p.deployers = deployers
+
+	fmt.Fprintln(w, "Dry run: nothing will be changed.")
+	var mu sync.Mutex
+	result, err := deployAll(ctx, cfg, p, services, env, tags, previousTags, w, &mu, maxServiceNameLen(services), parallel, false)
+	if err != nil {
+		return err
+	}
+	if len(result.failed) > 0 {
+		return fmt.Errorf("dry run failed for: %v", result.failed)
+	}
+	fmt.Fprintln(w, "Dry run complete.")
+	return nil
+}
 
-func resolveBuildTag(ctx context.Context, bp buildsProvider, value string) (string, error) {
+// planFormatMarkdown selects the Markdown table output for --dry-run.
+const planFormatMarkdown = "markdown"
+
+// formatPlanMarkdown renders the services a deploy would change as a
+// GitHub-flavored Markdown table, for pasting into a PR.
+func formatPlanMarkdown(cfg config, services []string, env string, tags, previousTags map[string]string) string {
+	var b strings.Builder
+	b.WriteString("| Service | Type | Env | Old | New |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, svc := range services {
+		oldTag := previousTags[svc]
+		if oldTag == "" {
+			oldTag = "_none_"
+		} else {
+			oldTag = "`" + oldTag + "`"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | `%s` |\n", svc, cfg.Services[svc].Type, env, oldTag, tags[svc])
+	}
+	return b.String()
+}
+
+func resolveBuildTag(ctx context.Context, bp buildsProvider, value string, blocked map[string]bool, maxBranchLen int) (string, error) {
 	if _, err := parseTag(value); err == nil {
+		if blocked[value] {
+			return "", blockedBuildError(value)
+		}
 		return value, nil
 	}
 
@@ -419,16 +1256,55 @@ func resolveBuildTag(ctx context.Context, bp buildsProvider, value string) (stri
 		return "", fmt.Errorf("listing builds: %w", err)
 	}
 
-	sanitized := sanitizeBranch(value)
+	var skipped string
 	for _, b := range builds {
-		if b.Branch == sanitized || b.Branch == value {
+		if branchMatches(b.Branch, value, maxBranchLen) {
+			if blocked[b.Tag] {
+				skipped = b.Tag
+				continue
+			}
 			return b.Tag, nil
 		}
 	}
 
+	if skipped != "" {
+		return "", fmt.Errorf("no builds found for branch %q that aren't blocked", value)
+	}
 	return "", fmt.Errorf("no builds found for branch %q", value)
 }
 
+// applyPreviousOverrides returns previousTags with the --previous overrides
+// applied, for repairing a rollback chain when history is wrong.
+func applyPreviousOverrides(previousTags map[string]string, services []string, overrides map[string]string) (map[string]string, error) {
+	merged := make(map[string]string, len(previousTags)+len(overrides))
+	for svc, t := range previousTags {
+		merged[svc] = t
+	}
+	for svc, t := range overrides {
+		if !slices.Contains(services, svc) {
+			return nil, fmt.Errorf("--previous %s=%s: %q is not being deployed", svc, t, svc)
+		}
+		if _, err := parseTag(t); err != nil {
+			return nil, fmt.Errorf("--previous %s=%s: %w", svc, t, err)
+		}
+		merged[svc] = t
+	}
+	return merged, nil
+}
+
+// blockedBuilds returns the config's blocked_builds as a set.
+func blockedBuilds(cfg config) map[string]bool {
+	blocked := make(map[string]bool, len(cfg.BlockedBuilds))
+	for _, t := range cfg.BlockedBuilds {
+		blocked[t] = true
+	}
+	return blocked
+}
+
+func blockedBuildError(tag string) error {
+	return fmt.Errorf("build %s is listed in blocked_builds and can't be deployed", tag)
+}
+
 func sortedServiceNames(cfg config) []string {
 	names := make([]string, 0, len(cfg.Services))
 	for name := range cfg.Services {
@@ -464,6 +1340,89 @@ func servicesWithEnv(cfg config, env string) []string {
 	return result
 }
 
+// filterServicesByType returns the names whose service type is typ, or names
+// unchanged when typ is empty.
+func filterServicesByType(cfg config, names []string, typ string) []string {
+	if typ == "" {
+		return names
+	}
+	var result []string
+	for _, name := range names {
+		if cfg.Services[name].Type == typ {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// expandGroup appends the services of the named --group to services, keeping
+// order and dropping duplicates. An empty group returns services unchanged.
+func expandGroup(cfg config, services []string, group string) ([]string, error) {
+	if group == "" {
+		return services, nil
+	}
+	members, ok := cfg.Groups[group]
+	if !ok {
+		return nil, fmt.Errorf("unknown group: %q", group)
+	}
+	seen := make(map[string]bool, len(services)+len(members))
+	var result []string
+	for _, name := range append(append([]string(nil), services...), members...) {
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+// checkServicesExist returns an error naming the first service not in cfg.
+func checkServicesExist(cfg config, names []string) error {
+	for _, name := range names {
+		if _, ok := cfg.Services[name]; !ok {
+			return fmt.Errorf("unknown service: %q", name)
+		}
+	}
+	return nil
+}
+
+// excludeServices returns names without the ones in exclude, keeping order.
+func excludeServices(names, exclude []string) []string {
+	if len(exclude) == 0 {
+		return names
+	}
+	var result []string
+	for _, name := range names {
+		if !slices.Contains(exclude, name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// checkServiceTypes validates a --type value and that every explicitly named
+// service is of that type.
+func checkServiceTypes(cfg config, names []string, typ string) error {
+	switch typ {
+	case "", "server", "static", "cronjob":
+	default:
+		return fmt.Errorf("invalid --type %q (must be \"server\", \"static\", or \"cronjob\")", typ)
+	}
+	if typ == "" {
+		return nil
+	}
+	for _, name := range names {
+		svc, ok := cfg.Services[name]
+		if !ok {
+			return fmt.Errorf("unknown service: %q", name)
+		}
+		if svc.Type != typ {
+			return fmt.Errorf("service %q is %s, not %s", name, svc.Type, typ)
+		}
+	}
+	return nil
+}
+
 func envIntersection(cfg config, services []string) []string {
 	if len(services) == 0 {
 		return nil
@@ -488,10 +1447,24 @@ func envIntersection(cfg config, services []string) []string {
 			result = append(result, env)
 		}
 	}
-	sort.Strings(result)
+	sortEnvs(cfg, result)
 	return result
 }
 
+// sortEnvs sorts envs in the config's env_order, with environments it
+// doesn't list after those it does, by name.
+func sortEnvs(cfg config, envs []string) {
+	rank := func(env string) int {
+		if i := slices.Index(cfg.EnvOrder, env); i >= 0 {
+			return i
+		}
+		return len(cfg.EnvOrder)
+	}
+	slices.SortFunc(envs, func(a, b string) int {
+		return cmp.Or(cmp.Compare(rank(a), rank(b)), strings.Compare(a, b))
+	})
+}
+
 // buildsForServices returns a builds provider for the selected services.
 // When services have different builds providers, it returns a merged provider
 // that intersects results — only builds present in all providers are returned.
@@ -3,11 +3,20 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -128,12 +137,12 @@ func testConfig() config {
 					"staging": {
 						Node:    "web1",
 						Host:    "api.staging.example.com",
-						EnvFile: "/etc/backend/staging.env",
+						EnvFile: stringList{"/etc/backend/staging.env"},
 					},
 					"production": {
 						Node:    "web2",
 						Host:    "api.example.com",
-						EnvFile: "/etc/backend/production.env",
+						EnvFile: stringList{"/etc/backend/production.env"},
 					},
 				},
 			},
@@ -142,11 +151,11 @@ func testConfig() config {
 				Env: map[string]envConfig{
 					"staging": {
 						Bucket:     "frontend-staging",
-						CloudFront: "E1234567890",
+						CloudFront: stringList{"E1234567890"},
 					},
 					"production": {
 						Bucket:     "frontend-prod",
-						CloudFront: "E0987654321",
+						CloudFront: stringList{"E0987654321"},
 					},
 				},
 			},
@@ -158,11 +167,11 @@ func testConfig() config {
 				Env: map[string]envConfig{
 					"staging": {
 						Node:    "web1",
-						EnvFile: "/etc/report/staging.env",
+						EnvFile: stringList{"/etc/report/staging.env"},
 					},
 					"production": {
 						Node:    "web2",
-						EnvFile: "/etc/report/production.env",
+						EnvFile: stringList{"/etc/report/production.env"},
 					},
 				},
 			},
@@ -197,7 +206,7 @@ func testProviders(builds []build, deploys map[string]deploy) (providers, *mockD
 func testDeployAll(ctx context.Context, cfg config, p providers, services []string, env string, tags, previousTags map[string]string) (deployResult, error) {
 	var mu sync.Mutex
 	padLen := maxServiceNameLen(services)
-	return deployAll(ctx, cfg, p, services, env, tags, previousTags, io.Discard, &mu, padLen)
+	return deployAll(ctx, cfg, p, services, env, tags, previousTags, io.Discard, &mu, padLen, 0, false)
 }
 
 func TestDeployAllHappyPath(t *testing.T) {
@@ -353,7 +362,7 @@ func TestResolveBuildTagFullTag(t *testing.T) {
 	bp := &mockBuildsProvider{}
 	tag := "main-abc1234-20250101000000"
 
-	result, err := resolveBuildTag(context.Background(), bp, tag)
+	result, err := resolveBuildTag(context.Background(), bp, tag, nil, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -370,7 +379,7 @@ func TestResolveBuildTagBranchName(t *testing.T) {
 	}
 	bp := &mockBuildsProvider{builds: builds}
 
-	result, err := resolveBuildTag(context.Background(), bp, "feat-xyz")
+	result, err := resolveBuildTag(context.Background(), bp, "feat-xyz", nil, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -379,12 +388,29 @@ func TestResolveBuildTagBranchName(t *testing.T) {
 	}
 }
 
+func TestResolveBuildTagLegacyTruncatedBranch(t *testing.T) {
+	branch := "feature/JIRA-1234-rework-the-billing-pipeline-for-invoices"
+	// Tagged before truncated branch names got a hash: cut at 40 characters.
+	legacy := "feature-JIRA-1234-rework-the-billing-pip"
+	bp := &mockBuildsProvider{builds: []build{
+		{Tag: legacy + "-abc1234-20250101000000", Branch: legacy},
+	}}
+
+	result, err := resolveBuildTag(context.Background(), bp, branch, nil, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != legacy+"-abc1234-20250101000000" {
+		t.Fatalf("expected the legacy build, got %s", result)
+	}
+}
+
 func TestResolveBuildTagUnknownBranch(t *testing.T) {
 	bp := &mockBuildsProvider{builds: []build{
 		{Tag: "main-abc1234-20250101000000", Branch: "main"},
 	}}
 
-	_, err := resolveBuildTag(context.Background(), bp, "nonexistent")
+	_, err := resolveBuildTag(context.Background(), bp, "nonexistent", nil, 0)
 	if err == nil {
 		t.Fatal("expected error for unknown branch")
 	}
@@ -393,6 +419,422 @@ func TestResolveBuildTagUnknownBranch(t *testing.T) {
 	}
 }
 
+func TestResolveBuildTagBlocked(t *testing.T) {
+	builds := []build{
+		{Tag: "main-abc1234-20250102000000", Branch: "main"},
+		{Tag: "main-def5678-20250101000000", Branch: "main"},
+	}
+	bp := &mockBuildsProvider{builds: builds}
+	blocked := map[string]bool{"main-abc1234-20250102000000": true}
+
+	_, err := resolveBuildTag(context.Background(), bp, "main-abc1234-20250102000000", blocked, 0)
+	if err == nil || !strings.Contains(err.Error(), "blocked_builds") {
+		t.Errorf("expected blocked build error, got: %v", err)
+	}
+
+	// A branch resolves to its newest build that isn't blocked.
+	result, err := resolveBuildTag(context.Background(), bp, "main", blocked, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "main-def5678-20250101000000" {
+		t.Errorf("expected older unblocked build, got %s", result)
+	}
+}
+
+func TestRunDeployRefusesBlockedBuild(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	cfg.BlockedBuilds = []string{tag}
+	p, md := testProviders(nil, nil)
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend"},
+		Env:      "staging",
+		Tags:     map[string]string{"backend": tag},
+		Yes:      true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "blocked_builds") {
+		t.Fatalf("expected blocked build error, got: %v", err)
+	}
+	if len(md.calls) != 0 {
+		t.Errorf("expected no deploys, got %d", len(md.calls))
+	}
+}
+
+func TestRunDeployPreviousOverride(t *testing.T) {
+	cfg := testConfig()
+	p, md := testProviders(nil, map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "main-aaaaaaa-20250101000000"},
+	})
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend"},
+		Env:      "staging",
+		Tags:     map[string]string{"backend": "main-ccccccc-20250103000000"},
+		Previous: map[string]string{"backend": "main-bbbbbbb-20250102000000"},
+		Yes:      true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 1 || md.calls[0].oldTag != "main-bbbbbbb-20250102000000" {
+		t.Errorf("expected oldTag from --previous, got %+v", md.calls)
+	}
+}
+
+func TestRunDeployPreviousOverrideRollsBackToLive(t *testing.T) {
+	cfg := testConfig()
+	p, md := testProviders(nil, map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "main-aaaaaaa-20250101000000"},
+	})
+	md.errors = map[string]error{"backend": fmt.Errorf("unhealthy")}
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services:  []string{"backend"},
+		Env:       "staging",
+		Tags:      map[string]string{"backend": "main-ccccccc-20250103000000"},
+		Previous:  map[string]string{"backend": "main-bbbbbbb-20250102000000"},
+		Yes:       true,
+		OnFailure: rollbackPolicyAuto,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	// The override is only recorded on the new deploy; the rollback goes to
+	// what was actually live.
+	if len(md.calls) != 2 || md.calls[0].oldTag != "main-bbbbbbb-20250102000000" || md.calls[1].tag != "main-aaaaaaa-20250101000000" {
+		t.Errorf("expected deploy recording the override, then rollback to the live tag, got %+v", md.calls)
+	}
+}
+
+func TestDowngradedServices(t *testing.T) {
+	live := map[string]string{
+		"backend":  "main-bbbbbbb-20250102000000",
+		"frontend": "main-bbbbbbb-20250102000000",
+		"report":   "latest",
+	}
+	tags := map[string]string{
+		"backend":  "main-aaaaaaa-20250101000000",
+		"frontend": "main-ccccccc-20250103000000",
+		"report":   "main-aaaaaaa-20250101000000",
+		"worker":   "main-aaaaaaa-20250101000000",
+	}
+	got := downgradedServices([]string{"backend", "frontend", "report", "worker"}, tags, live)
+	want := []serviceChange{{service: "backend", oldTag: "main-bbbbbbb-20250102000000", newTag: "main-aaaaaaa-20250101000000"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("downgraded = %+v, want %+v", got, want)
+	}
+}
+
+func TestRunDeployRefusesDowngrade(t *testing.T) {
+	cfg := testConfig()
+	p, md := testProviders(nil, map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "main-bbbbbbb-20250102000000"},
+	})
+	opts := deployOpts{
+		Services: []string{"backend"},
+		Env:      "staging",
+		Tags:     map[string]string{"backend": "main-aaaaaaa-20250101000000"},
+		Yes:      true,
+	}
+
+	err := runDeploy(context.Background(), cfg, p, opts)
+	if err == nil || !strings.Contains(err.Error(), "--allow-downgrade") {
+		t.Fatalf("expected downgrade error, got: %v", err)
+	}
+	if len(md.calls) != 0 {
+		t.Errorf("expected no deploys, got %d", len(md.calls))
+	}
+
+	opts.Downgrade = true
+	if err := runDeploy(context.Background(), cfg, p, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 1 {
+		t.Errorf("expected 1 deploy with --allow-downgrade, got %d", len(md.calls))
+	}
+}
+
+func TestRunDeployWritesToOutput(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "main-bbbbbbb-20250102000000"},
+	})
+	var out bytes.Buffer
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend"},
+		Env:      "staging",
+		Tags:     map[string]string{"backend": "main-aaaaaaa-20250101000000"},
+		Yes:      true,
+		ShowCmds: true,
+		Output:   &out,
+	})
+	if err == nil {
+		t.Fatal("expected downgrade error")
+	}
+	for _, want := range []string{"backend: docker run ", "This is a DOWNGRADE"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunDeployShowCommandsRedactsSecrets(t *testing.T) {
+	cfg := testConfig()
+	svc := cfg.Services["backend"]
+	svc.Command = "serve --password=hunter2"
+	cfg.Services["backend"] = svc
+	p, _ := testProviders(nil, nil)
+
+	var out bytes.Buffer
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services:  []string{"backend"},
+		Env:       "staging",
+		Tags:      map[string]string{"backend": "main-abc1234-20250101000000"},
+		Yes:       true,
+		ShowCmds:  true,
+		OnFailure: rollbackPolicyNever,
+		Output:    &out,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), "--password=***") {
+		t.Errorf("expected the password masked:\n%s", out.String())
+	}
+}
+
+func TestDeployMaxParallelAlias(t *testing.T) {
+	cmd := newRootCmd()
+	if err := cmd.ParseFlags([]string{"--max-parallel", "3"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, _ := cmd.Flags().GetInt("parallel"); n != 3 {
+		t.Errorf("parallel = %d, want 3 from --max-parallel", n)
+	}
+}
+
+func TestRunDeployAllEnvs(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	p, md := testProviders([]build{{Tag: tag, Branch: "main", SHA: "abc1234"}}, nil)
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services:  []string{"backend"},
+		Build:     "main",
+		AllEnvs:   true,
+		Yes:       true,
+		OnFailure: rollbackPolicyNever,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, c := range md.calls {
+		if c.tag != tag {
+			t.Errorf("%s/%s deployed %s, want %s", c.service, c.env, c.tag, tag)
+		}
+		got = append(got, c.service+"/"+c.env)
+	}
+	if strings.Join(got, ",") != "backend/production,backend/staging" {
+		t.Errorf("deploys = %v, want backend to production then staging", got)
+	}
+}
+
+func TestRunDeployAllEnvsEnvOrder(t *testing.T) {
+	cfg := testConfig()
+	cfg.EnvOrder = []string{"staging", "production"}
+	tag := "main-abc1234-20250101000000"
+	p, md := testProviders([]build{{Tag: tag, Branch: "main", SHA: "abc1234"}}, nil)
+	resultFile := filepath.Join(t.TempDir(), "result.json")
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services:   []string{"backend"},
+		Build:      "main",
+		AllEnvs:    true,
+		Yes:        true,
+		OnFailure:  rollbackPolicyNever,
+		ResultFile: resultFile,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, c := range md.calls {
+		got = append(got, c.service+"/"+c.env)
+	}
+	if strings.Join(got, ",") != "backend/staging,backend/production" {
+		t.Errorf("deploys = %v, want backend to staging then production", got)
+	}
+	for _, env := range []string{"staging", "production"} {
+		data, err := os.ReadFile(envResultFile(resultFile, env))
+		if err != nil {
+			t.Fatalf("reading %s result: %v", env, err)
+		}
+		if !strings.Contains(string(data), `"env": "`+env+`"`) {
+			t.Errorf("%s result is for another environment:\n%s", env, data)
+		}
+	}
+}
+
+func TestRunDeployAllEnvsStopsOnFailure(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	p, md := testProviders([]build{{Tag: tag, Branch: "main", SHA: "abc1234"}}, nil)
+	md.errors = map[string]error{"backend": fmt.Errorf("connection refused")}
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services:  []string{"backend"},
+		Build:     tag,
+		AllEnvs:   true,
+		Yes:       true,
+		OnFailure: rollbackPolicyNever,
+	})
+	if !errors.Is(err, errDeployFailed) || !strings.HasPrefix(err.Error(), "production: ") {
+		t.Fatalf("expected production to fail, got: %v", err)
+	}
+	if len(md.calls) != 1 {
+		t.Errorf("expected staging not to be attempted, got %+v", md.calls)
+	}
+}
+
+func TestRunDeployAllEnvsContinue(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	p, md := testProviders([]build{{Tag: tag, Branch: "main", SHA: "abc1234"}}, nil)
+	md.errors = map[string]error{"backend": fmt.Errorf("connection refused")}
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services:  []string{"backend"},
+		Build:     tag,
+		AllEnvs:   true,
+		Continue:  true,
+		Yes:       true,
+		OnFailure: rollbackPolicyNever,
+	})
+	if !errors.Is(err, errDeployFailed) {
+		t.Fatalf("expected deploy failure, got: %v", err)
+	}
+	for _, env := range []string{"production", "staging"} {
+		if !strings.Contains(err.Error(), env+": ") {
+			t.Errorf("error should report %s: %v", env, err)
+		}
+	}
+	if len(md.calls) != 2 {
+		t.Errorf("expected both environments to be attempted, got %+v", md.calls)
+	}
+}
+
+func TestRunDeployAllEnvsParallel(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	p, md := testProviders([]build{{Tag: tag, Branch: "main", SHA: "abc1234"}}, nil)
+	md.delay = 50 * time.Millisecond
+
+	start := time.Now()
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services:     []string{"backend"},
+		Build:        tag,
+		AllEnvs:      true,
+		ParallelEnvs: true,
+		Yes:          true,
+		OnFailure:    rollbackPolicyNever,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 2 {
+		t.Errorf("expected a deploy per environment, got %+v", md.calls)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("environments should deploy at once, took %s", elapsed)
+	}
+}
+
+func TestRunDeployAllEnvsRefusesDowngradeUpFront(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-aaaaaaa-20250101000000"
+	p, md := testProviders([]build{{Tag: tag, Branch: "main", SHA: "aaaaaaa"}}, map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "main-bbbbbbb-20250102000000"},
+	})
+	opts := deployOpts{
+		Services:  []string{"backend"},
+		Build:     tag,
+		AllEnvs:   true,
+		Yes:       true,
+		OnFailure: rollbackPolicyNever,
+	}
+
+	err := runDeploy(context.Background(), cfg, p, opts)
+	if err == nil || !strings.Contains(err.Error(), "staging backend") || !strings.Contains(err.Error(), "--allow-downgrade") {
+		t.Fatalf("expected downgrade refusal naming staging, got: %v", err)
+	}
+	if len(md.calls) != 0 {
+		t.Fatalf("expected no environment deployed, got %+v", md.calls)
+	}
+
+	opts.Downgrade = true
+	if err := runDeploy(context.Background(), cfg, p, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 2 {
+		t.Errorf("expected both environments deployed, got %+v", md.calls)
+	}
+}
+
+func TestRunDeployAllEnvsFlags(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, nil)
+	tests := []struct {
+		name    string
+		opts    deployOpts
+		wantErr string
+	}{
+		{"with env", deployOpts{Services: []string{"backend"}, Env: "staging", Build: "main"}, "mutually exclusive"},
+		{"without build", deployOpts{Services: []string{"backend"}}, "requires --build"},
+		{"without services", deployOpts{Build: "main"}, "requires -s"},
+		{"unknown exclude", deployOpts{Services: []string{"backend"}, Exclude: []string{"nope"}, Build: "main"}, "--exclude"},
+		{"wrong type", deployOpts{Services: []string{"backend"}, Type: "static", Build: "main"}, "not static"},
+		{"parallel with rollback prompt", deployOpts{Services: []string{"backend"}, Build: "main", ParallelEnvs: true}, "--rollback auto or never"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.AllEnvs = true
+			tt.opts.Yes = true
+			err := runDeploy(context.Background(), cfg, p, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunDeployEnvFlagsRequireAllEnvs(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, nil)
+	for _, opts := range []deployOpts{{ParallelEnvs: true}, {Continue: true}} {
+		opts.Services = []string{"backend"}
+		opts.Env = "staging"
+		err := runDeploy(context.Background(), cfg, p, opts)
+		if err == nil || !strings.Contains(err.Error(), "require --all-envs") {
+			t.Errorf("error = %v, want --all-envs required", err)
+		}
+	}
+}
+
+func TestApplyPreviousOverridesErrors(t *testing.T) {
+	_, err := applyPreviousOverrides(nil, []string{"backend"}, map[string]string{"frontend": "main-abc1234-20250101000000"})
+	if err == nil || !strings.Contains(err.Error(), `"frontend" is not being deployed`) {
+		t.Errorf("expected not-deployed error, got: %v", err)
+	}
+
+	_, err = applyPreviousOverrides(nil, []string{"backend"}, map[string]string{"backend": "latest"})
+	if err == nil || !strings.Contains(err.Error(), "--previous backend=latest") {
+		t.Errorf("expected invalid tag error, got: %v", err)
+	}
+}
+
 func TestAllEnvironments(t *testing.T) {
 	cfg := testConfig()
 	envs := allEnvironments(cfg)
@@ -834,6 +1276,32 @@ func TestNewServiceLogf(t *testing.T) {
 	}
 }
 
+func TestNewServiceLogfWithElapsed(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	logf := withElapsed(newServiceLogf(&buf, &mu, "backend", 8), time.Now().Add(-3200*time.Millisecond))
+	logf("pulling %s", "image:tag")
+
+	line := strings.TrimRight(buf.String(), "\n")
+	if !regexp.MustCompile(`^\[backend \] \+3\.\ds pulling image:tag$`).MatchString(line) {
+		t.Errorf("unexpected line: %q", line)
+	}
+}
+
+func TestNewServiceLogfRedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	logf := newServiceLogf(&buf, &mu, "backend", 7)
+	logf("$ %s", "docker login -u AWS -p hunter2 registry.example.com")
+
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("secret leaked into log: %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "-p ***") {
+		t.Errorf("expected masked password, got %q", buf.String())
+	}
+}
+
 func TestNewServiceLogfConcurrent(t *testing.T) {
 	var buf bytes.Buffer
 	var mu sync.Mutex
@@ -908,7 +1376,7 @@ func TestDeployAllLogOutput(t *testing.T) {
 	tag := "main-abc1234-20250101000000"
 	tags := map[string]string{"backend": tag, "frontend": tag}
 
-	_, err := deployAll(context.Background(), cfg, p, []string{"backend", "frontend"}, "staging", tags, nil, &buf, &mu, 8)
+	_, err := deployAll(context.Background(), cfg, p, []string{"backend", "frontend"}, "staging", tags, nil, &buf, &mu, 8, 0, false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -925,6 +1393,36 @@ func TestDeployAllLogOutput(t *testing.T) {
 	}
 }
 
+func TestDeployAllProgress(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, nil)
+	tag := "main-abc1234-20250101000000"
+	tags := map[string]string{"backend": tag, "frontend": tag, "report": tag}
+
+	for _, parallel := range []int{0, 1} {
+		var buf bytes.Buffer
+		var mu sync.Mutex
+		if _, err := deployAll(context.Background(), cfg, p, []string{"backend", "frontend", "report"}, "staging", tags, nil, &buf, &mu, 8, parallel, false); err != nil {
+			t.Fatalf("parallel=%d: unexpected error: %v", parallel, err)
+		}
+		for i := 1; i <= 3; i++ {
+			if want := fmt.Sprintf("(%d/3 services complete)\n", i); !strings.Contains(buf.String(), want) {
+				t.Errorf("parallel=%d: expected %q in output, got:\n%s", parallel, want, buf.String())
+			}
+		}
+	}
+
+	// A single service needs no count.
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	if _, err := deployAll(context.Background(), cfg, p, []string{"backend"}, "staging", tags, nil, &buf, &mu, 8, 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(buf.String(), "services complete") {
+		t.Errorf("expected no progress for one service, got:\n%s", buf.String())
+	}
+}
+
 func TestDeployAllErrorsMap(t *testing.T) {
 	cfg := testConfig()
 	p, md := testProviders(nil, nil)
@@ -952,3 +1450,660 @@ func TestDeployAllErrorsMap(t *testing.T) {
 		t.Error("expected no error for frontend")
 	}
 }
+
+func TestRunDeploySingleBuildSkipsPicker(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	builds := []build{{Tag: tag, Branch: "main", SHA: "abc1234"}}
+	deploys := map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "main-old1234-20241231000000"},
+	}
+	p, md := testProviders(builds, deploys)
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend"},
+		Env:      "staging",
+		Yes:      true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(md.calls) != 1 {
+		t.Fatalf("expected 1 deploy call, got %d", len(md.calls))
+	}
+	if md.calls[0].tag != tag {
+		t.Errorf("tag = %q, want %q", md.calls[0].tag, tag)
+	}
+	if md.calls[0].oldTag != "main-old1234-20241231000000" {
+		t.Errorf("oldTag = %q, want previous live tag", md.calls[0].oldTag)
+	}
+}
+
+func TestFilterServicesByType(t *testing.T) {
+	cfg := testConfig()
+	names := sortedServiceNames(cfg)
+
+	if got := filterServicesByType(cfg, names, "static"); strings.Join(got, ",") != "frontend" {
+		t.Errorf("static = %v, want [frontend]", got)
+	}
+	if got := filterServicesByType(cfg, names, ""); strings.Join(got, ",") != strings.Join(names, ",") {
+		t.Errorf("empty type = %v, want all services %v", got, names)
+	}
+}
+
+func TestCheckServiceTypes(t *testing.T) {
+	cfg := testConfig()
+	tests := []struct {
+		name    string
+		names   []string
+		typ     string
+		wantErr string
+	}{
+		{"no filter", []string{"backend", "frontend"}, "", ""},
+		{"matching", []string{"backend"}, "server", ""},
+		{"mismatch", []string{"frontend"}, "server", `service "frontend" is static, not server`},
+		{"invalid type", nil, "lambda", `invalid --type "lambda"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkServiceTypes(cfg, tt.names, tt.typ)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExpandGroup(t *testing.T) {
+	cfg := testConfig()
+	cfg.Groups = map[string][]string{"web": {"frontend", "backend"}}
+
+	got, err := expandGroup(cfg, []string{"backend", "report"}, "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, ",") != "backend,report,frontend" {
+		t.Errorf("got %v, want [backend report frontend]", got)
+	}
+
+	if got, _ := expandGroup(cfg, []string{"report"}, ""); strings.Join(got, ",") != "report" {
+		t.Errorf("empty group = %v, want [report]", got)
+	}
+
+	if _, err := expandGroup(cfg, nil, "api"); err == nil || !strings.Contains(err.Error(), `unknown group: "api"`) {
+		t.Errorf("expected unknown group error, got: %v", err)
+	}
+}
+
+func TestExcludeServices(t *testing.T) {
+	got := excludeServices([]string{"backend", "frontend", "report"}, []string{"frontend"})
+	if strings.Join(got, ",") != "backend,report" {
+		t.Errorf("got %v, want [backend report]", got)
+	}
+	if got := excludeServices([]string{"backend"}, nil); strings.Join(got, ",") != "backend" {
+		t.Errorf("no exclusions = %v, want [backend]", got)
+	}
+}
+
+func TestRunDeployExclude(t *testing.T) {
+	cfg := testConfig()
+	p, md := testProviders(nil, nil)
+	tag := "main-abc1234-20250101000000"
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend", "report"},
+		Exclude:  []string{"report"},
+		Env:      "staging",
+		Tags:     map[string]string{"backend": tag, "report": tag},
+		Yes:      true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 1 || md.calls[0].service != "backend" {
+		t.Errorf("expected only backend deployed, got %+v", md.calls)
+	}
+
+	err = runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend"},
+		Exclude:  []string{"nope"},
+		Env:      "staging",
+		Yes:      true,
+	})
+	if err == nil || !strings.Contains(err.Error(), `--exclude: unknown service: "nope"`) {
+		t.Errorf("expected unknown excluded service error, got: %v", err)
+	}
+}
+
+func TestNewProvidersSetsRegionOnConfig(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-3")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "none"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "none"))
+
+	cfg := testConfig()
+	cfg.Region = ""
+	p, err := newProviders(context.Background(), &cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer p.close()
+	if cfg.Region != "eu-west-3" {
+		t.Errorf("cfg.Region = %q, want the AWS config's region", cfg.Region)
+	}
+	if cmd := renderDeployCommand(cfg, "backend", "staging", "main-abc1234-20250101000000", ""); !strings.Contains(cmd, "awslogs-region=eu-west-3") {
+		t.Errorf("rendered command should name the AWS region, got: %s", cmd)
+	}
+}
+
+func TestRenderDeployCommand(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+
+	server := renderDeployCommand(cfg, "backend", "staging", tag, "main-old1234-20241231000000")
+	if !strings.HasPrefix(server, "docker run ") {
+		t.Errorf("server command = %q, want docker run", server)
+	}
+	if !strings.Contains(server, "'hoist.previous=main-old1234-20241231000000'") {
+		t.Errorf("server command should carry the previous-tag label, got %q", server)
+	}
+
+	cron := renderDeployCommand(cfg, "report", "staging", tag, "")
+	if !strings.Contains(cron, "docker run") || !strings.Contains(cron, tag) {
+		t.Errorf("cronjob command = %q, want crontab line for %s", cron, tag)
+	}
+
+	if got := renderDeployCommand(cfg, "frontend", "staging", tag, ""); got != "" {
+		t.Errorf("static command = %q, want empty", got)
+	}
+}
+
+func TestRunDeployResolvesOnlyEnvOfService(t *testing.T) {
+	cfg := testConfig()
+	delete(cfg.Services["backend"].Env, "production")
+	tag := "main-abc1234-20250101000000"
+	p, md := testProviders([]build{{Tag: tag, Branch: "main", SHA: "abc1234"}}, nil)
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend"},
+		Build:    tag,
+		Yes:      true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 1 || md.calls[0].env != "staging" {
+		t.Fatalf("calls = %+v, want one deploy to staging", md.calls)
+	}
+}
+
+func TestRunDeployNonInteractiveNeedsEnvWhenAmbiguous(t *testing.T) {
+	cfg := testConfig()
+	p, md := testProviders(nil, nil)
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend"},
+		Build:    "main-abc1234-20250101000000",
+		Yes:      true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "use -e") {
+		t.Fatalf("expected error asking for -e, got: %v", err)
+	}
+	if len(md.calls) != 0 {
+		t.Errorf("expected no deploys, got %d", len(md.calls))
+	}
+}
+
+// peakDeployer records the highest number of deploys running at once.
+type peakDeployer struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (d *peakDeployer) deploy(_ context.Context, _, _, _, _ string, logf func(string, ...any)) error {
+	d.mu.Lock()
+	d.inFlight++
+	d.peak = max(d.peak, d.inFlight)
+	d.mu.Unlock()
+
+	logf("working")
+	time.Sleep(20 * time.Millisecond)
+
+	d.mu.Lock()
+	d.inFlight--
+	d.mu.Unlock()
+	return nil
+}
+
+func TestDeployAllParallelLimit(t *testing.T) {
+	cfg := testConfig()
+	pd := &peakDeployer{}
+	p := providers{deployers: map[string]deployer{"server": pd, "static": pd, "cronjob": pd}}
+	services := []string{"backend", "frontend", "report"}
+	tag := "main-abc1234-20250101000000"
+	tags := map[string]string{"backend": tag, "frontend": tag, "report": tag}
+
+	var mu sync.Mutex
+	result, err := deployAll(context.Background(), cfg, p, services, "staging", tags, nil, io.Discard, &mu, 8, 2, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.failed) != 0 {
+		t.Fatalf("expected no failures, got %v", result.failed)
+	}
+	if pd.peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", pd.peak)
+	}
+}
+
+func TestDeployAllSerializesConflicts(t *testing.T) {
+	cfg := testConfig()
+	backend := cfg.Services["backend"]
+	backend.ConflictsWith = []string{"report"}
+	cfg.Services["backend"] = backend
+
+	pd := &peakDeployer{}
+	p := providers{deployers: map[string]deployer{"server": pd, "static": pd, "cronjob": pd}}
+	services := []string{"backend", "report"}
+	tag := "main-abc1234-20250101000000"
+	tags := map[string]string{"backend": tag, "report": tag}
+
+	var mu sync.Mutex
+	if _, err := deployAll(context.Background(), cfg, p, services, "staging", tags, nil, io.Discard, &mu, 7, 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pd.peak != 1 {
+		t.Errorf("peak concurrency = %d, want 1", pd.peak)
+	}
+}
+
+func TestConflictLocks(t *testing.T) {
+	cfg := testConfig()
+	report := cfg.Services["report"]
+	report.ConflictsWith = []string{"backend"}
+	cfg.Services["report"] = report
+
+	locks := conflictLocks(cfg, []string{"backend", "frontend", "report"})
+	if locks["backend"] != locks["report"] {
+		t.Error("expected backend and report to share a lock")
+	}
+	if locks["frontend"] == locks["backend"] {
+		t.Error("expected frontend to have its own lock")
+	}
+
+	// A conflict with a service outside this deploy doesn't link anything.
+	locks = conflictLocks(cfg, []string{"report", "frontend"})
+	if locks["report"] == locks["frontend"] {
+		t.Error("expected report and frontend to have separate locks")
+	}
+}
+
+func dependsOnConfig() config {
+	cfg := testConfig()
+	frontend := cfg.Services["frontend"]
+	frontend.DependsOn = []string{"backend"}
+	cfg.Services["frontend"] = frontend
+	return cfg
+}
+
+func TestDependencyOrder(t *testing.T) {
+	cfg := dependsOnConfig()
+	got := dependencyOrder(cfg, []string{"frontend", "report", "backend"})
+	if strings.Join(got, ",") != "report,backend,frontend" {
+		t.Errorf("order = %v, want [report backend frontend]", got)
+	}
+	// A dependency outside the deploy doesn't hold anything back.
+	got = dependencyOrder(cfg, []string{"frontend", "report"})
+	if strings.Join(got, ",") != "frontend,report" {
+		t.Errorf("order = %v, want [frontend report]", got)
+	}
+	// Repeats must not keep it looking for services to place.
+	got = dependencyOrder(cfg, []string{"backend", "backend"})
+	if strings.Join(got, ",") != "backend" {
+		t.Errorf("order = %v, want [backend]", got)
+	}
+}
+
+func TestDeployAllDependsOn(t *testing.T) {
+	cfg := dependsOnConfig()
+	md := &mockDeployer{delay: 20 * time.Millisecond}
+	p := providers{deployers: map[string]deployer{"server": md, "static": md, "cronjob": md}}
+	tag := "main-abc1234-20250101000000"
+	tags := map[string]string{"backend": tag, "frontend": tag}
+
+	var mu sync.Mutex
+	result, err := deployAll(context.Background(), cfg, p, []string{"frontend", "backend"}, "staging", tags, nil, io.Discard, &mu, 8, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.failed) != 0 || len(result.skipped) != 0 {
+		t.Fatalf("expected no failures, got failed=%v skipped=%v", result.failed, result.skipped)
+	}
+	if len(md.calls) != 2 || md.calls[0].service != "backend" || md.calls[1].service != "frontend" {
+		t.Errorf("expected backend before frontend, got %+v", md.calls)
+	}
+}
+
+func TestDeployAllDuplicateServices(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	tags := map[string]string{"backend": tag}
+
+	for _, parallel := range []int{0, 1} {
+		p, md := testProviders(nil, nil)
+		var mu sync.Mutex
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			result, err := deployAll(context.Background(), cfg, p, []string{"backend", "backend"}, "staging", tags, nil, io.Discard, &mu, 8, parallel, false)
+			if err != nil || len(result.failed) != 0 {
+				t.Errorf("parallel=%d: err=%v failed=%v", parallel, err, result.failed)
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("parallel=%d: deployAll did not return", parallel)
+		}
+		if len(md.calls) != 1 {
+			t.Errorf("parallel=%d: expected one deploy of backend, got %+v", parallel, md.calls)
+		}
+	}
+}
+
+func TestDeployAllSkipsDependentsOfFailure(t *testing.T) {
+	cfg := dependsOnConfig()
+	md := &mockDeployer{errors: map[string]error{"backend": fmt.Errorf("unhealthy")}}
+	p := providers{deployers: map[string]deployer{"server": md, "static": md, "cronjob": md}}
+	tag := "main-abc1234-20250101000000"
+	tags := map[string]string{"backend": tag, "frontend": tag, "report": tag}
+
+	for _, parallel := range []int{0, 1} {
+		md.calls = nil
+		var mu sync.Mutex
+		result, err := deployAll(context.Background(), cfg, p, []string{"frontend", "backend", "report"}, "staging", tags, nil, io.Discard, &mu, 8, parallel, false)
+		if err != nil {
+			t.Fatalf("parallel=%d: unexpected error: %v", parallel, err)
+		}
+		if strings.Join(result.failed, ",") != "backend" || strings.Join(result.skipped, ",") != "frontend" {
+			t.Errorf("parallel=%d: failed=%v skipped=%v, want [backend] [frontend]", parallel, result.failed, result.skipped)
+		}
+		if err := result.errors["frontend"]; err == nil || !strings.Contains(err.Error(), "dependency backend") {
+			t.Errorf("parallel=%d: frontend error = %v, want dependency backend", parallel, err)
+		}
+		for _, c := range md.calls {
+			if c.service == "frontend" {
+				t.Errorf("parallel=%d: frontend was attempted", parallel)
+			}
+		}
+		if len(md.calls) != 2 {
+			t.Errorf("parallel=%d: expected backend and report attempted, got %+v", parallel, md.calls)
+		}
+	}
+}
+
+// flakyDeployer fails with err on its first failures calls.
+type flakyDeployer struct {
+	failures int
+	err      error
+	calls    int
+	onCall   func()
+}
+
+func (f *flakyDeployer) deploy(ctx context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error {
+	f.calls++
+	if f.onCall != nil {
+		f.onCall()
+	}
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func shortRetryBackoff(t *testing.T) {
+	old := deployRetryBackoff
+	deployRetryBackoff = time.Millisecond
+	t.Cleanup(func() { deployRetryBackoff = old })
+}
+
+func TestDeployServiceRetriesTransient(t *testing.T) {
+	shortRetryBackoff(t)
+	cfg := testConfig()
+	cfg.Retries = 2
+	fd := &flakyDeployer{failures: 2, err: transientError{fmt.Errorf("pulling image: connection reset")}}
+	p := providers{deployers: map[string]deployer{"server": fd}}
+
+	var logs []string
+	logf := func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	if err := deployService(context.Background(), cfg, p, "backend", "staging", "main-abc1234-20250101000000", "", logf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fd.calls != 3 {
+		t.Errorf("calls = %d, want 3", fd.calls)
+	}
+	if len(logs) != 2 || !strings.HasPrefix(logs[0], "attempt 1 of 3 failed (pulling image: connection reset), retrying in") {
+		t.Errorf("unexpected retry logs: %q", logs)
+	}
+
+	// Out of retries, the last error is returned.
+	fd = &flakyDeployer{failures: 3, err: transientError{fmt.Errorf("connecting to 10.0.0.1: timeout")}}
+	p.deployers["server"] = fd
+	if err := deployService(context.Background(), cfg, p, "backend", "staging", "main-abc1234-20250101000000", "", nopLogf); err == nil {
+		t.Fatal("expected error after retries ran out")
+	}
+	if fd.calls != 3 {
+		t.Errorf("calls = %d, want 3", fd.calls)
+	}
+}
+
+func TestDeployServiceNoRetry(t *testing.T) {
+	shortRetryBackoff(t)
+	cfg := testConfig()
+	cfg.Retries = 3
+
+	// Healthcheck failures aren't transient.
+	fd := &flakyDeployer{failures: 1, err: fmt.Errorf("healthcheck failed after 120s")}
+	p := providers{deployers: map[string]deployer{"server": fd}}
+	if err := deployService(context.Background(), cfg, p, "backend", "staging", "main-abc1234-20250101000000", "", nopLogf); err == nil {
+		t.Fatal("expected error")
+	}
+	if fd.calls != 1 {
+		t.Errorf("calls = %d, want 1", fd.calls)
+	}
+
+	// Cancelling stops retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	fd = &flakyDeployer{failures: 3, err: transientError{fmt.Errorf("pulling image: EOF")}, onCall: cancel}
+	p.deployers["server"] = fd
+	if err := deployService(ctx, cfg, p, "backend", "staging", "main-abc1234-20250101000000", "", nopLogf); err == nil {
+		t.Fatal("expected error")
+	}
+	if fd.calls != 1 {
+		t.Errorf("calls after cancel = %d, want 1", fd.calls)
+	}
+}
+
+func TestIsTransientDeployError(t *testing.T) {
+	pull := transientError{fmt.Errorf("pulling image: EOF")}
+	health := fmt.Errorf("healthcheck failed")
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{pull, true},
+		{fmt.Errorf("web1: %w", pull), true},
+		{health, false},
+		{fmt.Errorf("deploy failed on web1, web2: %w", errors.Join(fmt.Errorf("web1: %w", pull), fmt.Errorf("web2: %w", pull))), true},
+		{fmt.Errorf("deploy failed on web1, web2: %w", errors.Join(pull, health)), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientDeployError(tt.err); got != tt.want {
+			t.Errorf("isTransientDeployError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDialAndPullFailedTransient(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	agent := &net.OpError{Op: "dial", Net: "unix", Err: errors.New("no such file or directory")}
+	dials := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("SSH dial 10.0.0.1:22: %w", refused), true},
+		{&hostKeyError{host: "10.0.0.1", reason: "key is revoked"}, false},
+		{fmt.Errorf("SSH_AUTH_SOCK not set"), false},
+		{fmt.Errorf("connecting to SSH agent: %w", agent), false},
+	}
+	for _, tt := range dials {
+		if got := isTransientDeployError(dialFailed("10.0.0.1", tt.err)); got != tt.want {
+			t.Errorf("dialFailed(%v) transient = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+
+	pulls := []struct {
+		err  error
+		want bool
+	}{
+		{io.EOF, true},
+		{fmt.Errorf("running \"docker pull\": exit 1\nError response from daemon: Get \"https://registry/v2/\": net/http: TLS handshake timeout"), true},
+		{fmt.Errorf("running \"docker pull\": exit 1\nError response from daemon: manifest unknown"), false},
+		{fmt.Errorf("pull access denied"), false},
+	}
+	for _, tt := range pulls {
+		if got := isTransientDeployError(pullFailed(tt.err)); got != tt.want {
+			t.Errorf("pullFailed(%v) transient = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDeployAllSequential(t *testing.T) {
+	cfg := testConfig()
+	pd := &peakDeployer{}
+	p := providers{deployers: map[string]deployer{"server": pd, "static": pd, "cronjob": pd}}
+	services := []string{"report", "backend"}
+	tag := "main-abc1234-20250101000000"
+	tags := map[string]string{"backend": tag, "report": tag}
+
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	if _, err := deployAll(context.Background(), cfg, p, services, "staging", tags, nil, &buf, &mu, 7, 1, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pd.peak != 1 {
+		t.Errorf("peak concurrency = %d, want 1", pd.peak)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "[backend") {
+		t.Errorf("sequential output should not be prefixed, got:\n%s", out)
+	}
+	report, backend := strings.Index(out, "==> report\n"), strings.Index(out, "==> backend\n")
+	if report < 0 || backend < 0 || report > backend {
+		t.Errorf("expected report then backend headers in order, got:\n%s", out)
+	}
+	if !strings.Contains(out, "    working\n") {
+		t.Errorf("expected indented deployer output, got:\n%s", out)
+	}
+}
+
+func TestRunDeployDryRunSkipsDeployAndHooks(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.Hooks.PostDeploy = webhookList{{URL: srv.URL}}
+	cfg.Hooks.SmokeTest = "exit 1"
+	p, md := testProviders(nil, nil)
+
+	err := runDeploy(context.Background(), cfg, p, deployOpts{
+		Services: []string{"backend"},
+		Env:      "staging",
+		Tags:     map[string]string{"backend": "main-abc1234-20250101000000"},
+		Yes:      true,
+		DryRun:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 0 {
+		t.Errorf("dry run should not deploy, got %d calls", len(md.calls))
+	}
+	if hits.Load() != 0 {
+		t.Errorf("dry run should not fire the post-deploy hook, got %d requests", hits.Load())
+	}
+}
+
+func TestFormatPlanMarkdown(t *testing.T) {
+	cfg := testConfig()
+	got := formatPlanMarkdown(cfg, []string{"backend", "frontend"}, "staging",
+		map[string]string{"backend": "main-abc1234-20250101000000", "frontend": "main-abc1234-20250101000000"},
+		map[string]string{"backend": "main-old1234-20241231000000"})
+
+	want := "| Service | Type | Env | Old | New |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| backend | server | staging | `main-old1234-20241231000000` | `main-abc1234-20250101000000` |\n" +
+		"| frontend | static | staging | _none_ | `main-abc1234-20250101000000` |\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestServerDeployerPlan(t *testing.T) {
+	cfg := testConfig()
+	cfg.PrePull = "prep-node"
+	cluster := newFakeCluster()
+	d := &serverDeployer{cfg: cfg, dial: cluster.dial}
+
+	var logs []string
+	logf := func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	if err := d.plan(context.Background(), "backend", "staging", "main-abc1234-20250101000000", "main-old1234-20241231000000", logf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if addrs := cluster.addrs(); len(addrs) != 0 {
+		t.Errorf("plan should not connect to nodes, dialed %v", addrs)
+	}
+	joined := strings.Join(logs, "\n")
+	for _, want := range []string{
+		"$ prep-node",
+		"$ docker pull myapp/backend:main-abc1234-20250101000000",
+		"$ docker run '-d' '--name' 'backend-staging-main-abc1234-20250101000000'",
+		":8080/health",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("plan should mention %q, got:\n%s", want, joined)
+		}
+	}
+}
+
+func TestCronjobDeployerPlan(t *testing.T) {
+	cfg := testConfig()
+	cluster := newFakeCluster()
+	d := &cronjobDeployer{cfg: cfg, dial: cluster.dial}
+
+	var logs []string
+	logf := func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	if err := d.plan(context.Background(), "report", "staging", "main-abc1234-20250101000000", "", logf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if addrs := cluster.addrs(); len(addrs) != 0 {
+		t.Errorf("plan should not connect to nodes, dialed %v", addrs)
+	}
+	joined := strings.Join(logs, "\n")
+	if !strings.Contains(joined, "crontab entry report-staging") || !strings.Contains(joined, "0 0 * * * docker rm -f report-staging") {
+		t.Errorf("plan should show the crontab line, got:\n%s", joined)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+type ecrPruneAPI interface {
+	ecrDescribeImagesAPI
+	BatchDeleteImage(ctx context.Context, params *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error)
+}
+
+// ecrDeleteBatchSize is the maximum number of image IDs BatchDeleteImage accepts per request.
+const ecrDeleteBatchSize = 100
+
+type ecrPruner struct {
+	ecr      ecrPruneAPI
+	repoName string
+}
+
+// deleteTags removes the given image tags from the repository. An image that
+// still carries other tags is untagged rather than deleted, as ECR does.
+func (p *ecrPruner) deleteTags(ctx context.Context, tags []string) error {
+	for start := 0; start < len(tags); start += ecrDeleteBatchSize {
+		end := min(start+ecrDeleteBatchSize, len(tags))
+		ids := make([]types.ImageIdentifier, 0, end-start)
+		for _, tag := range tags[start:end] {
+			ids = append(ids, types.ImageIdentifier{ImageTag: aws.String(tag)})
+		}
+		out, err := p.ecr.BatchDeleteImage(ctx, &ecr.BatchDeleteImageInput{
+			RepositoryName: &p.repoName,
+			ImageIds:       ids,
+		})
+		if err != nil {
+			return fmt.Errorf("deleting ECR images from %s: %w", p.repoName, err)
+		}
+		if len(out.Failures) > 0 {
+			f := out.Failures[0]
+			var tag string
+			if f.ImageId != nil {
+				tag = aws.ToString(f.ImageId.ImageTag)
+			}
+			return fmt.Errorf("deleting %s:%s: %s", p.repoName, tag, aws.ToString(f.FailureReason))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+type stubECRPrune struct {
+	stubECR
+	deleteInputs []ecr.BatchDeleteImageInput
+	failures     []types.ImageFailure
+}
+
+func (s *stubECRPrune) BatchDeleteImage(_ context.Context, params *ecr.BatchDeleteImageInput, _ ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error) {
+	s.deleteInputs = append(s.deleteInputs, *params)
+	return &ecr.BatchDeleteImageOutput{Failures: s.failures}, nil
+}
+
+func TestECRPrunerDeleteTags(t *testing.T) {
+	stub := &stubECRPrune{}
+	p := &ecrPruner{ecr: stub, repoName: "myapp"}
+
+	var tags []string
+	for i := range 150 {
+		tags = append(tags, fmt.Sprintf("main-abc1234-20250101%06d", i))
+	}
+	if err := p.deleteTags(context.Background(), tags); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(stub.deleteInputs) != 2 {
+		t.Fatalf("expected 2 BatchDeleteImage calls, got %d", len(stub.deleteInputs))
+	}
+	if got := len(stub.deleteInputs[0].ImageIds); got != 100 {
+		t.Errorf("first batch = %d images, want 100", got)
+	}
+	if got := len(stub.deleteInputs[1].ImageIds); got != 50 {
+		t.Errorf("second batch = %d images, want 50", got)
+	}
+	if got := aws.ToString(stub.deleteInputs[0].RepositoryName); got != "myapp" {
+		t.Errorf("repository = %q, want %q", got, "myapp")
+	}
+	if got := aws.ToString(stub.deleteInputs[0].ImageIds[0].ImageTag); got != tags[0] {
+		t.Errorf("first tag = %q, want %q", got, tags[0])
+	}
+}
+
+func TestECRPrunerDeleteTagsFailure(t *testing.T) {
+	stub := &stubECRPrune{failures: []types.ImageFailure{{
+		ImageId:       &types.ImageIdentifier{ImageTag: aws.String("main-abc1234-20250101000000")},
+		FailureReason: aws.String("Requested image not found"),
+	}}}
+	p := &ecrPruner{ecr: stub, repoName: "myapp"}
+
+	err := p.deleteTags(context.Background(), []string{"main-abc1234-20250101000000"})
+	if err == nil || !strings.Contains(err.Error(), "Requested image not found") {
+		t.Errorf("expected failure reason in error, got: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fakeNode is an in-memory sshRunner for exercising deploy flows without a
+// real host. It records every command it receives and answers from scripted
+// handlers matched by command prefix, so tests describe how the node behaves
+// rather than the exact order of calls. It is safe for concurrent use.
+//
+// Unlike mockSSHRunner, which replays a fixed sequence of responses, a
+// fakeNode keeps working when the deployer adds, removes or reorders commands.
+type fakeNode struct {
+	mu       sync.Mutex
+	handlers []fakeHandler
+	commands []string
+	closed   int
+}
+
+type fakeHandler struct {
+	prefix string
+	reply  func(cmd string) (string, error)
+}
+
+// on answers commands starting with prefix with output and err. Handlers
+// registered later take precedence, so a general default can be narrowed.
+func (n *fakeNode) on(prefix, output string, err error) *fakeNode {
+	return n.onFunc(prefix, func(string) (string, error) { return output, err })
+}
+
+// onFunc answers commands starting with prefix by calling reply.
+func (n *fakeNode) onFunc(prefix string, reply func(cmd string) (string, error)) *fakeNode {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handlers = append(n.handlers, fakeHandler{prefix: prefix, reply: reply})
+	return n
+}
+
+func (n *fakeNode) handle(cmd string) (string, error) {
+	n.mu.Lock()
+	n.commands = append(n.commands, cmd)
+	var reply func(string) (string, error)
+	for i := len(n.handlers) - 1; i >= 0; i-- {
+		if strings.HasPrefix(cmd, n.handlers[i].prefix) {
+			reply = n.handlers[i].reply
+			break
+		}
+	}
+	n.mu.Unlock()
+
+	// Unscripted commands succeed with no output.
+	if reply == nil {
+		return "", nil
+	}
+	return reply(cmd)
+}
+
+func (n *fakeNode) run(ctx context.Context, cmd string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return n.handle(cmd)
+}
+
+func (n *fakeNode) stream(ctx context.Context, cmd string, stdout io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	out, err := n.handle(cmd)
+	if out != "" {
+		io.WriteString(stdout, out)
+	}
+	return err
+}
+
+func (n *fakeNode) close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed++
+	return nil
+}
+
+// recorded returns a copy of the commands received so far, in order.
+func (n *fakeNode) recorded() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.commands...)
+}
+
+// fakeCluster hands out one fakeNode per address. Its dial method fits the
+// dial field of serverDeployer, cronjobDeployer and the logs providers.
+type fakeCluster struct {
+	mu    sync.Mutex
+	nodes map[string]*fakeNode
+	// unreachable addresses fail to dial with this error.
+	unreachable map[string]error
+}
+
+func newFakeCluster() *fakeCluster {
+	return &fakeCluster{nodes: make(map[string]*fakeNode), unreachable: make(map[string]error)}
+}
+
+// node returns the fake node for addr, creating it on first use.
+func (c *fakeCluster) node(addr string) *fakeNode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[addr]
+	if !ok {
+		n = &fakeNode{}
+		c.nodes[addr] = n
+	}
+	return n
+}
+
+func (c *fakeCluster) dial(addr string) (sshRunner, error) {
+	c.mu.Lock()
+	err := c.unreachable[addr]
+	c.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %w", addr, err)
+	}
+	return c.node(addr), nil
+}
+
+// addrs returns the addresses of all nodes that have been used, sorted.
+func (c *fakeCluster) addrs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	addrs := make([]string, 0, len(c.nodes))
+	for addr := range c.nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeNodeHandlers(t *testing.T) {
+	n := (&fakeNode{}).
+		on("docker ps", "a\nb", nil).
+		on("docker ps -a", "c", nil).
+		on("docker pull", "", errors.New("no such image"))
+
+	if out, _ := n.run(context.Background(), "docker ps --format x"); out != "a\nb" {
+		t.Errorf("docker ps = %q", out)
+	}
+	if out, _ := n.run(context.Background(), "docker ps -a --format x"); out != "c" {
+		t.Errorf("later handler should win, got %q", out)
+	}
+	if _, err := n.run(context.Background(), "docker pull x"); err == nil {
+		t.Error("expected scripted error")
+	}
+	if out, err := n.run(context.Background(), "uptime"); out != "" || err != nil {
+		t.Errorf("unscripted command = %q, %v", out, err)
+	}
+	if got := len(n.recorded()); got != 4 {
+		t.Errorf("recorded %d commands, want 4", got)
+	}
+}
+
+func TestFakeClusterServerDeploy(t *testing.T) {
+	cfg := testConfig()
+	cluster := newFakeCluster()
+	cluster.node("10.0.0.1").
+		on("docker inspect", "172.17.0.2", nil).
+		on("docker ps", "backend-staging-main-abc1234-20250101000000\nbackend-main-old1234-20241231000000", nil)
+
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         cluster.dial,
+		pollInterval: 10 * time.Millisecond,
+		pollTimeout:  time.Second,
+	}
+
+	err := d.deploy(context.Background(), "backend", "staging", "main-abc1234-20250101000000", "main-old1234-20241231000000", nopLogf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if addrs := cluster.addrs(); strings.Join(addrs, ",") != "10.0.0.1" {
+		t.Errorf("dialed %v, want only 10.0.0.1", addrs)
+	}
+	var removedOld bool
+	for _, cmd := range cluster.node("10.0.0.1").recorded() {
+		if cmd == "docker rm backend-main-old1234-20241231000000" {
+			removedOld = true
+		}
+	}
+	if !removedOld {
+		t.Error("expected old container to be removed")
+	}
+}
+
+func TestFakeClusterUnreachable(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.unreachable["10.0.0.1"] = errors.New("connection refused")
+
+	d := &serverDeployer{cfg: testConfig(), dial: cluster.dial}
+	err := d.deploy(context.Background(), "backend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected dial error, got: %v", err)
+	}
+}
@@ -2,11 +2,20 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -19,27 +28,66 @@ type deployEvent struct {
 	IsRollback bool           `json:"is_rollback"`
 	DurationMs int64          `json:"duration_ms"`
 	Timestamp  time.Time      `json:"timestamp"`
+	SmokeTest  *smokeTestInfo `json:"smoke_test,omitempty"`
 }
 
-type serviceEvent struct {
-	Name   string `json:"name"`
-	OldTag string `json:"old_tag"`
-	NewTag string `json:"new_tag"`
-	Status string `json:"status"`
+type smokeTestInfo struct {
+	Passed bool   `json:"passed"`
 	Error  string `json:"error,omitempty"`
 }
 
-func buildDeployEvent(project, env string, services []string, tags, previousTags map[string]string, result deployResult, duration time.Duration, isRollback bool) deployEvent {
+// recordSmokeTest adds the smoke test outcome to the event. A failed smoke
+// test fails the deploy even though every service deployed.
+func (e *deployEvent) recordSmokeTest(err error) {
+	e.SmokeTest = &smokeTestInfo{Passed: err == nil}
+	if err != nil {
+		e.SmokeTest.Error = err.Error()
+		e.Result = "failure"
+	}
+}
+
+// recordAbort marks a deploy that stopped before its services ran, such as
+// one refused by the pre_deploy hook: every service is skipped with err.
+func (e *deployEvent) recordAbort(err error) {
+	e.Result = "failure"
+	for i := range e.Services {
+		e.Services[i].Status = "skipped"
+		e.Services[i].Error = err.Error()
+	}
+}
+
+type serviceEvent struct {
+	Name    string       `json:"name"`
+	OldTag  string       `json:"old_tag"`
+	NewTag  string       `json:"new_tag"`
+	Status  string       `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	Commits *commitRange `json:"commits,omitempty"`
+}
+
+// deployReport is written to --result-file once a deploy finishes.
+type deployReport struct {
+	Deploy   deployEvent  `json:"deploy"`
+	Rollback *deployEvent `json:"rollback,omitempty"`
+}
+
+// buildDeployEvent reports services going from previousTags to tags. commits
+// holds each service's commit range, if known; rollbacks pass nil.
+func buildDeployEvent(project, env string, services []string, tags, previousTags map[string]string, commits map[string]*commitRange, result deployResult, duration time.Duration, isRollback bool) deployEvent {
 	var events []serviceEvent
 	for _, svc := range services {
 		se := serviceEvent{
-			Name:   svc,
-			OldTag: previousTags[svc],
-			NewTag: tags[svc],
-			Status: "success",
+			Name:    svc,
+			OldTag:  previousTags[svc],
+			NewTag:  tags[svc],
+			Status:  "success",
+			Commits: commits[svc],
 		}
 		if err, ok := result.errors[svc]; ok {
 			se.Status = "failure"
+			if slices.Contains(result.skipped, svc) {
+				se.Status = "skipped"
+			}
 			se.Error = err.Error()
 		}
 		events = append(events, se)
@@ -62,31 +110,283 @@ func buildDeployEvent(project, env string, services []string, tags, previousTags
 	}
 }
 
-func firePostDeployHook(url string, event deployEvent) {
+// buildPreDeployEvent returns the event sent to the pre_deploy hook: the
+// deploy about to run, with every result "pending".
+func buildPreDeployEvent(project, env string, services []string, tags, previousTags map[string]string, commits map[string]*commitRange) deployEvent {
+	event := buildDeployEvent(project, env, services, tags, previousTags, commits, deployResult{}, 0, false)
+	event.Result = "pending"
+	for i := range event.Services {
+		event.Services[i].Status = "pending"
+	}
+	return event
+}
+
+// firePreDeployHook posts event to hc.PreDeploy, signed with hc.Secret if set,
+// and waits up to hc.Timeout for the response. Unlike the post_deploy hook,
+// it isn't retried: any failure or non-2xx response is returned so the caller
+// can abort the deploy.
+func firePreDeployHook(ctx context.Context, hc hooksConfig, event deployEvent) error {
 	body, err := json.Marshal(event)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "hook: marshal error: %v\n", err)
+		return fmt.Errorf("pre_deploy hook: marshaling event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(hc.Timeout, defaultWebhookTimeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hc.PreDeploy, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("pre_deploy hook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hc.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, webhookSignature(hc.Secret, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pre_deploy hook: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("pre_deploy hook: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// hookWaitTimeout bounds how long hoist waits for in-flight hooks before
+// returning: long enough for every attempt to time out and every retry pause
+// in between, plus a second of slack.
+func hookWaitTimeout(hc hooksConfig) time.Duration {
+	perAttempt := cmp.Or(hc.Timeout, defaultWebhookTimeout)
+	var backoff time.Duration
+	for attempt := 1; attempt < webhookAttempts; attempt++ {
+		backoff += time.Duration(attempt) * webhookRetryBackoff
+	}
+	return webhookAttempts*perAttempt + backoff + time.Second
+}
+
+// goPostDeployHooks fires every post_deploy hook concurrently in the
+// background. Each returned channel is closed once its request has finished
+// (or failed), retries included.
+func goPostDeployHooks(hc hooksConfig, event deployEvent) []<-chan struct{} {
+	var pending []<-chan struct{}
+	for _, hook := range hc.PostDeploy {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			firePostDeployHook(hook, hc, event)
+		}()
+		pending = append(pending, done)
+	}
+	return pending
+}
+
+// waitForHooks blocks until every pending hook is done or timeout elapses.
+// A timeout of 0 waits for as long as the hooks take.
+func waitForHooks(pending []<-chan struct{}, timeout time.Duration) {
+	if len(pending) == 0 {
+		return
+	}
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	for _, done := range pending {
+		select {
+		case <-done:
+		case <-deadline:
+			fmt.Fprintf(os.Stderr, "hook: gave up waiting after %s\n", timeout)
+			return
+		}
+	}
+}
+
+// Webhook body formats for post_deploy.
+const (
+	webhookFormatJSON  = "json"  // the deployEvent as JSON
+	webhookFormatSlack = "slack" // a Slack incoming-webhook message summarizing the deploy
+)
+
+// webhookBody renders event in the hook's format.
+func webhookBody(format string, event deployEvent) ([]byte, error) {
+	switch format {
+	case "", webhookFormatJSON:
+		return json.Marshal(event)
+	case webhookFormatSlack:
+		return json.Marshal(map[string]string{"text": slackMessage(event)})
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// slackMessage summarizes event in Slack mrkdwn: a headline with the result,
+// then one line per service with its old and new tag.
+func slackMessage(event deployEvent) string {
+	kind := "Deploy"
+	if event.IsRollback {
+		kind = "Rollback"
+	}
+	outcome := "succeeded"
+	if event.Result != "success" {
+		outcome = "failed"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%s*: %s to *%s* %s", event.Project, kind, event.Env, outcome)
+	fmt.Fprintf(&sb, " in %s", (time.Duration(event.DurationMs) * time.Millisecond).Round(time.Second))
+	if event.User != "" {
+		fmt.Fprintf(&sb, " (by %s)", event.User)
+	}
+	for _, se := range event.Services {
+		old := se.OldTag
+		if old == "" {
+			old = "(none)"
+		}
+		fmt.Fprintf(&sb, "\n• %s: `%s` → `%s` %s", se.Name, old, se.NewTag, se.Status)
+		if se.Error != "" {
+			fmt.Fprintf(&sb, " (%s)", se.Error)
+		}
+	}
+	if event.SmokeTest != nil && !event.SmokeTest.Passed {
+		fmt.Fprintf(&sb, "\nSmoke test failed: %s", event.SmokeTest.Error)
+	}
+	return sb.String()
+}
+
+// webhookSignatureHeader carries the HMAC-SHA256 of a signed webhook body.
+const webhookSignatureHeader = "X-Hoist-Signature"
+
+// webhookSignature returns the X-Hoist-Signature value for body: "sha256="
+// followed by the hex HMAC-SHA256 of body keyed with secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post-deploy webhook delivery: each attempt gets the hook timeout, and a
+// connection error or 5xx response is retried after a growing pause.
+const (
+	defaultWebhookTimeout = 5 * time.Second
+	webhookAttempts       = 3
+)
+
+var webhookRetryBackoff = time.Second
+
+// firePostDeployHook posts event to hook, signed with hc.Secret if set,
+// retrying connection errors and 5xx responses. Failures are only reported;
+// the deploy has already happened.
+func firePostDeployHook(hook webhookConfig, hc hooksConfig, event deployEvent) {
+	body, err := webhookBody(cmp.Or(hook.Format, hc.PostDeployFormat), event)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hook %s: marshal error: %v\n", hook.URL, err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	for attempt := 1; ; attempt++ {
+		retry, err := postWebhook(hook.URL, body, hc)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == webhookAttempts {
+			fmt.Fprintf(os.Stderr, "hook %s: %v\n", hook.URL, err)
+			return
+		}
+		wait := time.Duration(attempt) * webhookRetryBackoff
+		fmt.Fprintf(os.Stderr, "hook %s: %v, retrying in %s\n", hook.URL, err, wait)
+		time.Sleep(wait)
+	}
+}
+
+// postWebhook makes one delivery attempt and reports whether a failure is
+// worth retrying.
+func postWebhook(url string, body []byte, hc hooksConfig) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(hc.Timeout, defaultWebhookTimeout))
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "hook: request error: %v\n", err)
-		return
+		return false, fmt.Errorf("request error: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if hc.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, webhookSignature(hc.Secret, body))
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "hook: %v\n", err)
-		return
+		return true, err
 	}
 	resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		fmt.Fprintf(os.Stderr, "hook: unexpected status %d\n", resp.StatusCode)
+		return resp.StatusCode >= 500, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// runSmokeTest runs the smoke_test hook command locally through sh after a
+// successful deploy. The deploy is described to the command in HOIST_* env
+// vars (see smokeTestEnv) and its output goes to w. A non-zero exit is an error.
+func runSmokeTest(ctx context.Context, command, project, env string, services []string, tags, previousTags map[string]string, w io.Writer) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), smokeTestEnv(project, env, services, tags, previousTags)...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("smoke test: %w", err)
+	}
+	return nil
+}
+
+// smokeTestEnv returns the env vars passed to the smoke test:
+//
+//	HOIST_PROJECT, HOIST_ENV
+//	HOIST_SERVICES        comma-separated service names
+//	HOIST_TAGS            comma-separated service=tag pairs
+//	HOIST_PREVIOUS_TAGS   comma-separated service=tag pairs of what was live before
+//	HOIST_TAG_<SERVICE>   tag per service, upper-cased with - replaced by _
+func smokeTestEnv(project, env string, services []string, tags, previousTags map[string]string) []string {
+	var pairs, prevPairs []string
+	vars := []string{
+		"HOIST_PROJECT=" + project,
+		"HOIST_ENV=" + env,
+		"HOIST_SERVICES=" + strings.Join(services, ","),
+	}
+	for _, svc := range services {
+		pairs = append(pairs, svc+"="+tags[svc])
+		prevPairs = append(prevPairs, svc+"="+previousTags[svc])
+		name := strings.ToUpper(strings.ReplaceAll(svc, "-", "_"))
+		vars = append(vars, "HOIST_TAG_"+name+"="+tags[svc])
+	}
+	return append(vars,
+		"HOIST_TAGS="+strings.Join(pairs, ","),
+		"HOIST_PREVIOUS_TAGS="+strings.Join(prevPairs, ","),
+	)
+}
+
+// writeDeployReport writes the report as JSON to path. It writes to a temp file
+// in the same directory and renames it, so readers never see a partial file.
+func writeDeployReport(path string, report deployReport) error {
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".hoist-result-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
 	}
+	return os.Rename(tmp, path)
 }
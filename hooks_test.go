@@ -1,9 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -36,7 +49,7 @@ func TestFirePostDeployHook(t *testing.T) {
 		Timestamp:  time.Now(),
 	}
 
-	firePostDeployHook(srv.URL, event)
+	firePostDeployHook(webhookConfig{URL: srv.URL}, hooksConfig{}, event)
 
 	if received.Project != "myapp" {
 		t.Errorf("expected project myapp, got %s", received.Project)
@@ -52,19 +65,112 @@ func TestFirePostDeployHook(t *testing.T) {
 	}
 }
 
+func TestFirePostDeployHookSignature(t *testing.T) {
+	const secret = "s3cret"
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Hoist-Signature")
+	}))
+	defer srv.Close()
+
+	firePostDeployHook(webhookConfig{URL: srv.URL}, hooksConfig{Secret: secret}, deployEvent{Project: "myapp"})
+
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		t.Fatalf("X-Hoist-Signature = %q, want sha256=...", signature)
+	}
+	got, err := hex.DecodeString(digest)
+	if err != nil {
+		t.Fatalf("decoding signature: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		t.Errorf("signature %q does not verify against the body", signature)
+	}
+
+	firePostDeployHook(webhookConfig{URL: srv.URL}, hooksConfig{}, deployEvent{Project: "myapp"})
+	if signature != "" {
+		t.Errorf("expected no signature without a secret, got %q", signature)
+	}
+}
+
 func TestFirePostDeployHookServerError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
 	defer srv.Close()
 
+	shortHookBackoff(t)
 	// Should not panic or block
-	firePostDeployHook(srv.URL, deployEvent{Project: "test"})
+	firePostDeployHook(webhookConfig{URL: srv.URL}, hooksConfig{}, deployEvent{Project: "test"})
 }
 
 func TestFirePostDeployHookUnreachable(t *testing.T) {
+	shortHookBackoff(t)
 	// Should not panic or block
-	firePostDeployHook("http://127.0.0.1:1", deployEvent{Project: "test"})
+	firePostDeployHook(webhookConfig{URL: "http://127.0.0.1:1"}, hooksConfig{}, deployEvent{Project: "test"})
+}
+
+// shortHookBackoff makes webhook retries immediate for the test.
+func shortHookBackoff(t *testing.T) {
+	old := webhookRetryBackoff
+	webhookRetryBackoff = time.Millisecond
+	t.Cleanup(func() { webhookRetryBackoff = old })
+}
+
+func TestFirePostDeployHookRetries(t *testing.T) {
+	shortHookBackoff(t)
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+	}{
+		{"5xx then success", []int{http.StatusBadGateway, http.StatusOK}, 2},
+		{"gives up after the last attempt", []int{500, 500, 500, 500}, webhookAttempts},
+		{"4xx is not retried", []int{http.StatusBadRequest, http.StatusOK}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[hits.Add(1)-1])
+			}))
+			defer srv.Close()
+
+			firePostDeployHook(webhookConfig{URL: srv.URL}, hooksConfig{}, deployEvent{Project: "test"})
+			if got := int(hits.Load()); got != tt.want {
+				t.Errorf("attempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirePostDeployHookTimeout(t *testing.T) {
+	shortHookBackoff(t)
+	var hits atomic.Int32
+	// The first request hangs past hooks.timeout. The handler doesn't read
+	// the body, so its context may never be cancelled: release it before
+	// Close, which waits for it.
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			<-release
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	start := time.Now()
+	firePostDeployHook(webhookConfig{URL: srv.URL}, hooksConfig{Timeout: 50 * time.Millisecond}, deployEvent{Project: "test"})
+	if hits.Load() != 2 {
+		t.Errorf("attempts = %d, want the timed-out one retried", hits.Load())
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("hooks.timeout was not applied, took %s", time.Since(start))
+	}
 }
 
 func TestBuildDeployEvent(t *testing.T) {
@@ -76,7 +182,7 @@ func TestBuildDeployEvent(t *testing.T) {
 		errors: map[string]error{"frontend": errCancelled},
 	}
 
-	event := buildDeployEvent("myapp", "prod", services, tags, previousTags, result, 3*time.Second, false)
+	event := buildDeployEvent("myapp", "prod", services, tags, previousTags, nil, result, 3*time.Second, false)
 
 	if event.Project != "myapp" {
 		t.Errorf("expected project myapp, got %s", event.Project)
@@ -119,7 +225,7 @@ func TestBuildDeployEvent(t *testing.T) {
 }
 
 func TestBuildDeployEventRollback(t *testing.T) {
-	event := buildDeployEvent("myapp", "prod", []string{"backend"}, map[string]string{"backend": "old-tag"}, map[string]string{"backend": "new-tag"}, deployResult{}, time.Second, true)
+	event := buildDeployEvent("myapp", "prod", []string{"backend"}, map[string]string{"backend": "old-tag"}, map[string]string{"backend": "new-tag"}, nil, deployResult{}, time.Second, true)
 
 	if !event.IsRollback {
 		t.Error("expected is_rollback=true")
@@ -128,3 +234,431 @@ func TestBuildDeployEventRollback(t *testing.T) {
 		t.Errorf("expected result success, got %s", event.Result)
 	}
 }
+
+func TestWriteDeployReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	report := deployReport{
+		Deploy: deployEvent{Project: "myapp", Env: "staging", Result: "success"},
+	}
+
+	if err := writeDeployReport(path, report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got deployReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if got.Deploy.Project != "myapp" || got.Deploy.Result != "success" {
+		t.Errorf("unexpected report: %+v", got)
+	}
+	if got.Rollback != nil {
+		t.Error("expected no rollback")
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the result file, got %d entries", len(entries))
+	}
+}
+
+func TestDeployAllWithLogResultFileOnFailure(t *testing.T) {
+	cfg := testConfig()
+	p, md := testProviders(nil, nil)
+	md.errors = map[string]error{"backend": fmt.Errorf("connection refused")}
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	tags := map[string]string{"backend": "main-abc1234-20250101000000"}
+	err := deployAllWithLog(context.Background(), cfg, p, []string{"backend"}, "staging", tags, nil, nil, nil, io.Discard, strings.NewReader("n\n"), path, "", 0, false)
+	if !errors.Is(err, errDeployFailed) {
+		t.Fatalf("expected errDeployFailed, got: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected result file: %v", err)
+	}
+	var got deployReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if got.Deploy.Result != "failure" {
+		t.Errorf("expected failure result, got %s", got.Deploy.Result)
+	}
+	if len(got.Deploy.Services) != 1 || got.Deploy.Services[0].Error == "" {
+		t.Errorf("expected backend error in report, got %+v", got.Deploy.Services)
+	}
+}
+
+func TestDeployAllWithLogWaitsForHook(t *testing.T) {
+	received := make(chan deployEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		var ev deployEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		received <- ev
+	}))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.Hooks.PostDeploy = webhookList{{URL: srv.URL}}
+	p, _ := testProviders(nil, nil)
+
+	tags := map[string]string{"backend": "main-abc1234-20250101000000"}
+	if err := deployAllWithLog(context.Background(), cfg, p, []string{"backend"}, "staging", tags, nil, nil, nil, io.Discard, strings.NewReader(""), "", "", 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case ev := <-received:
+		if ev.Result != "success" {
+			t.Errorf("expected success event, got %s", ev.Result)
+		}
+	default:
+		t.Fatal("expected hook to complete before deployAllWithLog returned")
+	}
+}
+
+func TestFirePostDeployHookSlackFormat(t *testing.T) {
+	bodies := make(chan []byte, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	event := deployEvent{
+		Project: "myapp",
+		Env:     "production",
+		User:    "alice",
+		Services: []serviceEvent{
+			{Name: "backend", OldTag: "main-old1234-20241231000000", NewTag: "main-abc1234-20250101000000", Status: "success"},
+			{Name: "frontend", NewTag: "main-abc1234-20250101000000", Status: "failure", Error: "S3 access denied"},
+		},
+		Result:     "failure",
+		DurationMs: 65400,
+	}
+
+	// post_deploy_format applies to hooks without a format of their own.
+	hc := hooksConfig{PostDeployFormat: webhookFormatSlack}
+	firePostDeployHook(webhookConfig{URL: srv.URL}, hc, event)
+	firePostDeployHook(webhookConfig{URL: srv.URL, Format: webhookFormatJSON}, hc, event)
+
+	var msg struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(<-bodies, &msg); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	for _, want := range []string{
+		"*myapp*: Deploy to *production* failed in 1m5s (by alice)",
+		"• backend: `main-old1234-20241231000000` → `main-abc1234-20250101000000` success",
+		"• frontend: `(none)` → `main-abc1234-20250101000000` failure (S3 access denied)",
+	} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("slack text missing %q, got:\n%s", want, msg.Text)
+		}
+	}
+
+	var raw deployEvent
+	if err := json.Unmarshal(<-bodies, &raw); err != nil || raw.Project != "myapp" {
+		t.Errorf("hook with format json should get the deploy event, got %+v (%v)", raw, err)
+	}
+}
+
+func TestDeployAllWithLogMultiplePostDeployHooks(t *testing.T) {
+	received := make(chan string, 2)
+	newHook := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- name
+		}))
+	}
+	slack, audit := newHook("slack"), newHook("audit")
+	defer slack.Close()
+	defer audit.Close()
+
+	cfg := testConfig()
+	cfg.Hooks.PostDeploy = webhookList{{URL: slack.URL}, {URL: audit.URL, Format: webhookFormatJSON}}
+	p, _ := testProviders(nil, nil)
+
+	tags := map[string]string{"backend": "main-abc1234-20250101000000"}
+	if err := deployAllWithLog(context.Background(), cfg, p, []string{"backend"}, "staging", tags, nil, nil, nil, io.Discard, strings.NewReader(""), "", "", 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	close(received)
+	var got []string
+	for name := range received {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "audit,slack" {
+		t.Errorf("hooks fired = %v, want [audit slack]", got)
+	}
+}
+
+func TestDeployAllWithLogPreDeployHook(t *testing.T) {
+	received := make(chan deployEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev deployEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		received <- ev
+	}))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.Hooks.PreDeploy = srv.URL
+	p, md := testProviders(nil, nil)
+
+	tags := map[string]string{"backend": "main-abc1234-20250101000000"}
+	if err := deployAllWithLog(context.Background(), cfg, p, []string{"backend"}, "staging", tags, nil, nil, nil, io.Discard, strings.NewReader(""), "", "", 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ev := <-received
+	if ev.Result != "pending" {
+		t.Errorf("expected pending result, got %s", ev.Result)
+	}
+	if len(ev.Services) != 1 || ev.Services[0].Status != "pending" || ev.Services[0].NewTag != tags["backend"] {
+		t.Errorf("unexpected services in event: %+v", ev.Services)
+	}
+	if len(md.calls) != 1 {
+		t.Errorf("expected 1 deploy, got %d", len(md.calls))
+	}
+}
+
+func TestFirePreDeployHookSignature(t *testing.T) {
+	const secret = "s3cret"
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Hoist-Signature")
+	}))
+	defer srv.Close()
+
+	hc := hooksConfig{PreDeploy: srv.URL, Secret: secret}
+	if err := firePreDeployHook(context.Background(), hc, deployEvent{Project: "myapp"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := webhookSignature(secret, body); signature != want {
+		t.Errorf("X-Hoist-Signature = %q, want %q", signature, want)
+	}
+}
+
+func TestFirePreDeployHookTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	hc := hooksConfig{PreDeploy: srv.URL, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	if err := firePreDeployHook(context.Background(), hc, deployEvent{Project: "myapp"}); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("pre_deploy hook should give up after hooks.timeout")
+	}
+}
+
+func TestDeployAllWithLogPreDeployHookAborts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.Hooks.PreDeploy = srv.URL
+	p, md := testProviders(nil, nil)
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	tags := map[string]string{"backend": "main-abc1234-20250101000000"}
+	err := deployAllWithLog(context.Background(), cfg, p, []string{"backend"}, "staging", tags, nil, nil, nil, io.Discard, strings.NewReader(""), path, "", 0, false)
+	if err == nil || !strings.Contains(err.Error(), "unexpected status 409") {
+		t.Fatalf("expected pre_deploy hook error, got: %v", err)
+	}
+	if len(md.calls) != 0 {
+		t.Errorf("expected no deploys, got %d", len(md.calls))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected result file for an aborted deploy: %v", err)
+	}
+	var got deployReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if got.Deploy.Result != "failure" || len(got.Deploy.Services) != 1 || got.Deploy.Services[0].Status != "skipped" {
+		t.Errorf("expected a failed report with backend skipped, got %+v", got.Deploy)
+	}
+}
+
+func TestHookWaitTimeoutCoversRetries(t *testing.T) {
+	// Three 5s attempts with 1s and 2s pauses between them, plus slack.
+	if got, want := hookWaitTimeout(hooksConfig{}), 19*time.Second; got != want {
+		t.Errorf("default wait = %v, want %v", got, want)
+	}
+	if got, want := hookWaitTimeout(hooksConfig{Timeout: 20 * time.Second}), 64*time.Second; got != want {
+		t.Errorf("wait with 20s timeout = %v, want %v", got, want)
+	}
+}
+
+func TestWaitForHooksTimeout(t *testing.T) {
+	never := make(chan struct{})
+	start := time.Now()
+	waitForHooks([]<-chan struct{}{never}, 50*time.Millisecond)
+	if time.Since(start) > time.Second {
+		t.Fatal("waitForHooks should return after timeout")
+	}
+}
+
+func TestBuildDeployEventNoCommitsForUnknownTags(t *testing.T) {
+	event := buildDeployEvent("myapp", "prod", []string{"backend"}, map[string]string{"backend": "new-tag"}, map[string]string{"backend": "old-tag"}, nil, deployResult{}, time.Second, false)
+	if event.Services[0].Commits != nil {
+		t.Errorf("expected no commit range for unparseable tags, got %+v", event.Services[0].Commits)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(data), `"commits"`) {
+		t.Errorf("commits should be omitted when unknown: %s", data)
+	}
+}
+
+func TestSmokeTestEnv(t *testing.T) {
+	got := smokeTestEnv("myapp", "staging", []string{"backend", "admin-ui"},
+		map[string]string{"backend": "new-b", "admin-ui": "new-a"},
+		map[string]string{"backend": "old-b"})
+	want := []string{
+		"HOIST_PROJECT=myapp",
+		"HOIST_ENV=staging",
+		"HOIST_SERVICES=backend,admin-ui",
+		"HOIST_TAG_BACKEND=new-b",
+		"HOIST_TAG_ADMIN_UI=new-a",
+		"HOIST_TAGS=backend=new-b,admin-ui=new-a",
+		"HOIST_PREVIOUS_TAGS=backend=old-b,admin-ui=",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("smokeTestEnv =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunSmokeTest(t *testing.T) {
+	var out bytes.Buffer
+	err := runSmokeTest(context.Background(), `echo "$HOIST_ENV $HOIST_TAG_BACKEND"`, "myapp", "staging", []string{"backend"}, map[string]string{"backend": "new-b"}, nil, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "staging new-b\n" {
+		t.Errorf("output = %q", out.String())
+	}
+
+	if err := runSmokeTest(context.Background(), "exit 3", "myapp", "staging", nil, nil, nil, io.Discard); err == nil {
+		t.Error("expected error for non-zero exit")
+	}
+}
+
+func TestDeployAllWithLogSmokeTestFailureOffersRollback(t *testing.T) {
+	cfg := testConfig()
+	cfg.Hooks.SmokeTest = "exit 1"
+	p, md := testProviders(nil, nil)
+
+	tags := map[string]string{"backend": "main-abc1234-20250101000000"}
+	previous := map[string]string{"backend": "main-old1234-20241231000000"}
+	var out bytes.Buffer
+	err := deployAllWithLog(context.Background(), cfg, p, []string{"backend"}, "staging", tags, previous, nil, nil, &out, strings.NewReader("y\n"), "", "", 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "smoke test") {
+		t.Errorf("expected smoke test failure in output, got:\n%s", out.String())
+	}
+	if len(md.calls) != 2 {
+		t.Fatalf("expected deploy and rollback, got %d calls", len(md.calls))
+	}
+	if md.calls[1].tag != "main-old1234-20241231000000" {
+		t.Errorf("rollback deployed %s, want previous tag", md.calls[1].tag)
+	}
+}
+
+func TestDeployAllWithLogSmokeTestAutoRollback(t *testing.T) {
+	cfg := testConfig()
+	cfg.Hooks.SmokeTest = "echo broken; exit 1"
+	p, md := testProviders(nil, nil)
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	tags := map[string]string{"backend": "main-abc1234-20250101000000"}
+	previous := map[string]string{"backend": "main-old1234-20241231000000"}
+	// No prompt input: auto must not ask.
+	err := deployAllWithLog(context.Background(), cfg, p, []string{"backend"}, "staging", tags, previous, nil, nil, io.Discard, strings.NewReader(""), path, rollbackPolicyAuto, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(md.calls) != 2 || md.calls[1].tag != "main-old1234-20241231000000" {
+		t.Fatalf("expected automatic rollback to previous tag, got calls %+v", md.calls)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected result file: %v", err)
+	}
+	var got deployReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if got.Deploy.Result != "failure" {
+		t.Errorf("deploy result = %s, want failure", got.Deploy.Result)
+	}
+	if got.Deploy.SmokeTest == nil || got.Deploy.SmokeTest.Passed || got.Deploy.SmokeTest.Error == "" {
+		t.Errorf("expected failed smoke test in event, got %+v", got.Deploy.SmokeTest)
+	}
+	if got.Rollback == nil || got.Rollback.Result != "success" {
+		t.Errorf("expected successful rollback in report, got %+v", got.Rollback)
+	}
+}
+
+func TestDeployAllWithLogSmokeTestNeverRollback(t *testing.T) {
+	cfg := testConfig()
+	cfg.Hooks.SmokeTest = "exit 1"
+	p, md := testProviders(nil, nil)
+
+	tags := map[string]string{"backend": "main-abc1234-20250101000000"}
+	previous := map[string]string{"backend": "main-old1234-20241231000000"}
+	err := deployAllWithLog(context.Background(), cfg, p, []string{"backend"}, "staging", tags, previous, nil, nil, io.Discard, strings.NewReader("y\n"), "", rollbackPolicyNever, 0, false)
+	if !errors.Is(err, errDeployFailed) || !strings.Contains(err.Error(), "smoke test") {
+		t.Fatalf("expected errDeployFailed for the smoke test, got: %v", err)
+	}
+	if len(md.calls) != 1 {
+		t.Errorf("expected no rollback with --rollback=never, got %d calls", len(md.calls))
+	}
+}
+
+func TestRecordSmokeTestPassed(t *testing.T) {
+	event := deployEvent{Result: "success"}
+	event.recordSmokeTest(nil)
+	if event.Result != "success" || event.SmokeTest == nil || !event.SmokeTest.Passed {
+		t.Errorf("unexpected event after passing smoke test: %+v", event)
+	}
+}
+
+func TestCheckRollbackPolicy(t *testing.T) {
+	for _, policy := range []string{"", "prompt", "auto", "never"} {
+		if err := checkRollbackPolicy(policy); err != nil {
+			t.Errorf("checkRollbackPolicy(%q): %v", policy, err)
+		}
+	}
+	if err := checkRollbackPolicy("sometimes"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
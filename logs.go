@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"regexp"
 	"strconv"
 	"sync"
 )
@@ -57,6 +58,68 @@ func (w *linePrefixWriter) Flush() error {
 	return nil
 }
 
+// lineFilterWriter wraps a writer and passes through only the lines that
+// match include (when set) and don't match exclude (when set). Like
+// linePrefixWriter, it buffers partial lines until a newline is seen.
+type lineFilterWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	buf     []byte
+}
+
+func newLineFilterWriter(w io.Writer, include, exclude *regexp.Regexp) *lineFilterWriter {
+	return &lineFilterWriter{w: w, include: include, exclude: exclude}
+}
+
+func (w *lineFilterWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	total := len(p)
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	return total, nil
+}
+
+func (w *lineFilterWriter) writeLine(line []byte) error {
+	text := bytes.TrimSuffix(line, []byte("\n"))
+	if w.include != nil && !w.include.Match(text) {
+		return nil
+	}
+	if w.exclude != nil && w.exclude.Match(text) {
+		return nil
+	}
+	_, err := w.w.Write(line)
+	return err
+}
+
+// Flush filters and writes any remaining buffered content (partial line
+// without trailing newline).
+func (w *lineFilterWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		err := w.writeLine(w.buf)
+		w.buf = nil
+		return err
+	}
+	return nil
+}
+
 func dockerLogsArgs(container, since string, n int, follow bool) []string {
 	args := []string{"logs"}
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -140,3 +141,39 @@ func TestLinePrefixWriterFlush(t *testing.T) {
 		t.Errorf("got %q, want %q", buf.String(), want)
 	}
 }
+
+func TestLineFilterWriter(t *testing.T) {
+	input := "GET /health 200\nGET /api/users 200\nPOST /api/users 500\npartial /api"
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		want    string
+	}{
+		{name: "grep", include: "/api", want: "GET /api/users 200\nPOST /api/users 500\npartial /api"},
+		{name: "grep-v", exclude: "/health", want: "GET /api/users 200\nPOST /api/users 500\npartial /api"},
+		{name: "grep then grep-v", include: "/api", exclude: " 200$", want: "POST /api/users 500\npartial /api"},
+		{name: "nothing matches", include: "DELETE", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var include, exclude *regexp.Regexp
+			if tt.include != "" {
+				include = regexp.MustCompile(tt.include)
+			}
+			if tt.exclude != "" {
+				exclude = regexp.MustCompile(tt.exclude)
+			}
+			var buf bytes.Buffer
+			w := newLineFilterWriter(&buf, include, exclude)
+			// Split mid-line to check partial lines are buffered.
+			w.Write([]byte(input[:10]))
+			w.Write([]byte(input[10:]))
+			w.Flush()
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
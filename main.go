@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // version and buildTime are set via -ldflags for release builds.
@@ -59,15 +60,46 @@ func newRootCmd() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
+	cmd.PersistentFlags().StringP("config", "c", "hoist.yml", "config file path")
+	cmd.PersistentFlags().String("overlay", os.Getenv("HOIST_OVERLAY"), "config overlay file merged on top of the base config")
 	addDeployToRoot(cmd)
 	cmd.AddCommand(newTagCmd())
 	cmd.AddCommand(newStatusCmd())
 	cmd.AddCommand(newBuildsCmd())
 	cmd.AddCommand(newRollbackCmd())
+	cmd.AddCommand(newRedeployCmd())
+	cmd.AddCommand(newPromoteCmd())
 	cmd.AddCommand(newLogsCmd())
+	cmd.AddCommand(newVersionCmd())
+	cmd.AddCommand(newRunOnCmd())
+	cmd.AddCommand(newRunCmd())
+	cmd.AddCommand(newCrontabDiffCmd())
+	cmd.AddCommand(newPruneBuildsCmd())
+	cmd.AddCommand(newSchemaCmd())
+	cmd.AddCommand(newInitCmd())
 	return cmd
 }
 
+// flagAliases returns a flag normalization func that maps each alias to the
+// flag it stands for, so a second spelling is accepted without registering a
+// second flag bound to the same variable.
+func flagAliases(aliases map[string]string) func(*pflag.FlagSet, string) pflag.NormalizedName {
+	return func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		if flag, ok := aliases[name]; ok {
+			name = flag
+		}
+		return pflag.NormalizedName(name)
+	}
+}
+
+// loadCommandConfig loads the config named by the root command's --config
+// and --overlay flags.
+func loadCommandConfig(cmd *cobra.Command) (config, error) {
+	cfgPath, _ := cmd.Flags().GetString("config")
+	overlay, _ := cmd.Flags().GetString("overlay")
+	return loadConfigWithOverlay(cfgPath, overlay)
+}
+
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
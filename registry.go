@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+// registryCredentials are what docker login needs for one registry.
+type registryCredentials struct {
+	Server   string
+	Username string
+	Password string
+}
+
+// registryAuth fetches credentials for the image registry.
+type registryAuth interface {
+	credentials(ctx context.Context) (registryCredentials, error)
+}
+
+// stdinRunner is implemented by sshRunners that can feed a command's stdin,
+// so secrets don't have to appear in the command line.
+type stdinRunner interface {
+	runWithStdin(ctx context.Context, cmd string, stdin io.Reader) (string, error)
+}
+
+type ecrGetAuthorizationTokenAPI interface {
+	GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error)
+}
+
+// ecrRegistryAuth gets docker credentials from ECR. The token is valid for 12
+// hours, so it is fetched once and shared by every deploy in the run.
+type ecrRegistryAuth struct {
+	ecr ecrGetAuthorizationTokenAPI
+
+	mu    sync.Mutex
+	creds *registryCredentials
+	until time.Time
+}
+
+func (a *ecrRegistryAuth) credentials(ctx context.Context) (registryCredentials, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.creds != nil && time.Now().Before(a.until) {
+		return *a.creds, nil
+	}
+
+	out, err := a.ecr.GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return registryCredentials{}, fmt.Errorf("getting ECR authorization token: %w", err)
+	}
+	if len(out.AuthorizationData) == 0 {
+		return registryCredentials{}, fmt.Errorf("getting ECR authorization token: no authorization data")
+	}
+	data := out.AuthorizationData[0]
+	if data.AuthorizationToken == nil || data.ProxyEndpoint == nil {
+		return registryCredentials{}, fmt.Errorf("getting ECR authorization token: incomplete authorization data")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(*data.AuthorizationToken)
+	if err != nil {
+		return registryCredentials{}, fmt.Errorf("decoding ECR authorization token: %w", err)
+	}
+	user, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return registryCredentials{}, fmt.Errorf("decoding ECR authorization token: expected user:password")
+	}
+
+	creds := registryCredentials{
+		Server:   strings.TrimPrefix(*data.ProxyEndpoint, "https://"),
+		Username: user,
+		Password: password,
+	}
+	a.creds = &creds
+	a.until = time.Now().Add(time.Hour)
+	if data.ExpiresAt != nil {
+		// Refresh a little before ECR's expiry.
+		a.until = data.ExpiresAt.Add(-5 * time.Minute)
+	}
+	return creds, nil
+}
+
+// registryLoginCommand returns the docker login command for creds. The
+// password is read from stdin, never passed as an argument.
+func registryLoginCommand(creds registryCredentials) string {
+	return "docker login --username " + shellQuote(creds.Username) + " --password-stdin " + shellQuote(creds.Server)
+}
+
+// runRegistryLogin logs the node's docker into the registry, if one is
+// configured. auth may be nil.
+func runRegistryLogin(ctx context.Context, client sshRunner, auth registryAuth, logf func(string, ...any)) error {
+	if auth == nil {
+		return nil
+	}
+	creds, err := auth.credentials(ctx)
+	if err != nil {
+		return fmt.Errorf("registry login: %w", err)
+	}
+	sr, ok := client.(stdinRunner)
+	if !ok {
+		return fmt.Errorf("registry login: connection can't pass the password on stdin")
+	}
+	command := registryLoginCommand(creds)
+	logf("$ %s", command)
+	if _, err := sr.runWithStdin(ctx, command, strings.NewReader(creds.Password)); err != nil {
+		return fmt.Errorf("registry login: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+type fakeECRAuth struct {
+	calls int
+	err   error
+}
+
+func (f *fakeECRAuth) GetAuthorizationToken(_ context.Context, _ *ecr.GetAuthorizationTokenInput, _ ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []types.AuthorizationData{{
+			AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte("AWS:s3cr3t-token"))),
+			ProxyEndpoint:      aws.String("https://123456789012.dkr.ecr.us-east-1.amazonaws.com"),
+			ExpiresAt:          aws.Time(time.Now().Add(12 * time.Hour)),
+		}},
+	}, nil
+}
+
+func TestECRRegistryAuthCredentials(t *testing.T) {
+	fake := &fakeECRAuth{}
+	auth := &ecrRegistryAuth{ecr: fake}
+
+	for range 2 {
+		creds, err := auth.credentials(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := registryCredentials{Server: "123456789012.dkr.ecr.us-east-1.amazonaws.com", Username: "AWS", Password: "s3cr3t-token"}
+		if creds != want {
+			t.Errorf("credentials = %+v, want %+v", creds, want)
+		}
+	}
+	if fake.calls != 1 {
+		t.Errorf("GetAuthorizationToken called %d times, want 1", fake.calls)
+	}
+}
+
+func TestCronjobDeployRegistryLoginBeforePull(t *testing.T) {
+	cfg := cronjobTestConfig()
+	cfg.Registry = &registryConfig{Type: "ecr"}
+	mock := &mockSSHRunner{}
+	d := &cronjobDeployer{
+		cfg:      cfg,
+		dial:     func(string) (sshRunner, error) { return mock, nil },
+		registry: &ecrRegistryAuth{ecr: &fakeECRAuth{}},
+	}
+
+	if err := d.deploy(context.Background(), "report", "prod", "main-abc1234-20250101000000", "", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(mock.commands) < 2 {
+		t.Fatalf("expected login and pull, got %v", mock.commands)
+	}
+	want := "docker login --username 'AWS' --password-stdin '123456789012.dkr.ecr.us-east-1.amazonaws.com'"
+	if mock.commands[0] != want {
+		t.Errorf("cmd[0] = %q, want %q", mock.commands[0], want)
+	}
+	if !strings.HasPrefix(mock.commands[1], "docker pull ") {
+		t.Errorf("cmd[1] = %q, want docker pull after login", mock.commands[1])
+	}
+	if mock.stdins[0] != "s3cr3t-token" {
+		t.Errorf("login stdin = %q, want the password", mock.stdins[0])
+	}
+	for _, cmd := range mock.commands {
+		if strings.Contains(cmd, "s3cr3t-token") {
+			t.Errorf("password leaked into command: %s", cmd)
+		}
+	}
+}
+
+func TestServerDeployRegistryLoginFailure(t *testing.T) {
+	cfg := testConfig()
+	cfg.Registry = &registryConfig{Type: "ecr"}
+	mock := &mockSSHRunner{}
+	d := &serverDeployer{
+		cfg:      cfg,
+		dial:     func(string) (sshRunner, error) { return mock, nil },
+		registry: &ecrRegistryAuth{ecr: &fakeECRAuth{err: errors.New("access denied")}},
+	}
+
+	err := d.deploy(context.Background(), "backend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err == nil || !strings.Contains(err.Error(), "registry login") || !strings.Contains(err.Error(), "access denied") {
+		t.Fatalf("expected registry login error, got: %v", err)
+	}
+	if len(mock.commands) != 0 {
+		t.Errorf("expected nothing run on the node, got %v", mock.commands)
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
 )
@@ -14,9 +20,18 @@ type ecrDescribeImagesAPI interface {
 	DescribeImages(ctx context.Context, params *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error)
 }
 
+// ecrImageConfigAPI is the part of the ECR client used to read an image's
+// config, for its OCI labels.
+type ecrImageConfigAPI interface {
+	BatchGetImage(ctx context.Context, params *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error)
+	GetDownloadUrlForLayer(ctx context.Context, params *ecr.GetDownloadUrlForLayerInput, optFns ...func(*ecr.Options)) (*ecr.GetDownloadUrlForLayerOutput, error)
+}
+
 type serverBuildsProvider struct {
-	ecr      ecrDescribeImagesAPI
-	repoName string
+	ecr        ecrDescribeImagesAPI
+	repoName   string
+	labels     ecrImageConfigAPI // nil means builds carry only what the tag says
+	httpClient *http.Client      // fetches image configs; nil means http.DefaultClient
 }
 
 // parseECRRepo extracts the repository name from a full ECR image URL.
@@ -74,5 +89,122 @@ func (p *serverBuildsProvider) listBuilds(ctx context.Context, limit, offset int
 		all = all[:limit]
 	}
 
+	if p.labels != nil {
+		// BatchGetImage takes at most 100 image IDs per call.
+		for chunk := range slices.Chunk(all, 100) {
+			p.applyLabels(ctx, chunk)
+		}
+	}
 	return all, nil
 }
+
+// OCI image labels read by applyLabels.
+const (
+	labelRevision    = "org.opencontainers.image.revision"
+	labelCreated     = "org.opencontainers.image.created"
+	labelAuthors     = "org.opencontainers.image.authors"
+	labelDescription = "org.opencontainers.image.description"
+	labelTitle       = "org.opencontainers.image.title"
+)
+
+// applyLabels fills in builds from the OCI labels on their images: Time from
+// created, Author from authors, Message from description (or title), and the
+// full SHA from revision. It is best-effort; a build whose labels can't be read
+// keeps what its tag says.
+func (p *serverBuildsProvider) applyLabels(ctx context.Context, builds []build) {
+	ids := make([]types.ImageIdentifier, len(builds))
+	for i := range builds {
+		ids[i] = types.ImageIdentifier{ImageTag: aws.String(builds[i].Tag)}
+	}
+	out, err := p.labels.BatchGetImage(ctx, &ecr.BatchGetImageInput{
+		RepositoryName: &p.repoName,
+		ImageIds:       ids,
+		AcceptedMediaTypes: []string{
+			"application/vnd.docker.distribution.manifest.v2+json",
+			"application/vnd.oci.image.manifest.v1+json",
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	byTag := make(map[string]int, len(builds))
+	for i, b := range builds {
+		byTag[b.Tag] = i
+	}
+	for _, img := range out.Images {
+		if img.ImageId == nil {
+			continue
+		}
+		i, ok := byTag[aws.ToString(img.ImageId.ImageTag)]
+		if !ok {
+			continue
+		}
+		labels, err := p.imageLabels(ctx, aws.ToString(img.ImageManifest))
+		if err != nil {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, labels[labelCreated]); err == nil {
+			builds[i].Time = t
+		}
+		if v := labels[labelAuthors]; v != "" {
+			builds[i].Author = v
+		}
+		if v := cmp.Or(labels[labelDescription], labels[labelTitle]); v != "" {
+			builds[i].Message = v
+		}
+		if v := labels[labelRevision]; strings.HasPrefix(v, builds[i].SHA) {
+			builds[i].SHA = v
+		}
+	}
+}
+
+// imageLabels reads the labels from the config blob an image manifest points
+// at.
+func (p *serverBuildsProvider) imageLabels(ctx context.Context, manifest string) (map[string]string, error) {
+	var m struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal([]byte(manifest), &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	if m.Config.Digest == "" {
+		return nil, fmt.Errorf("manifest has no config")
+	}
+
+	url, err := p.labels.GetDownloadUrlForLayer(ctx, &ecr.GetDownloadUrlForLayerInput{
+		RepositoryName: &p.repoName,
+		LayerDigest:    &m.Config.Digest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting config URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, aws.ToString(url.DownloadUrl), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := p.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching config: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching config: %s", resp.Status)
+	}
+
+	var cfg struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	return cfg.Config.Labels, nil
+}
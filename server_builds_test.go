@@ -3,8 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
 )
@@ -57,6 +62,7 @@ func TestServerBuildsFiltering(t *testing.T) {
 					{ImageTags: []string{"latest"}},
 					{ImageTags: []string{"not-a-valid-tag"}},
 					{ImageTags: []string{"feat-xyz-def5678-20250101090000"}},
+					{ImageTags: []string{"latest", "main-0123456-20250101080000", "v1.2.3"}},
 				},
 			},
 		},
@@ -67,8 +73,14 @@ func TestServerBuildsFiltering(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(builds) != 2 {
-		t.Fatalf("expected 2 builds, got %d", len(builds))
+	var tags []string
+	for _, b := range builds {
+		tags = append(tags, b.Tag)
+	}
+	// An image's hoist tag is kept even when it also carries manual tags.
+	want := "main-abc1234-20250101100000,feat-xyz-def5678-20250101090000,main-0123456-20250101080000"
+	if got := strings.Join(tags, ","); got != want {
+		t.Errorf("builds = %s, want %s", got, want)
 	}
 }
 
@@ -212,3 +224,82 @@ func TestServerBuildsEmpty(t *testing.T) {
 		t.Errorf("expected nil builds, got %d", len(builds))
 	}
 }
+
+type stubECRLabels struct {
+	manifests map[string]string // by tag
+	configURL string
+	requested []string
+}
+
+func (s *stubECRLabels) BatchGetImage(_ context.Context, in *ecr.BatchGetImageInput, _ ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	out := &ecr.BatchGetImageOutput{}
+	for _, id := range in.ImageIds {
+		tag := aws.ToString(id.ImageTag)
+		s.requested = append(s.requested, tag)
+		if m, ok := s.manifests[tag]; ok {
+			out.Images = append(out.Images, types.Image{ImageId: &types.ImageIdentifier{ImageTag: aws.String(tag)}, ImageManifest: aws.String(m)})
+		}
+	}
+	return out, nil
+}
+
+func (s *stubECRLabels) GetDownloadUrlForLayer(_ context.Context, in *ecr.GetDownloadUrlForLayerInput, _ ...func(*ecr.Options)) (*ecr.GetDownloadUrlForLayerOutput, error) {
+	return &ecr.GetDownloadUrlForLayerOutput{DownloadUrl: aws.String(s.configURL + "/" + aws.ToString(in.LayerDigest))}, nil
+}
+
+func TestServerBuildsOCILabels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sha256:cfg1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"config":{"Labels":{
+			"org.opencontainers.image.revision":"abc1234def5678abc1234def5678abc1234def56",
+			"org.opencontainers.image.created":"2025-01-01T10:30:00Z",
+			"org.opencontainers.image.authors":"Jane Doe",
+			"org.opencontainers.image.description":"Fix login redirect"}}}`)
+	}))
+	defer srv.Close()
+
+	stub := &stubECR{
+		pages: []ecr.DescribeImagesOutput{{
+			ImageDetails: []types.ImageDetail{
+				{ImageTags: []string{"main-abc1234-20250101100000"}},
+				{ImageTags: []string{"main-def5678-20250101090000"}},
+			},
+		}},
+	}
+	labels := &stubECRLabels{
+		manifests: map[string]string{
+			"main-abc1234-20250101100000": `{"config":{"digest":"sha256:cfg1"}}`,
+			"main-def5678-20250101090000": `{"config":{"digest":"sha256:missing"}}`,
+		},
+		configURL: srv.URL,
+	}
+
+	p := &serverBuildsProvider{ecr: stub, repoName: "test-repo", labels: labels, httpClient: srv.Client()}
+	builds, err := p.listBuilds(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(builds) != 2 {
+		t.Fatalf("expected 2 builds, got %d", len(builds))
+	}
+
+	got := builds[0]
+	if got.Message != "Fix login redirect" || got.Author != "Jane Doe" {
+		t.Errorf("message, author = %q, %q", got.Message, got.Author)
+	}
+	if want := time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC); !got.Time.Equal(want) {
+		t.Errorf("time = %s, want %s", got.Time, want)
+	}
+	if got.SHA != "abc1234def5678abc1234def5678abc1234def56" {
+		t.Errorf("sha = %q, want the full revision", got.SHA)
+	}
+
+	// The second image's config can't be fetched, so it keeps what its tag says.
+	fallback := builds[1]
+	if fallback.Message != "" || fallback.SHA != "def5678" || !fallback.Time.Equal(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("fallback build = %+v", fallback)
+	}
+}
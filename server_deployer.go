@@ -1,9 +1,12 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,83 +20,195 @@ type sshRunner interface {
 type serverDeployer struct {
 	cfg          config
 	dial         func(addr string) (sshRunner, error)
-	pollInterval time.Duration // 0 means use default (2s)
-	pollTimeout  time.Duration // 0 means use default (120s)
+	registry     registryAuth  // nil means no registry login
+	pollInterval time.Duration // 0 means use the service's healthcheck_interval, or 2s
+	pollTimeout  time.Duration // 0 means use the service's healthcheck_timeout, or 120s
 }
 
+// deploy runs the blue-green deploy on each of the environment's nodes in
+// turn. A failed node doesn't stop the others; the failures are reported
+// together. With node_rollback set, nodes that took the new tag are rolled
+// back to oldTag when any node fails, so they don't disagree.
 func (d *serverDeployer) deploy(ctx context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error {
+	ec := d.cfg.Services[service].Env[env]
+	nodes := ec.nodeNames()
+	if len(nodes) == 1 {
+		return d.deployNode(ctx, nodes[0], service, env, tag, oldTag, logf)
+	}
+
+	var deployed, failed []string
+	var errs []error
+	for _, node := range nodes {
+		if ctx.Err() != nil {
+			failed = append(failed, node)
+			errs = append(errs, fmt.Errorf("%s: %w", node, ctx.Err()))
+			continue
+		}
+		nlogf := nodeLogf(logf, node)
+		if err := d.deployNode(ctx, node, service, env, tag, oldTag, nlogf); err != nil {
+			nlogf("failed: %v", err)
+			failed = append(failed, node)
+			errs = append(errs, fmt.Errorf("%s: %w", node, err))
+			continue
+		}
+		deployed = append(deployed, node)
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if ec.NodeRollback && oldTag != "" && ctx.Err() == nil {
+		for _, node := range deployed {
+			nlogf := nodeLogf(logf, node)
+			nlogf("rolling back to %s", oldTag)
+			if err := d.deployNode(ctx, node, service, env, oldTag, tag, nlogf); err != nil {
+				errs = append(errs, fmt.Errorf("%s: rolling back: %w", node, err))
+			}
+		}
+	}
+	return fmt.Errorf("deploy failed on %s: %w", strings.Join(failed, ", "), errors.Join(errs...))
+}
+
+// nodeLogf prefixes each line with the node name, so output from
+// multi-node deploys says where it came from.
+func nodeLogf(logf func(string, ...any), node string) func(string, ...any) {
+	return func(format string, args ...any) {
+		logf("[%s] %s", node, fmt.Sprintf(format, args...))
+	}
+}
+
+// deployNode runs the blue-green deploy on a single node: pull, start the new
+// container, wait for it to pass its healthcheck, then remove the old ones.
+func (d *serverDeployer) deployNode(ctx context.Context, node, service, env, tag, oldTag string, logf func(string, ...any)) error {
 	svc := d.cfg.Services[service]
 	ec := svc.Env[env]
-	addr := d.cfg.Nodes[ec.Node]
+	addr := d.cfg.Nodes[node]
 
-	logf("connecting to %s (%s)", ec.Node, addr)
+	logf("connecting to %s (%s)", node, addr)
 	client, err := d.dial(addr)
 	if err != nil {
-		return fmt.Errorf("connecting to %s: %w", addr, err)
+		return dialFailed(addr, err)
 	}
 	defer client.close()
 
+	if err := runRegistryLogin(ctx, client, d.registry, logf); err != nil {
+		return err
+	}
+	if err := runPrePull(ctx, client, d.cfg, service, logf); err != nil {
+		return err
+	}
+
 	// Pull image.
 	pullCmd := fmt.Sprintf("docker pull %s:%s", svc.Image, tag)
 	logf("$ %s", pullCmd)
 	if _, err := client.run(ctx, pullCmd); err != nil {
-		return fmt.Errorf("pulling image: %w", err)
+		return pullFailed(err)
 	}
 	logf("image pulled")
 
 	// If redeploying the same tag, rename the existing container to avoid name conflict.
 	if tag == oldTag && oldTag != "" {
-		oldName := fmt.Sprintf("%s-%s", service, oldTag)
+		oldName := containerName(d.cfg, service, env, oldTag)
 		tempName := oldName + "-old"
 		renameCmd := fmt.Sprintf("docker rename %s %s", oldName, tempName)
 		logf("$ %s", renameCmd)
 		if _, err := client.run(ctx, renameCmd); err != nil {
-			return fmt.Errorf("renaming old container: %w", err)
+			// A container from before container_name existed has the
+			// legacy name and doesn't clash; a real clash fails docker run.
+			logf("warning: renaming %s: %v", oldName, err)
 		}
 	}
 
 	// Start new container.
-	containerName := service + "-" + tag
-	runArgs := buildDockerRunArgs(d.cfg.Project, service, tag, oldTag, svc, ec, env)
+	newName := containerName(d.cfg, service, env, tag)
+	runArgs := buildDockerRunArgs(d.cfg.Project, d.cfg.Region, newName, service, tag, oldTag, svc, ec, env)
 	runCmd := "docker run " + shellJoin(runArgs)
-	logf("$ docker run --name %s-%s ...", service, tag)
+
+	// If the deploy is aborted between starting the container and passing the
+	// healthcheck, remove the new container so it isn't left running. ctx is
+	// already cancelled at that point, so cleanup uses its own deadline.
+	// Before docker run succeeds, newName may still be the live container
+	// (a same-tag redeploy whose rename was interrupted), so leave it alone.
+	started, finalized := false, false
+	defer func() {
+		if !started || finalized || ctx.Err() == nil {
+			return
+		}
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		logf("deploy aborted, removing %s", newName)
+		client.run(cleanupCtx, fmt.Sprintf("docker stop %s", newName))
+		client.run(cleanupCtx, fmt.Sprintf("docker rm %s", newName))
+	}()
+
+	logf("$ docker run --name %s ...", newName)
 	if _, err := client.run(ctx, runCmd); err != nil {
 		// Clean up the stopped container so the name is free for retry.
-		client.run(ctx, fmt.Sprintf("docker rm %s", containerName))
+		client.run(ctx, fmt.Sprintf("docker rm %s", newName))
 		return fmt.Errorf("starting container: %w", err)
 	}
+	started = true
 	logf("container started")
 
 	// Wait for healthcheck.
-	interval := d.pollInterval
-	if interval == 0 {
-		interval = 2 * time.Second
+	interval, timeout := d.pollSettings(svc)
+
+	target := fmt.Sprintf(":%d%s", svc.Port, svc.Healthcheck)
+	switch {
+	case svc.ReadinessCommand != "":
+		target = "readiness_command"
+	case svc.HealthcheckType == "tcp":
+		target = fmt.Sprintf("tcp :%d", svc.Port)
 	}
-	timeout := d.pollTimeout
-	if timeout == 0 {
-		timeout = 120 * time.Second
+	if svc.HealthcheckSuccesses > 1 {
+		logf("waiting for %d consecutive healthchecks (%s, timeout %s)", svc.HealthcheckSuccesses, target, timeout)
+	} else {
+		logf("waiting for healthcheck (%s, timeout %s)", target, timeout)
 	}
-
-	logf("waiting for healthcheck (:%d%s, timeout %s)", svc.Port, svc.Healthcheck, timeout)
-	if err := pollHealthcheck(ctx, client, containerName, svc.Port, svc.Healthcheck, interval, timeout); err != nil {
+	if err := pollHealthcheck(ctx, client, newName, svc, interval, timeout); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("healthcheck failed: %w", err)
+		}
 		logf("healthcheck failed, cleaning up new container")
 		// Clean up failed new container (best-effort).
-		client.run(ctx, fmt.Sprintf("docker stop %s", containerName))
-		client.run(ctx, fmt.Sprintf("docker rm %s", containerName))
+		client.run(ctx, fmt.Sprintf("docker stop %s", newName))
+		client.run(ctx, fmt.Sprintf("docker rm %s", newName))
 		return fmt.Errorf("healthcheck failed: %w", err)
 	}
 	logf("healthcheck passed")
+	finalized = true
 
 	// Stop and remove ALL old containers for this service.
-	newName := service + "-" + tag
-	oldContainers, err := listServiceContainers(ctx, client, service)
+	listed, err := listServiceContainers(ctx, client, d.cfg, service, env)
 	if err != nil {
 		logf("warning: failed to list old containers: %v", err)
 	}
-	for _, name := range oldContainers {
+	prefix := containerPrefix(d.cfg, service, env)
+	shared := sharedNode(d.cfg, service, env, node)
+	var oldContainers []string
+	for _, name := range listed {
 		if name == newName {
 			continue
 		}
+		if shared && !strings.HasPrefix(name, prefix) {
+			logf("warning: leaving %s running: a legacy container on a node shared by several environments of %s may belong to any of them; remove it by hand", name, service)
+			continue
+		}
+		oldContainers = append(oldContainers, name)
+	}
+	first := true
+removal:
+	for _, name := range oldContainers {
+		if !first && svc.RemovalDelay > 0 {
+			// Let connections drain before dropping the next container.
+			select {
+			case <-ctx.Done():
+				logf("warning: interrupted before removing %s: %v", name, ctx.Err())
+				break removal
+			case <-time.After(svc.RemovalDelay):
+			}
+		}
+		first = false
 		logf("$ docker stop %s", name)
 		if _, err := client.run(ctx, fmt.Sprintf("docker stop %s", name)); err != nil {
 			logf("warning: failed to stop %s: %v", name, err)
@@ -105,63 +220,134 @@ func (d *serverDeployer) deploy(ctx context.Context, service, env, tag, oldTag s
 		}
 	}
 	if len(oldContainers) > 0 {
-		removed := 0
-		for _, name := range oldContainers {
-			if name != newName {
-				removed++
-			}
-		}
-		if removed > 0 {
-			logf("removed %d old container(s)", removed)
-		}
+		logf("removed %d old container(s)", len(oldContainers))
 	}
 
 	return nil
 }
 
-// listServiceContainers returns the names of all running containers whose name
-// starts with "<service>-". This catches orphaned containers from previous deploys.
-func listServiceContainers(ctx context.Context, client sshRunner, service string) ([]string, error) {
-	cmd := fmt.Sprintf(`docker ps --filter "name=%s-" --format "{{.Names}}"`, service)
-	out, err := client.run(ctx, cmd)
-	if err != nil {
-		return nil, err
-	}
-	out = strings.TrimSpace(out)
-	if out == "" {
-		return nil, nil
-	}
-	prefix := service + "-"
-	var names []string
-	for _, line := range strings.Split(out, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, prefix) {
-			names = append(names, line)
-		}
+// plan logs the commands deploy would run, without connecting to the node.
+func (d *serverDeployer) plan(_ context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error {
+	svc := d.cfg.Services[service]
+	ec := svc.Env[env]
+
+	for _, node := range ec.nodeNames() {
+		logf("would connect to %s (%s)", node, d.cfg.Nodes[node])
 	}
-	return names, nil
+	if nodes := ec.nodeNames(); len(nodes) > 1 {
+		logf("on each node, one at a time:")
+	}
+	if d.registry != nil {
+		logf("would log in to the %s registry", d.cfg.Registry.Type)
+	}
+	if command := prePullCommand(d.cfg, service); command != "" {
+		logf("$ %s", command)
+	}
+	logf("$ docker pull %s:%s", svc.Image, tag)
+	if tag == oldTag && oldTag != "" {
+		oldName := containerName(d.cfg, service, env, oldTag)
+		logf("$ docker rename %s %s", oldName, oldName+"-old")
+	}
+	logf("$ docker run %s", shellJoin(buildDockerRunArgs(d.cfg.Project, d.cfg.Region, containerName(d.cfg, service, env, tag), service, tag, oldTag, svc, ec, env)))
+	interval, timeout := d.pollSettings(svc)
+	switch {
+	case svc.ReadinessCommand != "":
+		logf("would run $ %s every %s for up to %s", readinessCommand(containerName(d.cfg, service, env, tag), svc), interval, timeout)
+	case svc.HealthcheckType == "tcp":
+		logf("would connect to <container-ip>:%d every %s for up to %s", svc.Port, interval, timeout)
+	default:
+		logf("would poll http://<container-ip>:%d%s every %s for up to %s", svc.Port, svc.Healthcheck, interval, timeout)
+	}
+	if svc.HealthcheckSuccesses > 1 {
+		logf("would require %d consecutive passes", svc.HealthcheckSuccesses)
+	}
+	if svc.RemovalDelay > 0 {
+		logf("would stop and remove other running %s* containers, %s apart", containerPrefix(d.cfg, service, env), svc.RemovalDelay)
+	} else {
+		logf("would stop and remove other running %s* containers", containerPrefix(d.cfg, service, env))
+	}
+	if ec.NodeRollback && len(ec.nodeNames()) > 1 && oldTag != "" {
+		logf("if any node fails, would roll the others back to %s", oldTag)
+	}
+	return nil
 }
 
-func buildDockerRunArgs(project, service, tag, oldTag string, svc serviceConfig, ec envConfig, env string) []string {
+// pollSettings returns the healthcheck interval and timeout for svc: the
+// deployer's overrides if set, then the service's config, then the defaults.
+func (d *serverDeployer) pollSettings(svc serviceConfig) (interval, timeout time.Duration) {
+	interval = cmp.Or(d.pollInterval, svc.HealthcheckInterval, 2*time.Second)
+	timeout = cmp.Or(d.pollTimeout, svc.HealthcheckTimeout, 120*time.Second)
+	return interval, timeout
+}
+
+// buildDockerRunArgs returns the docker run arguments for a server container
+// called name. The awslogs region is left to the Docker daemon when region is
+// empty.
+func buildDockerRunArgs(project, region, name, service, tag, oldTag string, svc serviceConfig, ec envConfig, env string) []string {
 	args := []string{
 		"-d",
-		"--name", service + "-" + tag,
+		"--name", name,
 		"--restart", "unless-stopped",
-		"--env-file", ec.EnvFile,
-		"--log-driver", "awslogs",
+	}
+	for _, f := range ec.EnvFile {
+		args = append(args, "--env-file", f)
+	}
+	args = append(args, resourceArgs(svc)...)
+	args = append(args, "--log-driver", "awslogs")
+	if region != "" {
+		args = append(args, "--log-opt", "awslogs-region="+region)
+	}
+	args = append(args,
 		"--log-opt", fmt.Sprintf("awslogs-group=/%s/%s/%s", project, env, service),
 		"--label", "traefik.enable=true",
-		"--label", fmt.Sprintf("traefik.http.routers.%s.rule=Host(`%s`)", service, ec.Host),
-		"--label", fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port=%d", service, svc.Port),
+		// Router and service names carry the env, so environments of one
+		// service sharing a node don't override each other in Traefik.
+		"--label", fmt.Sprintf("traefik.http.routers.%s-%s.rule=Host(`%s`)", service, env, ec.Host),
+		"--label", fmt.Sprintf("traefik.http.services.%s-%s.loadbalancer.server.port=%d", service, env, svc.Port),
 		"--label", fmt.Sprintf("hoist.previous=%s", oldTag),
-		svc.Image + ":" + tag,
-	}
+		svc.Image+":"+tag,
+	)
 	if svc.Command != "" {
 		args = append(args, svc.Command)
 	}
 	return args
 }
 
+// resourceArgs returns the docker run flags for the service's memory and cpus
+// limits, or nil if it has neither.
+func resourceArgs(svc serviceConfig) []string {
+	var args []string
+	if svc.Memory != "" {
+		args = append(args, "--memory", svc.Memory)
+	}
+	if svc.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(svc.CPUs, 'f', -1, 64))
+	}
+	return args
+}
+
+// prePullCommand returns the pre_pull command for service, or "" if none is
+// configured. A service's own pre_pull replaces the top-level one.
+func prePullCommand(cfg config, service string) string {
+	if command := cfg.Services[service].PrePull; command != "" {
+		return command
+	}
+	return cfg.PrePull
+}
+
+// runPrePull runs the pre_pull command for service on the node, if any.
+func runPrePull(ctx context.Context, client sshRunner, cfg config, service string, logf func(string, ...any)) error {
+	command := prePullCommand(cfg, service)
+	if command == "" {
+		return nil
+	}
+	logf("$ %s", command)
+	if _, err := client.run(ctx, command); err != nil {
+		return fmt.Errorf("running pre_pull: %w", err)
+	}
+	return nil
+}
+
 // shellJoin quotes each argument for safe use in a shell command string.
 func shellJoin(args []string) string {
 	quoted := make([]string, len(args))
@@ -171,21 +357,61 @@ func shellJoin(args []string) string {
 	return strings.Join(quoted, " ")
 }
 
-func pollHealthcheck(ctx context.Context, client sshRunner, container string, port int, path string, interval, timeout time.Duration) error {
-	// Get the container's bridge IP to healthcheck it directly,
-	// avoiding Traefik routing to the old container during blue-green deploy.
-	ipCmd := fmt.Sprintf("docker inspect %s --format '{{range .NetworkSettings.Networks}}{{.IPAddress}}{{end}}'", container)
-	ip, err := client.run(ctx, ipCmd)
-	if err != nil {
-		return fmt.Errorf("getting container IP: %w", err)
+// containerIPCommand returns the command that prints a container's bridge IP.
+func containerIPCommand(container string) string {
+	return fmt.Sprintf("docker inspect %s --format '{{range .NetworkSettings.Networks}}{{.IPAddress}}{{end}}'", container)
+}
+
+// healthcheckCommand returns the command that succeeds when the service's
+// healthcheck at ip:port/path answers with a 2xx status, or for a tcp
+// healthcheck, when ip:port accepts a connection.
+func healthcheckCommand(ip string, svc serviceConfig) string {
+	if svc.HealthcheckType == "tcp" {
+		return fmt.Sprintf("bash -c '</dev/tcp/%s/%d'", ip, svc.Port)
+	}
+	return fmt.Sprintf("curl -sf http://%s:%d%s", ip, svc.Port, svc.Healthcheck)
+}
+
+// readinessCommand returns the command that runs the service's
+// readiness_command inside container, succeeding when it exits 0.
+func readinessCommand(container string, svc serviceConfig) string {
+	return fmt.Sprintf("docker exec %s sh -c %s", container, shellQuote(svc.ReadinessCommand))
+}
+
+// pollHealthcheck polls the container's healthcheck until it passes
+// svc.HealthcheckSuccesses times in a row (0 means once); a failure resets the
+// count. With a readiness_command, that command is run inside the container
+// instead.
+func pollHealthcheck(ctx context.Context, client sshRunner, container string, svc serviceConfig, interval, timeout time.Duration) error {
+	var healthCmd string
+	if svc.ReadinessCommand != "" {
+		healthCmd = readinessCommand(container, svc)
+	} else {
+		// Get the container's bridge IP to healthcheck it directly,
+		// avoiding Traefik routing to the old container during blue-green deploy.
+		ip, err := client.run(ctx, containerIPCommand(container))
+		if err != nil {
+			return fmt.Errorf("getting container IP: %w", err)
+		}
+		healthCmd = healthcheckCommand(ip, svc)
 	}
-	healthCmd := fmt.Sprintf("curl -sf http://%s:%d%s", ip, port, path)
 	deadline := time.After(timeout)
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	successes := max(svc.HealthcheckSuccesses, 1)
+	passed := 0
+	check := func() bool {
+		if _, err := client.run(ctx, healthCmd); err != nil {
+			passed = 0
+			return false
+		}
+		passed++
+		return passed >= successes
+	}
+
 	// First attempt immediately.
-	if _, err := client.run(ctx, healthCmd); err == nil {
+	if check() {
 		return nil
 	}
 
@@ -196,7 +422,7 @@ func pollHealthcheck(ctx context.Context, client sshRunner, container string, po
 		case <-deadline:
 			return fmt.Errorf("timed out after %s", timeout)
 		case <-ticker.C:
-			if _, err := client.run(ctx, healthCmd); err == nil {
+			if check() {
 				return nil
 			}
 		}
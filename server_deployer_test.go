@@ -3,8 +3,10 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -13,6 +15,7 @@ import (
 
 type mockSSHRunner struct {
 	commands  []string
+	stdins    map[int]string // stdin passed to runWithStdin, by command index
 	responses []mockRunResult
 	idx       int
 }
@@ -33,6 +36,15 @@ func (m *mockSSHRunner) run(_ context.Context, cmd string) (string, error) {
 	return "", nil
 }
 
+func (m *mockSSHRunner) runWithStdin(ctx context.Context, cmd string, stdin io.Reader) (string, error) {
+	data, _ := io.ReadAll(stdin)
+	if m.stdins == nil {
+		m.stdins = make(map[int]string)
+	}
+	m.stdins[len(m.commands)] = string(data)
+	return m.run(ctx, cmd)
+}
+
 func (m *mockSSHRunner) stream(_ context.Context, cmd string, stdout io.Writer) error {
 	m.commands = append(m.commands, cmd)
 	if m.idx < len(m.responses) {
@@ -54,21 +66,22 @@ func (m *mockSSHRunner) close() error { return nil }
 
 func TestBuildDockerRunArgs(t *testing.T) {
 	svc := serviceConfig{Image: "myapp/backend", Port: 8080, Healthcheck: "/health"}
-	ec := envConfig{Host: "api.staging.example.com", EnvFile: "/etc/backend/staging.env"}
+	ec := envConfig{Host: "api.staging.example.com", EnvFile: stringList{"/etc/backend/staging.env"}}
 
-	args := buildDockerRunArgs("myapp", "backend", "main-abc1234-20250101000000", "main-old1234-20241231000000", svc, ec, "staging")
+	args := buildDockerRunArgs("myapp", "eu-west-1", "backend-staging-main-abc1234-20250101000000", "backend", "main-abc1234-20250101000000", "main-old1234-20241231000000", svc, ec, "staging")
 	joined := strings.Join(args, " ")
 
 	checks := []string{
 		"-d",
-		"--name backend-main-abc1234-20250101000000",
+		"--name backend-staging-main-abc1234-20250101000000",
 		"--restart unless-stopped",
 		"--env-file /etc/backend/staging.env",
 		"--log-driver awslogs",
+		"--log-opt awslogs-region=eu-west-1",
 		"awslogs-group=/myapp/staging/backend",
 		"traefik.enable=true",
-		"traefik.http.routers.backend.rule=Host(`api.staging.example.com`)",
-		"traefik.http.services.backend.loadbalancer.server.port=8080",
+		"traefik.http.routers.backend-staging.rule=Host(`api.staging.example.com`)",
+		"traefik.http.services.backend-staging.loadbalancer.server.port=8080",
 		"hoist.previous=main-old1234-20241231000000",
 	}
 
@@ -83,13 +96,26 @@ func TestBuildDockerRunArgs(t *testing.T) {
 	if last != "myapp/backend:main-abc1234-20250101000000" {
 		t.Errorf("expected last arg to be image:tag, got %q", last)
 	}
+	if strings.Contains(joined, "--memory") || strings.Contains(joined, "--cpus") {
+		t.Errorf("expected no resource limits by default, got: %s", joined)
+	}
+}
+
+func TestBuildDockerRunArgsResourceLimits(t *testing.T) {
+	svc := serviceConfig{Image: "myapp/backend", Port: 8080, Healthcheck: "/health", Memory: "512m", CPUs: 1.5}
+	ec := envConfig{Host: "api.staging.example.com"}
+
+	args := buildDockerRunArgs("myapp", "", "backend-staging-tag", "backend", "tag", "", svc, ec, "staging")
+	if joined := strings.Join(args, " "); !strings.Contains(joined, "--memory 512m --cpus 1.5") {
+		t.Errorf("expected --memory and --cpus flags, got: %s", joined)
+	}
 }
 
 func TestBuildDockerRunArgsWithCommand(t *testing.T) {
 	svc := serviceConfig{Image: "myapp/platform", Port: 8080, Healthcheck: "/healthz", Command: "public-api"}
-	ec := envConfig{Host: "api.example.com", EnvFile: "/etc/platform/prod.env"}
+	ec := envConfig{Host: "api.example.com", EnvFile: stringList{"/etc/platform/prod.env"}}
 
-	args := buildDockerRunArgs("myapp", "public-api", "main-abc1234-20250101000000", "", svc, ec, "prod")
+	args := buildDockerRunArgs("myapp", "", "public-api-prod-main-abc1234-20250101000000", "public-api", "main-abc1234-20250101000000", "", svc, ec, "prod")
 
 	// Image:tag should be second-to-last, command should be last.
 	last := args[len(args)-1]
@@ -104,15 +130,29 @@ func TestBuildDockerRunArgsWithCommand(t *testing.T) {
 
 func TestBuildDockerRunArgsEmptyOldTag(t *testing.T) {
 	svc := serviceConfig{Image: "myapp/backend", Port: 8080, Healthcheck: "/health"}
-	ec := envConfig{Host: "api.example.com", EnvFile: "/etc/backend/prod.env"}
+	ec := envConfig{Host: "api.example.com", EnvFile: stringList{"/etc/backend/prod.env"}}
 
-	args := buildDockerRunArgs("myapp", "backend", "main-abc1234-20250101000000", "", svc, ec, "production")
+	args := buildDockerRunArgs("myapp", "", "backend-production-main-abc1234-20250101000000", "backend", "main-abc1234-20250101000000", "", svc, ec, "production")
 	joined := strings.Join(args, " ")
 
 	// Label should still be present with empty value.
 	if !strings.Contains(joined, "hoist.previous=") {
 		t.Errorf("expected hoist.previous label, got: %s", joined)
 	}
+	// Without a region the awslogs driver falls back to the daemon's.
+	if strings.Contains(joined, "awslogs-region") {
+		t.Errorf("expected no awslogs-region when region is empty, got: %s", joined)
+	}
+}
+
+func TestBuildDockerRunArgsMultipleEnvFiles(t *testing.T) {
+	svc := serviceConfig{Image: "myapp/backend", Port: 8080, Healthcheck: "/health"}
+	ec := envConfig{Host: "api.example.com", EnvFile: stringList{"/etc/shared.env", "/etc/backend/secrets.env"}}
+
+	joined := strings.Join(buildDockerRunArgs("myapp", "", "backend-production-main-abc1234-20250101000000", "backend", "main-abc1234-20250101000000", "", svc, ec, "production"), " ")
+	if !strings.Contains(joined, "--env-file /etc/shared.env --env-file /etc/backend/secrets.env") {
+		t.Errorf("expected one --env-file per entry in order, got: %s", joined)
+	}
 }
 
 func TestPollHealthcheckImmediateSuccess(t *testing.T) {
@@ -122,7 +162,7 @@ func TestPollHealthcheckImmediateSuccess(t *testing.T) {
 			{output: "OK"},         // curl
 		},
 	}
-	err := pollHealthcheck(context.Background(), mock, "test-container", 8080, "/health", 10*time.Millisecond, 1*time.Second)
+	err := pollHealthcheck(context.Background(), mock, "test-container", serviceConfig{Port: 8080, Healthcheck: "/health"}, 10*time.Millisecond, 1*time.Second)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -147,7 +187,7 @@ func TestPollHealthcheckEventualSuccess(t *testing.T) {
 			{output: "OK"},                 // curl 4
 		},
 	}
-	err := pollHealthcheck(context.Background(), mock, "test-container", 8080, "/health", 10*time.Millisecond, 1*time.Second)
+	err := pollHealthcheck(context.Background(), mock, "test-container", serviceConfig{Port: 8080, Healthcheck: "/health"}, 10*time.Millisecond, 1*time.Second)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -156,6 +196,62 @@ func TestPollHealthcheckEventualSuccess(t *testing.T) {
 	}
 }
 
+func TestPollHealthcheckConsecutiveSuccesses(t *testing.T) {
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: "172.17.0.2"},         // docker inspect
+			{output: "OK"},                 // curl 1: pass
+			{err: fmt.Errorf("unhealthy")}, // curl 2: fail, resets the count
+			{output: "OK"},                 // curl 3: pass
+			{output: "OK"},                 // curl 4: pass
+			{output: "OK"},                 // curl 5: third pass in a row
+			{output: "OK"},
+		},
+	}
+	err := pollHealthcheck(context.Background(), mock, "test-container", serviceConfig{Port: 8080, Healthcheck: "/health", HealthcheckSuccesses: 3}, 10*time.Millisecond, 1*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mock.commands) != 6 {
+		t.Fatalf("expected 6 commands, got %d", len(mock.commands))
+	}
+}
+
+func TestPollHealthcheckTCP(t *testing.T) {
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: "172.17.0.2"}, // docker inspect
+			{},                     // connect
+		},
+	}
+	svc := serviceConfig{Port: 50051, HealthcheckType: "tcp"}
+	err := pollHealthcheck(context.Background(), mock, "test-container", svc, 10*time.Millisecond, 1*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "bash -c '</dev/tcp/172.17.0.2/50051'"; len(mock.commands) != 2 || mock.commands[1] != want {
+		t.Errorf("commands = %q, want %q last", mock.commands, want)
+	}
+}
+
+func TestPollHealthcheckReadinessCommand(t *testing.T) {
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{err: fmt.Errorf("exit status 2")}, // not ready
+			{},                                 // ready
+		},
+	}
+	svc := serviceConfig{Port: 5432, ReadinessCommand: "pg_isready -U app"}
+	err := pollHealthcheck(context.Background(), mock, "test-container", svc, 10*time.Millisecond, 1*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "docker exec test-container sh -c 'pg_isready -U app'"
+	if len(mock.commands) != 2 || mock.commands[0] != want || mock.commands[1] != want {
+		t.Errorf("commands = %q, want %q twice and no IP lookup", mock.commands, want)
+	}
+}
+
 func TestPollHealthcheckTimeout(t *testing.T) {
 	mock := &mockSSHRunner{
 		responses: []mockRunResult{
@@ -172,7 +268,7 @@ func TestPollHealthcheckTimeout(t *testing.T) {
 			{err: fmt.Errorf("unhealthy")},
 		},
 	}
-	err := pollHealthcheck(context.Background(), mock, "test-container", 8080, "/health", 10*time.Millisecond, 50*time.Millisecond)
+	err := pollHealthcheck(context.Background(), mock, "test-container", serviceConfig{Port: 8080, Healthcheck: "/health"}, 10*time.Millisecond, 50*time.Millisecond)
 	if err == nil {
 		t.Fatal("expected timeout error")
 	}
@@ -197,7 +293,7 @@ func TestPollHealthcheckContextCancelled(t *testing.T) {
 		time.Sleep(25 * time.Millisecond)
 		cancel()
 	}()
-	err := pollHealthcheck(ctx, mock, "test-container", 8080, "/health", 10*time.Millisecond, 5*time.Second)
+	err := pollHealthcheck(ctx, mock, "test-container", serviceConfig{Port: 8080, Healthcheck: "/health"}, 10*time.Millisecond, 5*time.Second)
 	if err == nil {
 		t.Fatal("expected error from context cancellation")
 	}
@@ -214,7 +310,7 @@ func TestServerDeployHappyPath(t *testing.T) {
 			{},                      // docker run
 			{output: "172.17.0.2"},  // docker inspect
 			{output: "OK"},          // curl healthcheck
-			{output: "backend-main-abc1234-20250101000000\nbackend-main-old1234-20241231000000"}, // docker ps
+			{output: "backend-staging-main-abc1234-20250101000000\nbackend-main-old1234-20241231000000"}, // docker ps
 			{}, // docker stop old
 			{}, // docker rm old
 		},
@@ -268,6 +364,81 @@ func TestServerDeployHappyPath(t *testing.T) {
 	}
 }
 
+func TestServerDeploySharedNodeLeavesLegacyContainers(t *testing.T) {
+	cfg := testConfig()
+	backend := cfg.Services["backend"]
+	backend.Env["production"] = envConfig{Node: "web1", Host: "api.example.com"}
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{},                     // docker pull
+			{},                     // docker run
+			{output: "172.17.0.2"}, // docker inspect
+			{output: "OK"},         // curl healthcheck
+			{output: "backend-staging-new\nbackend-staging-old\nbackend-main-legacy"}, // docker ps
+		},
+	}
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         func(string) (sshRunner, error) { return mock, nil },
+		pollInterval: 10 * time.Millisecond,
+		pollTimeout:  1 * time.Second,
+	}
+
+	var logs []string
+	logf := func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	if err := d.deploy(context.Background(), "backend", "staging", "new", "old", logf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// backend-main-legacy may be production's: only staging's own old
+	// container is removed.
+	want := []string{"docker stop backend-staging-old", "docker rm backend-staging-old"}
+	if got := mock.commands[len(mock.commands)-2:]; !slices.Equal(got, want) {
+		t.Errorf("removal commands = %q, want %q", got, want)
+	}
+	for _, cmd := range mock.commands {
+		if strings.Contains(cmd, "backend-main-legacy") {
+			t.Errorf("legacy container touched: %q", cmd)
+		}
+	}
+	if !strings.Contains(strings.Join(logs, "\n"), "warning: leaving backend-main-legacy running") {
+		t.Errorf("expected a warning about the legacy container, got:\n%s", strings.Join(logs, "\n"))
+	}
+}
+
+func TestServerDeployRemovalDelay(t *testing.T) {
+	cfg := testConfig()
+	svc := cfg.Services["backend"]
+	svc.RemovalDelay = 50 * time.Millisecond
+	cfg.Services["backend"] = svc
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{},                     // docker pull
+			{},                     // docker run
+			{output: "172.17.0.2"}, // docker inspect
+			{output: "OK"},         // curl healthcheck
+			{output: "backend-staging-new\nbackend-staging-old1\nbackend-staging-old2"}, // docker ps
+		},
+	}
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         func(string) (sshRunner, error) { return mock, nil },
+		pollInterval: 10 * time.Millisecond,
+		pollTimeout:  1 * time.Second,
+	}
+
+	start := time.Now()
+	if err := d.deploy(context.Background(), "backend", "staging", "new", "old1", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("deploy took %s, want at least the 50ms removal delay", elapsed)
+	}
+	want := []string{"docker stop backend-staging-old1", "docker rm backend-staging-old1", "docker stop backend-staging-old2", "docker rm backend-staging-old2"}
+	if got := mock.commands[len(mock.commands)-4:]; !slices.Equal(got, want) {
+		t.Errorf("removal commands = %q, want %q", got, want)
+	}
+}
+
 func TestServerDeployNoOldTag(t *testing.T) {
 	cfg := testConfig()
 	mock := &mockSSHRunner{}
@@ -358,10 +529,10 @@ func TestServerDeployHealthcheckFailure(t *testing.T) {
 	// Verify cleanup of new container happened.
 	var hasStopNew, hasRmNew bool
 	for _, cmd := range mock.commands {
-		if cmd == "docker stop backend-main-abc1234-20250101000000" {
+		if cmd == "docker stop backend-staging-main-abc1234-20250101000000" {
 			hasStopNew = true
 		}
-		if cmd == "docker rm backend-main-abc1234-20250101000000" {
+		if cmd == "docker rm backend-staging-main-abc1234-20250101000000" {
 			hasRmNew = true
 		}
 	}
@@ -383,6 +554,76 @@ func TestServerDeployHealthcheckFailure(t *testing.T) {
 	}
 }
 
+func TestServerDeployAbortCleansUpNewContainer(t *testing.T) {
+	cfg := testConfig()
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: ""},                   // docker pull
+			{output: "container-id"},       // docker run
+			{output: "172.17.0.2"},         // docker inspect IP
+			{err: fmt.Errorf("unhealthy")}, // healthcheck
+		},
+	}
+
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         func(_ string) (sshRunner, error) { return mock, nil },
+		pollInterval: time.Hour,
+		pollTimeout:  time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := d.deploy(ctx, "backend", "staging", "main-abc1234-20250101000000", "main-old1234-20241231000000", nopLogf)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context error, got: %v", err)
+	}
+
+	want := []string{
+		"docker stop backend-staging-main-abc1234-20250101000000",
+		"docker rm backend-staging-main-abc1234-20250101000000",
+	}
+	got := mock.commands[len(mock.commands)-2:]
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("command[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestServerDeployAbortDuringSameTagRenameKeepsLiveContainer(t *testing.T) {
+	cfg := testConfig()
+	tag := "main-abc1234-20250101000000"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The rename is interrupted, so the live container keeps the name the
+	// new one would have taken.
+	node := (&fakeNode{}).onFunc("docker rename", func(string) (string, error) {
+		cancel()
+		return "", errors.New("signal: terminated")
+	})
+
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         func(_ string) (sshRunner, error) { return node, nil },
+		pollInterval: 10 * time.Millisecond,
+		pollTimeout:  time.Second,
+	}
+
+	err := d.deploy(ctx, "backend", "staging", tag, tag, nopLogf)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context error, got: %v", err)
+	}
+
+	for _, cmd := range node.recorded() {
+		if strings.HasPrefix(cmd, "docker stop") || strings.HasPrefix(cmd, "docker rm") {
+			t.Errorf("unexpected %q: the live container must not be touched", cmd)
+		}
+	}
+}
+
 func TestServerDeployDialFailure(t *testing.T) {
 	cfg := testConfig()
 
@@ -411,7 +652,7 @@ func TestServerDeploySameTag(t *testing.T) {
 			{},                      // docker run
 			{output: "172.17.0.2"},  // docker inspect
 			{output: "OK"},          // curl healthcheck
-			{output: "backend-main-abc1234-20250101000000\nbackend-main-abc1234-20250101000000-old"}, // docker ps
+			{output: "backend-staging-main-abc1234-20250101000000\nbackend-staging-main-abc1234-20250101000000-old"}, // docker ps
 			{}, // docker stop old
 			{}, // docker rm old
 		},
@@ -438,7 +679,7 @@ func TestServerDeploySameTag(t *testing.T) {
 	if !strings.HasPrefix(mock.commands[0], "docker pull") {
 		t.Errorf("cmd[0] = %q, want docker pull", mock.commands[0])
 	}
-	expectedRename := "docker rename backend-main-abc1234-20250101000000 backend-main-abc1234-20250101000000-old"
+	expectedRename := "docker rename backend-staging-main-abc1234-20250101000000 backend-staging-main-abc1234-20250101000000-old"
 	if mock.commands[1] != expectedRename {
 		t.Errorf("cmd[1] = %q, want %q", mock.commands[1], expectedRename)
 	}
@@ -448,10 +689,10 @@ func TestServerDeploySameTag(t *testing.T) {
 
 	// Last two: stop and rm the renamed container.
 	n := len(mock.commands)
-	if mock.commands[n-2] != "docker stop backend-main-abc1234-20250101000000-old" {
+	if mock.commands[n-2] != "docker stop backend-staging-main-abc1234-20250101000000-old" {
 		t.Errorf("cmd[%d] = %q, want docker stop -old", n-2, mock.commands[n-2])
 	}
-	if mock.commands[n-1] != "docker rm backend-main-abc1234-20250101000000-old" {
+	if mock.commands[n-1] != "docker rm backend-staging-main-abc1234-20250101000000-old" {
 		t.Errorf("cmd[%d] = %q, want docker rm -old", n-1, mock.commands[n-1])
 	}
 }
@@ -464,7 +705,7 @@ func TestServerDeployLogOutput(t *testing.T) {
 			{},                      // docker run
 			{output: "172.17.0.2"},  // docker inspect
 			{output: "OK"},          // curl healthcheck
-			{output: "backend-main-abc1234-20250101000000\nbackend-main-old1234-20241231000000"}, // docker ps
+			{output: "backend-staging-main-abc1234-20250101000000\nbackend-main-old1234-20241231000000"}, // docker ps
 			{}, // docker stop old
 			{}, // docker rm old
 		},
@@ -504,3 +745,146 @@ func TestServerDeployLogOutput(t *testing.T) {
 		}
 	}
 }
+
+func TestServerDeployRunsPrePull(t *testing.T) {
+	cfg := testConfig()
+	cfg.PrePull = "aws ecr get-login-password | docker login --password-stdin 123.dkr.ecr.us-east-1.amazonaws.com"
+	cluster := newFakeCluster()
+	cluster.node("10.0.0.1").on("docker inspect", "172.17.0.2", nil)
+
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         cluster.dial,
+		pollInterval: 10 * time.Millisecond,
+		pollTimeout:  time.Second,
+	}
+
+	var logs []string
+	logf := func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	if err := d.deploy(context.Background(), "backend", "staging", "main-abc1234-20250101000000", "", logf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cmds := cluster.node("10.0.0.1").recorded()
+	if len(cmds) < 2 || cmds[0] != cfg.PrePull || !strings.HasPrefix(cmds[1], "docker pull") {
+		t.Fatalf("expected pre_pull before docker pull, got %v", cmds)
+	}
+	if !strings.Contains(strings.Join(logs, "\n"), "$ "+cfg.PrePull) {
+		t.Errorf("expected pre_pull to be logged, got %v", logs)
+	}
+}
+
+func TestServerDeployPrePullFailure(t *testing.T) {
+	cfg := testConfig()
+	cfg.PrePull = "docker login -p hunter2 registry.example.com"
+	cluster := newFakeCluster()
+	cluster.node("10.0.0.1").on("docker login", "", fmt.Errorf("unauthorized"))
+
+	d := &serverDeployer{cfg: cfg, dial: cluster.dial}
+
+	err := d.deploy(context.Background(), "backend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err == nil || !strings.Contains(err.Error(), "running pre_pull") {
+		t.Fatalf("expected pre_pull error, got: %v", err)
+	}
+	for _, cmd := range cluster.node("10.0.0.1").recorded() {
+		if strings.HasPrefix(cmd, "docker pull") {
+			t.Error("should not pull after pre_pull fails")
+		}
+	}
+}
+
+func multiNodeConfig(rollback bool) config {
+	cfg := testConfig()
+	svc := cfg.Services["backend"]
+	svc.Env["production"] = envConfig{Nodes: []string{"web1", "web2"}, NodeRollback: rollback, Host: "example.com", EnvFile: stringList{"/etc/backend.env"}}
+	cfg.Services["backend"] = svc
+	return cfg
+}
+
+func TestServerDeployMultiNode(t *testing.T) {
+	cfg := multiNodeConfig(false)
+	mocks := map[string]*mockSSHRunner{"10.0.0.1": {}, "10.0.0.2": {}}
+
+	var lines []string
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         func(addr string) (sshRunner, error) { return mocks[addr], nil },
+		pollInterval: 10 * time.Millisecond,
+		pollTimeout:  1 * time.Second,
+	}
+	logf := func(format string, args ...any) { lines = append(lines, fmt.Sprintf(format, args...)) }
+
+	if err := d.deploy(context.Background(), "backend", "production", "main-abc1234-20250101000000", "", logf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for addr, mock := range mocks {
+		if len(mock.commands) == 0 || !strings.HasPrefix(mock.commands[0], "docker pull myapp/backend:main-abc1234-20250101000000") {
+			t.Errorf("%s: commands = %q, want a deploy", addr, mock.commands)
+		}
+	}
+	if len(lines) == 0 || lines[0] != "[web1] connecting to web1 (10.0.0.1)" {
+		t.Errorf("expected node-prefixed output, got %q", lines)
+	}
+}
+
+func TestServerDeployMultiNodeFailureRollsBack(t *testing.T) {
+	cfg := multiNodeConfig(true)
+	web1 := &mockSSHRunner{}
+	web2 := &mockSSHRunner{responses: []mockRunResult{{err: fmt.Errorf("pull access denied")}}}
+	mocks := map[string]*mockSSHRunner{"10.0.0.1": web1, "10.0.0.2": web2}
+
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         func(addr string) (sshRunner, error) { return mocks[addr], nil },
+		pollInterval: 10 * time.Millisecond,
+		pollTimeout:  1 * time.Second,
+	}
+
+	err := d.deploy(context.Background(), "backend", "production", "new-tag", "old-tag", nopLogf)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "deploy failed on web2") || !strings.Contains(err.Error(), "pulling image") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var pulls []string
+	for _, cmd := range web1.commands {
+		if strings.HasPrefix(cmd, "docker pull") {
+			pulls = append(pulls, cmd)
+		}
+	}
+	want := []string{"docker pull myapp/backend:new-tag", "docker pull myapp/backend:old-tag"}
+	if !slices.Equal(pulls, want) {
+		t.Errorf("web1 pulls = %q, want %q", pulls, want)
+	}
+}
+
+func TestServerDeployMultiNodeFailureNoRollback(t *testing.T) {
+	cfg := multiNodeConfig(false)
+	web1 := &mockSSHRunner{responses: []mockRunResult{{err: fmt.Errorf("pull access denied")}}}
+	web2 := &mockSSHRunner{}
+	mocks := map[string]*mockSSHRunner{"10.0.0.1": web1, "10.0.0.2": web2}
+
+	d := &serverDeployer{
+		cfg:          cfg,
+		dial:         func(addr string) (sshRunner, error) { return mocks[addr], nil },
+		pollInterval: 10 * time.Millisecond,
+		pollTimeout:  1 * time.Second,
+	}
+
+	err := d.deploy(context.Background(), "backend", "production", "new-tag", "old-tag", nopLogf)
+	if err == nil || !strings.Contains(err.Error(), "deploy failed on web1") {
+		t.Fatalf("expected failure on web1, got %v", err)
+	}
+	// A failed node doesn't stop the rest, and without node_rollback web2
+	// keeps the new tag.
+	for _, cmd := range web2.commands {
+		if strings.Contains(cmd, "old-tag") && strings.HasPrefix(cmd, "docker pull") {
+			t.Errorf("unexpected rollback on web2: %s", cmd)
+		}
+	}
+	if len(web2.commands) == 0 || web2.commands[0] != "docker pull myapp/backend:new-tag" {
+		t.Errorf("web2 commands = %q, want a deploy of new-tag", web2.commands)
+	}
+}
@@ -7,16 +7,68 @@ import (
 	"time"
 )
 
+// healthProbeTimeout bounds the healthcheck current runs against the live
+// container, so a hung app doesn't stall status.
+const healthProbeTimeout = 5 * time.Second
+
 type serverHistoryProvider struct {
 	cfg config
 	run func(ctx context.Context, addr, cmd string) (string, error)
 }
 
+// current reports the running deploy. For an environment on several nodes it
+// queries each one and records their tags in NodeTags; Tag and Uptime come from
+// the first node with a running container, and Health is the worst seen.
 func (p *serverHistoryProvider) current(ctx context.Context, service, env string) (deploy, error) {
+	nodes := p.cfg.Services[service].Env[env].nodeNames()
+	if len(nodes) == 1 {
+		return p.currentOnNode(ctx, nodes[0], service, env)
+	}
+
+	var result deploy
+	tags := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		d, err := p.currentOnNode(ctx, node, service, env)
+		if err != nil {
+			return deploy{}, fmt.Errorf("%s: %w", node, err)
+		}
+		tags[node] = d.Tag
+		if d.Tag == "" {
+			continue
+		}
+		if result.Tag == "" {
+			result = d
+			continue
+		}
+		if healthRank(d.Health) > healthRank(result.Health) {
+			result.Health = d.Health
+		}
+		result.Running = max(result.Running, d.Running)
+	}
+	if result.Tag == "" {
+		return deploy{}, nil
+	}
+	result.NodeTags = tags
+	return result, nil
+}
+
+// healthRank orders health values from best to worst.
+func healthRank(health string) int {
+	switch health {
+	case "healthy":
+		return 0
+	case "unknown":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (p *serverHistoryProvider) currentOnNode(ctx context.Context, node, service, env string) (deploy, error) {
 	svc := p.cfg.Services[service]
-	addr := p.cfg.Nodes[svc.Env[env].Node]
+	addr := p.cfg.Nodes[node]
 
-	cmd := fmt.Sprintf(`docker ps --filter "name=%s-" --format "{{.Names}}\t{{.Status}}"`, service)
+	cmd := fmt.Sprintf(`docker ps %s --format "{{.Names}}\t{{.Status}}"`, containerFilter(p.cfg, service, env))
 	out, err := p.run(ctx, addr, cmd)
 	if err != nil {
 		return deploy{}, fmt.Errorf("listing containers: %w", err)
@@ -26,34 +78,85 @@ func (p *serverHistoryProvider) current(ctx context.Context, service, env string
 		return deploy{}, nil
 	}
 
-	// Docker's name filter is a substring match, so we must check the prefix ourselves.
-	prefix := service + "-"
+	var names []string
+	statuses := make(map[string]string)
 	for _, line := range strings.Split(out, "\n") {
 		parts := strings.SplitN(line, "\t", 2)
 		if len(parts) != 2 {
 			continue
 		}
-		name := parts[0]
-		if !strings.HasPrefix(name, prefix) {
-			continue
+		names = append(names, parts[0])
+		statuses[parts[0]] = parts[1]
+	}
+
+	// Docker's name filter is a substring match, so we must check the name ourselves.
+	name := findContainer(p.cfg, service, env, names)
+	if name == "" {
+		return deploy{}, nil
+	}
+	return deploy{
+		Service: service,
+		Env:     env,
+		Tag:     parseContainerTag(p.cfg, service, env, name),
+		Uptime:  parseDockerUptime(statuses[name]),
+		Health:  p.probeHealth(ctx, addr, name, svc),
+		Running: countRunning(p.cfg, service, env, names),
+	}, nil
+}
+
+// countRunning counts the containers of service in env among names. Legacy
+// names only count when there are no current ones: on a node shared by
+// several environments, a legacy container may belong to another of them.
+func countRunning(cfg config, service, env string, names []string) int {
+	prefix := containerPrefix(cfg, service, env)
+	current, legacy := 0, 0
+	for _, name := range names {
+		switch {
+		case parseContainerTag(cfg, service, env, name) == "":
+		case strings.HasPrefix(name, prefix):
+			current++
+		default:
+			legacy++
 		}
-		return deploy{
-			Service: service,
-			Env:     env,
-			Tag:     name[len(prefix):],
-			Uptime:  parseDockerUptime(parts[1]),
-		}, nil
 	}
+	if current == 0 {
+		return legacy
+	}
+	return current
+}
 
-	return deploy{}, nil
+// probeHealth runs the service's healthcheck once against container. It is
+// best-effort: "unknown" means the container IP could not be resolved or the
+// probe ran out of time, not that the app is down.
+func (p *serverHistoryProvider) probeHealth(ctx context.Context, addr, container string, svc serviceConfig) string {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	healthCmd := readinessCommand(container, svc)
+	if svc.ReadinessCommand == "" {
+		ip, err := p.run(ctx, addr, containerIPCommand(container))
+		if err != nil || strings.TrimSpace(ip) == "" {
+			return "unknown"
+		}
+		healthCmd = healthcheckCommand(strings.TrimSpace(ip), svc)
+	}
+	if _, err := p.run(ctx, addr, healthCmd); err != nil {
+		if ctx.Err() != nil {
+			return "unknown"
+		}
+		return "unhealthy"
+	}
+	return "healthy"
 }
 
+// previous reads the hoist.previous label from the first node; every node of
+// a multi-node deploy is given the same one.
 func (p *serverHistoryProvider) previous(ctx context.Context, service, env string) (deploy, error) {
 	svc := p.cfg.Services[service]
-	addr := p.cfg.Nodes[svc.Env[env].Node]
+	addr := p.cfg.Nodes[svc.Env[env].nodeNames()[0]]
 
 	// Find the running container name.
-	psCmd := fmt.Sprintf(`docker ps --filter "name=%s-" --format "{{.Names}}"`, service)
+	psCmd := fmt.Sprintf(`docker ps %s --format "{{.Names}}"`, containerFilter(p.cfg, service, env))
 	out, err := p.run(ctx, addr, psCmd)
 	if err != nil {
 		return deploy{}, fmt.Errorf("listing containers: %w", err)
@@ -63,21 +166,14 @@ func (p *serverHistoryProvider) previous(ctx context.Context, service, env strin
 		return deploy{}, nil
 	}
 
-	// Docker's name filter is a substring match, so we must check the prefix ourselves.
-	prefix := service + "-"
-	var containerName string
-	for _, line := range strings.Split(out, "\n") {
-		if strings.HasPrefix(line, prefix) {
-			containerName = line
-			break
-		}
-	}
-	if containerName == "" {
+	// Docker's name filter is a substring match, so we must check the name ourselves.
+	container := findContainer(p.cfg, service, env, strings.Split(out, "\n"))
+	if container == "" {
 		return deploy{}, nil
 	}
 
 	// Read the hoist.previous label from the running container.
-	inspectCmd := fmt.Sprintf(`docker inspect --format "{{index .Config.Labels \"hoist.previous\"}}" %s`, containerName)
+	inspectCmd := fmt.Sprintf(`docker inspect --format "{{index .Config.Labels \"hoist.previous\"}}" %s`, container)
 	label, err := p.run(ctx, addr, inspectCmd)
 	if err != nil {
 		return deploy{}, fmt.Errorf("inspecting container: %w", err)
@@ -95,16 +191,6 @@ func (p *serverHistoryProvider) previous(ctx context.Context, service, env strin
 	}, nil
 }
 
-// parseContainerTag extracts the tag from a container name like "backend-main-abc1234-20250101000000".
-// Returns empty string if the name doesn't start with the service prefix.
-func parseContainerTag(service, name string) string {
-	prefix := service + "-"
-	if !strings.HasPrefix(name, prefix) {
-		return ""
-	}
-	return name[len(prefix):]
-}
-
 // parseDockerUptime parses Docker status strings like "Up 3 hours", "Up 2 days",
 // "Up About a minute", "Up 30 seconds". Approximate — used for display only.
 func parseDockerUptime(status string) time.Duration {
@@ -3,34 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"maps"
+	"strings"
 	"testing"
 	"time"
 )
 
-func TestParseContainerTag(t *testing.T) {
-	tests := []struct {
-		name    string
-		service string
-		input   string
-		want    string
-	}{
-		{"simple tag", "backend", "backend-main-abc1234-20250101000000", "main-abc1234-20250101000000"},
-		{"branch with hyphens", "backend", "backend-feat-login-abc1234-20250101000000", "feat-login-abc1234-20250101000000"},
-		{"no prefix match", "backend", "unrelated", ""},
-		{"service name only", "backend", "backend-", ""},
-		{"different service", "api", "backend-main-abc1234-20250101000000", ""},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := parseContainerTag(tt.service, tt.input)
-			if got != tt.want {
-				t.Errorf("parseContainerTag(%q, %q) = %q, want %q", tt.service, tt.input, got, tt.want)
-			}
-		})
-	}
-}
-
 func TestParseDockerUptime(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -90,6 +68,133 @@ func TestServerHistoryCurrent(t *testing.T) {
 	}
 }
 
+func TestServerHistoryCurrentSharedNode(t *testing.T) {
+	cfg := testConfig()
+	backend := cfg.Services["backend"]
+	backend.Env["production"] = envConfig{Node: "web1", Host: "api.example.com"}
+
+	p := &serverHistoryProvider{
+		cfg: cfg,
+		run: func(_ context.Context, _, cmd string) (string, error) {
+			if strings.HasPrefix(cmd, "docker ps") {
+				return "backend-main-old1234-20241231000000\tUp 2 days\nbackend-staging-feat-abc1234-20250102000000\tUp 1 hour\nbackend-production-main-abc1234-20250101000000\tUp 3 hours", nil
+			}
+			return "", nil
+		},
+	}
+
+	for env, want := range map[string]string{
+		"staging":    "feat-abc1234-20250102000000",
+		"production": "main-abc1234-20250101000000",
+	} {
+		d, err := p.current(context.Background(), "backend", env)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", env, err)
+		}
+		if d.Tag != want {
+			t.Errorf("%s: tag = %q, want %q", env, d.Tag, want)
+		}
+	}
+}
+
+func TestServerHistoryCurrentMultipleContainers(t *testing.T) {
+	cfg := testConfig()
+	p := &serverHistoryProvider{
+		cfg: cfg,
+		run: func(_ context.Context, _, cmd string) (string, error) {
+			if strings.HasPrefix(cmd, "docker ps") {
+				return "backend-staging-main-abc1234-20250101000000\tUp 3 hours\nbackend-staging-main-old1234-20241231000000\tUp 2 days", nil
+			}
+			return "", nil
+		},
+	}
+
+	d, err := p.current(context.Background(), "backend", "staging")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Running != 2 {
+		t.Errorf("running = %d, want 2", d.Running)
+	}
+}
+
+func TestCountRunning(t *testing.T) {
+	cfg := testConfig()
+	tests := []struct {
+		name  string
+		names []string
+		want  int
+	}{
+		{"one", []string{"backend-staging-a"}, 1},
+		{"two current", []string{"backend-staging-a", "backend-staging-b"}, 2},
+		{"two legacy", []string{"backend-a", "backend-b"}, 2},
+		// The legacy container may be another environment's on a shared node.
+		{"current and legacy", []string{"backend-staging-a", "backend-b"}, 1},
+		{"other environment", []string{"backend-staging-a", "backend-production-b"}, 1},
+		{"none", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countRunning(cfg, "backend", "staging", tt.names); got != tt.want {
+				t.Errorf("countRunning(%q) = %d, want %d", tt.names, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerHistoryCurrentUnhealthy(t *testing.T) {
+	cfg := testConfig()
+
+	var commands []string
+	p := &serverHistoryProvider{
+		cfg: cfg,
+		run: func(_ context.Context, _, cmd string) (string, error) {
+			commands = append(commands, cmd)
+			switch {
+			case strings.HasPrefix(cmd, "docker ps"):
+				return "backend-main-abc1234-20250101000000\tRestarting (1) 2 seconds ago", nil
+			case strings.HasPrefix(cmd, "docker inspect"):
+				return "172.17.0.2", nil
+			default:
+				return "", fmt.Errorf("exit status 7")
+			}
+		},
+	}
+
+	d, err := p.current(context.Background(), "backend", "staging")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Health != "unhealthy" {
+		t.Errorf("health = %q, want %q", d.Health, "unhealthy")
+	}
+	if len(commands) != 3 || commands[2] != "curl -sf http://172.17.0.2:8080/health" {
+		t.Errorf("unexpected commands: %q", commands)
+	}
+}
+
+func TestServerHistoryCurrentHealthUnknown(t *testing.T) {
+	cfg := testConfig()
+
+	p := &serverHistoryProvider{
+		cfg: cfg,
+		run: func(_ context.Context, _, cmd string) (string, error) {
+			if strings.HasPrefix(cmd, "docker ps") {
+				return "backend-main-abc1234-20250101000000\tUp 3 hours", nil
+			}
+			return "", fmt.Errorf("no such container")
+		},
+	}
+
+	d, err := p.current(context.Background(), "backend", "staging")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Health != "unknown" {
+		t.Errorf("health = %q, want %q", d.Health, "unknown")
+	}
+}
+
 func TestServerHistoryCurrentNoContainer(t *testing.T) {
 	cfg := testConfig()
 
@@ -196,3 +301,65 @@ func TestServerHistoryPreviousNoLabel(t *testing.T) {
 		t.Errorf("expected empty tag, got %q", d.Tag)
 	}
 }
+
+func TestServerHistoryCurrentMultiNodeDrift(t *testing.T) {
+	cfg := multiNodeConfig(false)
+
+	p := &serverHistoryProvider{
+		cfg: cfg,
+		run: func(_ context.Context, addr, cmd string) (string, error) {
+			switch {
+			case strings.HasPrefix(cmd, "docker ps") && addr == "10.0.0.1":
+				return "backend-new-tag\tUp 3 hours", nil
+			case strings.HasPrefix(cmd, "docker ps"):
+				return "backend-old-tag\tUp 2 days", nil
+			case strings.HasPrefix(cmd, "docker inspect"):
+				return "172.17.0.2", nil
+			case addr == "10.0.0.2":
+				return "", fmt.Errorf("exit status 7")
+			default:
+				return "OK", nil
+			}
+		},
+	}
+
+	d, err := p.current(context.Background(), "backend", "production")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Tag != "new-tag" {
+		t.Errorf("tag = %q, want %q", d.Tag, "new-tag")
+	}
+	if d.Health != "unhealthy" {
+		t.Errorf("health = %q, want %q", d.Health, "unhealthy")
+	}
+	want := map[string]string{"web1": "new-tag", "web2": "old-tag"}
+	if !maps.Equal(d.NodeTags, want) {
+		t.Errorf("node tags = %v, want %v", d.NodeTags, want)
+	}
+	if !d.drifted() {
+		t.Error("expected drift")
+	}
+}
+
+func TestServerHistoryCurrentMultiNodeInSync(t *testing.T) {
+	cfg := multiNodeConfig(false)
+
+	p := &serverHistoryProvider{
+		cfg: cfg,
+		run: func(_ context.Context, _, cmd string) (string, error) {
+			if strings.HasPrefix(cmd, "docker ps") {
+				return "backend-new-tag\tUp 3 hours", nil
+			}
+			return "", nil
+		},
+	}
+
+	d, err := p.current(context.Background(), "backend", "production")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.drifted() {
+		t.Errorf("unexpected drift: %v", d.NodeTags)
+	}
+}
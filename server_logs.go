@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 )
 
@@ -12,10 +13,11 @@ type serverLogsProvider struct {
 	dial func(addr string) (sshRunner, error)
 }
 
-func (p *serverLogsProvider) tail(ctx context.Context, service, env string, n int, since string, w io.Writer) error {
+func (p *serverLogsProvider) tail(ctx context.Context, service, env string, n int, since string, follow bool, w io.Writer) error {
 	svc := p.cfg.Services[service]
 	ec := svc.Env[env]
-	addr := p.cfg.Nodes[ec.Node]
+	// Multi-node environments read from the first node.
+	addr := p.cfg.Nodes[ec.nodeNames()[0]]
 
 	client, err := p.dial(addr)
 	if err != nil {
@@ -24,26 +26,56 @@ func (p *serverLogsProvider) tail(ctx context.Context, service, env string, n in
 	defer client.close()
 
 	// Find running container.
-	psCmd := fmt.Sprintf(`docker ps --filter "name=%s-" --format "{{.Names}}"`, service)
+	psCmd := fmt.Sprintf(`docker ps %s --format "{{.Names}}"`, containerFilter(p.cfg, service, env))
 	out, err := client.run(ctx, psCmd)
 	if err != nil {
 		return fmt.Errorf("listing containers: %w", err)
 	}
 
-	// Docker's name filter is a substring match, so we must check the prefix ourselves.
-	prefix := service + "-"
-	var container string
-	for _, line := range strings.Split(out, "\n") {
-		if strings.HasPrefix(line, prefix) {
-			container = line
-			break
-		}
-	}
+	// Docker's name filter is a substring match, so we must check the name ourselves.
+	container := findContainer(p.cfg, service, env, strings.Split(out, "\n"))
 	if container == "" {
 		return fmt.Errorf("no running container for %s in %s", service, env)
 	}
 
-	follow := n == 0 && since == ""
+	return streamLogs(ctx, client, container, n, since, follow, w)
+}
+
+// tailTag reads the logs of the container for a specific tag, which may
+// already be stopped (e.g. the previous version after a deploy).
+func (p *serverLogsProvider) tailTag(ctx context.Context, service, env, tag string, n int, since string, follow bool, w io.Writer) error {
+	ec := p.cfg.Services[service].Env[env]
+	addr := p.cfg.Nodes[ec.nodeNames()[0]]
+
+	client, err := p.dial(addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer client.close()
+
+	// Containers started before container_name existed have the legacy name.
+	container := containerName(p.cfg, service, env, tag)
+	legacy := legacyContainerPrefix(service) + tag
+	filter := fmt.Sprintf(`--filter "name=^%s$"`, container)
+	if legacy != container {
+		filter += fmt.Sprintf(` --filter "name=^%s$"`, legacy)
+	}
+	out, err := client.run(ctx, fmt.Sprintf(`docker ps -a %s --format "{{.Names}}"`, filter))
+	if err != nil {
+		return fmt.Errorf("listing containers: %w", err)
+	}
+	names := strings.Fields(out)
+	if len(names) == 0 {
+		return fmt.Errorf("no container for %s %s in %s (it may have been removed)", service, tag, env)
+	}
+	if !slices.Contains(names, container) {
+		container = legacy
+	}
+
+	return streamLogs(ctx, client, container, n, since, follow, w)
+}
+
+func streamLogs(ctx context.Context, client sshRunner, container string, n int, since string, follow bool, w io.Writer) error {
 	args := dockerLogsArgs(container, since, n, follow)
 	cmd := "docker " + strings.Join(args, " ")
 
@@ -27,7 +27,7 @@ func TestServerLogsTailFindsContainer(t *testing.T) {
 		},
 	}
 
-	err := p.tail(context.Background(), "backend", "staging", 100, "", io.Discard)
+	err := p.tail(context.Background(), "backend", "staging", 100, "", false, io.Discard)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -63,8 +63,7 @@ func TestServerLogsTailFollowMode(t *testing.T) {
 		dial: func(_ string) (sshRunner, error) { return mock, nil },
 	}
 
-	// n=0 and since="" triggers follow mode
-	err := p.tail(context.Background(), "backend", "staging", 0, "", io.Discard)
+	err := p.tail(context.Background(), "backend", "staging", 0, "", true, io.Discard)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -74,6 +73,30 @@ func TestServerLogsTailFollowMode(t *testing.T) {
 	}
 }
 
+func TestServerLogsTailFollowWithTail(t *testing.T) {
+	cfg := testConfig()
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: "backend-main-abc1234-20250101000000"},
+			{output: ""},
+		},
+	}
+
+	p := &serverLogsProvider{
+		cfg:  cfg,
+		dial: func(_ string) (sshRunner, error) { return mock, nil },
+	}
+
+	err := p.tail(context.Background(), "backend", "staging", 100, "", true, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mock.commands[1] != "docker logs --tail 100 -f backend-main-abc1234-20250101000000" {
+		t.Errorf("cmd[1] = %q, want docker logs --tail 100 -f", mock.commands[1])
+	}
+}
+
 func TestServerLogsTailWithSince(t *testing.T) {
 	cfg := testConfig()
 	mock := &mockSSHRunner{
@@ -88,7 +111,7 @@ func TestServerLogsTailWithSince(t *testing.T) {
 		dial: func(_ string) (sshRunner, error) { return mock, nil },
 	}
 
-	err := p.tail(context.Background(), "backend", "staging", 50, "1h", io.Discard)
+	err := p.tail(context.Background(), "backend", "staging", 50, "1h", false, io.Discard)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -113,7 +136,7 @@ func TestServerLogsTailStreamsOutput(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	err := p.tail(context.Background(), "backend", "staging", 10, "", &buf)
+	err := p.tail(context.Background(), "backend", "staging", 10, "", false, &buf)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -136,7 +159,7 @@ func TestServerLogsTailNoContainer(t *testing.T) {
 		dial: func(_ string) (sshRunner, error) { return mock, nil },
 	}
 
-	err := p.tail(context.Background(), "backend", "staging", 100, "", io.Discard)
+	err := p.tail(context.Background(), "backend", "staging", 100, "", false, io.Discard)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -155,7 +178,7 @@ func TestServerLogsTailDialFailure(t *testing.T) {
 		},
 	}
 
-	err := p.tail(context.Background(), "backend", "staging", 100, "", io.Discard)
+	err := p.tail(context.Background(), "backend", "staging", 100, "", false, io.Discard)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -177,7 +200,7 @@ func TestServerLogsTailDockerPsFailure(t *testing.T) {
 		dial: func(_ string) (sshRunner, error) { return mock, nil },
 	}
 
-	err := p.tail(context.Background(), "backend", "staging", 100, "", io.Discard)
+	err := p.tail(context.Background(), "backend", "staging", 100, "", false, io.Discard)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -185,3 +208,47 @@ func TestServerLogsTailDockerPsFailure(t *testing.T) {
 		t.Errorf("expected 'listing containers' error, got: %v", err)
 	}
 }
+
+func TestServerLogsTailTag(t *testing.T) {
+	cfg := testConfig()
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: "backend-main-old1234-20241231000000"}, // docker ps -a
+			{output: ""}, // docker logs (stream)
+		},
+	}
+	p := &serverLogsProvider{
+		cfg:  cfg,
+		dial: func(_ string) (sshRunner, error) { return mock, nil },
+	}
+
+	err := p.tailTag(context.Background(), "backend", "staging", "main-old1234-20241231000000", 100, "", false, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mock.commands[0] != `docker ps -a --filter "name=^backend-staging-main-old1234-20241231000000$" --filter "name=^backend-main-old1234-20241231000000$" --format "{{.Names}}"` {
+		t.Errorf("cmd[0] = %q, want docker ps -a for the tagged container", mock.commands[0])
+	}
+	if mock.commands[1] != "docker logs --tail 100 backend-main-old1234-20241231000000" {
+		t.Errorf("cmd[1] = %q, want docker logs for the tagged container", mock.commands[1])
+	}
+}
+
+func TestServerLogsTailTagRemoved(t *testing.T) {
+	cfg := testConfig()
+	mock := &mockSSHRunner{
+		responses: []mockRunResult{
+			{output: ""}, // docker ps -a
+		},
+	}
+	p := &serverLogsProvider{
+		cfg:  cfg,
+		dial: func(_ string) (sshRunner, error) { return mock, nil },
+	}
+
+	err := p.tailTag(context.Background(), "backend", "staging", "main-old1234-20241231000000", 100, "", false, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "may have been removed") {
+		t.Errorf("expected removed container error, got: %v", err)
+	}
+}
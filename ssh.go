@@ -3,14 +3,21 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 type sshClient struct {
@@ -36,9 +43,137 @@ func parseSSHAddr(addr string) (user, hostport string) {
 	return user, host
 }
 
+// hostKeyError is returned when a node's host key can't be verified against
+// known_hosts, so it reads differently from a connectivity problem.
+type hostKeyError struct {
+	host   string
+	reason string
+}
+
+func (e *hostKeyError) Error() string {
+	return fmt.Sprintf("host key verification failed for %s: %s", e.host, e.reason)
+}
+
+// sshHostKeyCallback verifies host keys against ~/.ssh/known_hosts. Setting
+// HOIST_SSH_INSECURE=1 skips verification, for ephemeral hosts whose keys
+// change on every rebuild.
+func sshHostKeyCallback() (ssh.HostKeyCallback, error) {
+	if os.Getenv("HOIST_SSH_INSECURE") == "1" {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("finding known_hosts: %w", err)
+	}
+	return knownHostsCallback(filepath.Join(home, ".ssh", "known_hosts"))
+}
+
+func knownHostsCallback(path string) (ssh.HostKeyCallback, error) {
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w (set HOIST_SSH_INSECURE=1 to skip host key verification)", path, err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		host, _, splitErr := net.SplitHostPort(hostname)
+		if splitErr != nil {
+			host = hostname
+		}
+		var keyErr *knownhosts.KeyError
+		var revoked *knownhosts.RevokedError
+		switch {
+		case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+			return &hostKeyError{host: host, reason: fmt.Sprintf("key does not match %s:%d", keyErr.Want[0].Filename, keyErr.Want[0].Line)}
+		case errors.As(err, &keyErr):
+			return &hostKeyError{host: host, reason: fmt.Sprintf("host not in %s (run ssh-keyscan %s >> %s)", path, host, path)}
+		case errors.As(err, &revoked):
+			return &hostKeyError{host: host, reason: "key is revoked"}
+		}
+		return &hostKeyError{host: host, reason: err.Error()}
+	}, nil
+}
+
+// formatSSHAddr builds the address form parseSSHAddr reads from a user, host
+// and port. An empty user is left out so the default applies; a zero port
+// means 22, and is always written so IPv6 hosts come out bracketed.
+func formatSSHAddr(user, host string, port int) string {
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	if user != "" {
+		addr = user + "@" + addr
+	}
+	return addr
+}
+
+// execNodePrefix marks a node address that is the output of a command rather
+// than a fixed address, for fleets whose hosts change.
+const execNodePrefix = "exec:"
+
+// nodeExecTimeout bounds a node's exec command.
+const nodeExecTimeout = 30 * time.Second
+
+var nodeAddrs = struct {
+	mu    sync.Mutex
+	cache map[string]string
+}{cache: make(map[string]string)}
+
+// resolveNodeAddr returns the SSH address for a configured node address. Exec
+// addresses run their command through sh once per process and use the first
+// line of its output; any other address is returned unchanged.
+func resolveNodeAddr(addr string) (string, error) {
+	command, ok := strings.CutPrefix(addr, execNodePrefix)
+	if !ok {
+		return addr, nil
+	}
+	return resolveNodeExec(command, func(ctx context.Context, command string) ([]byte, error) {
+		return exec.CommandContext(ctx, "sh", "-c", command).Output()
+	})
+}
+
+func resolveNodeExec(command string, run func(ctx context.Context, command string) ([]byte, error)) (string, error) {
+	nodeAddrs.mu.Lock()
+	defer nodeAddrs.mu.Unlock()
+	if resolved, ok := nodeAddrs.cache[command]; ok {
+		return resolved, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), nodeExecTimeout)
+	defer cancel()
+	out, err := run(ctx, command)
+	if err != nil {
+		return "", fmt.Errorf("resolving node address with %q: %w", command, err)
+	}
+	resolved, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	resolved = strings.TrimSpace(resolved)
+	if resolved == "" {
+		return "", fmt.Errorf("resolving node address with %q: command printed nothing", command)
+	}
+	nodeAddrs.cache[command] = resolved
+	return resolved, nil
+}
+
+// dialNode resolves a configured node address and dials it.
+func dialNode(addr string) (sshRunner, error) {
+	resolved, err := resolveNodeAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	return sshDial(resolved)
+}
+
 func sshDial(addr string) (*sshClient, error) {
 	user, hostport := parseSSHAddr(addr)
 
+	hostKeyCallback, err := sshHostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
 	sock := os.Getenv("SSH_AUTH_SOCK")
 	if sock == "" {
 		return nil, fmt.Errorf("SSH_AUTH_SOCK not set")
@@ -55,11 +190,15 @@ func sshDial(addr string) (*sshClient, error) {
 		Auth: []ssh.AuthMethod{
 			ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers),
 		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: hostKeyCallback,
 	}
 
 	client, err := ssh.Dial("tcp", hostport, config)
 	if err != nil {
+		var hkErr *hostKeyError
+		if errors.As(err, &hkErr) {
+			return nil, hkErr
+		}
 		return nil, fmt.Errorf("SSH dial %s: %w", hostport, err)
 	}
 
@@ -67,6 +206,11 @@ func sshDial(addr string) (*sshClient, error) {
 }
 
 func (c *sshClient) run(ctx context.Context, cmd string) (string, error) {
+	return c.runWithStdin(ctx, cmd, nil)
+}
+
+// runWithStdin is run with stdin (if non-nil) fed to the command.
+func (c *sshClient) runWithStdin(ctx context.Context, cmd string, stdin io.Reader) (string, error) {
 	session, err := c.client.NewSession()
 	if err != nil {
 		return "", fmt.Errorf("creating SSH session: %w", err)
@@ -83,6 +227,7 @@ func (c *sshClient) run(ctx context.Context, cmd string) (string, error) {
 	}()
 
 	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
 	session.Stdout = &stdout
 	session.Stderr = &stderr
 
@@ -136,10 +281,87 @@ func (c *sshClient) close() error {
 
 // sshRun is a convenience function that dials, runs one command, and closes.
 func sshRun(ctx context.Context, addr, cmd string) (string, error) {
-	c, err := sshDial(addr)
+	c, err := dialNode(addr)
 	if err != nil {
 		return "", err
 	}
 	defer c.close()
 	return c.run(ctx, cmd)
 }
+
+// sshPool reuses one SSH connection per address for short commands, so fanning
+// out many queries to the same node opens a single connection to its sshd.
+// Connections stay open until close; commands run as separate sessions on the
+// shared connection, at most maxPoolSessions at a time. A failed dial is not
+// remembered, so the next command to the address dials again.
+type sshPool struct {
+	dial func(addr string) (sshRunner, error)
+
+	mu      sync.Mutex
+	entries map[string]*sshPoolEntry
+}
+
+// maxPoolSessions bounds concurrent sessions on a pooled connection, staying
+// within sshd's MaxSessions default of 10.
+const maxPoolSessions = 10
+
+type sshPoolEntry struct {
+	mu       sync.Mutex // held while dialing
+	client   sshRunner
+	sessions chan struct{}
+}
+
+func newSSHPool(dial func(addr string) (sshRunner, error)) *sshPool {
+	return &sshPool{dial: dial, entries: make(map[string]*sshPoolEntry)}
+}
+
+// run executes cmd on addr, dialing on first use. It has the same signature as
+// sshRun so it can be used as a history provider's run func.
+func (p *sshPool) run(ctx context.Context, addr, cmd string) (string, error) {
+	p.mu.Lock()
+	e, ok := p.entries[addr]
+	if !ok {
+		e = &sshPoolEntry{sessions: make(chan struct{}, maxPoolSessions)}
+		p.entries[addr] = e
+	}
+	p.mu.Unlock()
+
+	e.mu.Lock()
+	if e.client == nil {
+		client, err := p.dial(addr)
+		if err != nil {
+			e.mu.Unlock()
+			return "", err
+		}
+		e.client = client
+	}
+	client := e.client
+	e.mu.Unlock()
+
+	select {
+	case e.sessions <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-e.sessions }()
+	return client.run(ctx, cmd)
+}
+
+// close closes all pooled connections.
+func (p *sshPool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var firstErr error
+	for addr, e := range p.entries {
+		e.mu.Lock()
+		if e.client != nil {
+			if err := e.client.close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			e.client = nil
+		}
+		e.mu.Unlock()
+		delete(p.entries, addr)
+	}
+	return firstErr
+}
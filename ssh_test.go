@@ -1,6 +1,21 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
 
 func TestParseSSHAddr(t *testing.T) {
 	tests := []struct {
@@ -28,3 +43,233 @@ func TestParseSSHAddr(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatSSHAddr(t *testing.T) {
+	tests := []struct {
+		user, host string
+		port       int
+		want       string
+	}{
+		{"deploy", "10.0.0.1", 2222, "deploy@10.0.0.1:2222"},
+		{"", "host.example.com", 0, "host.example.com:22"},
+		{"admin", "2001:db8::1", 22, "admin@[2001:db8::1]:22"},
+	}
+	for _, tt := range tests {
+		addr := formatSSHAddr(tt.user, tt.host, tt.port)
+		if addr != tt.want {
+			t.Errorf("formatSSHAddr(%q, %q, %d) = %q, want %q", tt.user, tt.host, tt.port, addr, tt.want)
+		}
+		// parseSSHAddr must read back what formatSSHAddr writes.
+		user, hostport := parseSSHAddr(addr)
+		wantUser := tt.user
+		if wantUser == "" {
+			wantUser = "root"
+		}
+		if user != wantUser || hostport != strings.TrimPrefix(tt.want, tt.user+"@") {
+			t.Errorf("parseSSHAddr(%q) = %q, %q", addr, user, hostport)
+		}
+	}
+}
+
+func TestResolveNodeAddr(t *testing.T) {
+	addr, err := resolveNodeAddr("ubuntu@10.0.0.1")
+	if err != nil || addr != "ubuntu@10.0.0.1" {
+		t.Errorf("plain address = %q, %v; want it unchanged", addr, err)
+	}
+
+	addr, err = resolveNodeAddr("exec:printf 'deploy@10.0.0.9\\n10.0.0.10\\n'")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr != "deploy@10.0.0.9" {
+		t.Errorf("exec address = %q, want first line %q", addr, "deploy@10.0.0.9")
+	}
+}
+
+func TestResolveNodeExecCaches(t *testing.T) {
+	calls := 0
+	run := func(_ context.Context, _ string) ([]byte, error) {
+		calls++
+		return []byte("10.0.0.7\n"), nil
+	}
+	for range 2 {
+		addr, err := resolveNodeExec("test-resolve-caches", run)
+		if err != nil || addr != "10.0.0.7" {
+			t.Fatalf("resolveNodeExec = %q, %v", addr, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("command ran %d times, want 1", calls)
+	}
+}
+
+func TestResolveNodeExecErrors(t *testing.T) {
+	empty := func(_ context.Context, _ string) ([]byte, error) { return []byte("\n"), nil }
+	if _, err := resolveNodeExec("test-resolve-empty", empty); err == nil || !strings.Contains(err.Error(), "printed nothing") {
+		t.Errorf("expected empty output error, got: %v", err)
+	}
+
+	failing := func(_ context.Context, _ string) ([]byte, error) { return nil, errors.New("exit status 1") }
+	if _, err := resolveNodeExec("test-resolve-fails", failing); err == nil || !strings.Contains(err.Error(), "exit status 1") {
+		t.Errorf("expected command error, got: %v", err)
+	}
+}
+
+func TestSSHPoolReusesConnections(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.node("10.0.0.1").on("hostname", "web1", nil)
+	var mu sync.Mutex
+	dials := map[string]int{}
+	pool := newSSHPool(func(addr string) (sshRunner, error) {
+		mu.Lock()
+		dials[addr]++
+		mu.Unlock()
+		return cluster.dial(addr)
+	})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		for _, addr := range []string{"10.0.0.1", "10.0.0.2"} {
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+				if _, err := pool.run(context.Background(), addr, "hostname"); err != nil {
+					t.Errorf("run on %s: %v", addr, err)
+				}
+			}(addr)
+		}
+	}
+	wg.Wait()
+
+	if dials["10.0.0.1"] != 1 || dials["10.0.0.2"] != 1 {
+		t.Errorf("dials = %v, want one per address", dials)
+	}
+	if got := len(cluster.node("10.0.0.1").recorded()); got != 10 {
+		t.Errorf("10.0.0.1 ran %d commands, want 10", got)
+	}
+
+	if err := pool.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if cluster.node("10.0.0.1").closed != 1 {
+		t.Errorf("expected pooled connection to be closed once")
+	}
+}
+
+func TestSSHPoolDialError(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.unreachable["10.0.0.1"] = errors.New("connection refused")
+	pool := newSSHPool(cluster.dial)
+
+	for range 2 {
+		if _, err := pool.run(context.Background(), "10.0.0.1", "hostname"); err == nil {
+			t.Error("expected dial error")
+		}
+	}
+
+	// A failed dial isn't remembered: once the node is back, it's used.
+	cluster.mu.Lock()
+	delete(cluster.unreachable, "10.0.0.1")
+	cluster.mu.Unlock()
+	if _, err := pool.run(context.Background(), "10.0.0.1", "hostname"); err != nil {
+		t.Errorf("expected the pool to dial again, got: %v", err)
+	}
+}
+
+func TestSSHPoolBoundsSessions(t *testing.T) {
+	cluster := newFakeCluster()
+	var mu sync.Mutex
+	running, peak := 0, 0
+	cluster.node("10.0.0.1").onFunc("sleep", func(string) (string, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return "", nil
+	})
+	pool := newSSHPool(cluster.dial)
+
+	var wg sync.WaitGroup
+	for range 3 * maxPoolSessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pool.run(context.Background(), "10.0.0.1", "sleep 1"); err != nil {
+				t.Errorf("run: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > maxPoolSessions {
+		t.Errorf("peak concurrent sessions = %d, want at most %d", peak, maxPoolSessions)
+	}
+}
+
+func testHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKnownHostsCallback(t *testing.T) {
+	known := testHostKey(t)
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(knownhosts.Line([]string{"10.0.0.1"}, known)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	check, err := knownHostsCallback(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+
+	if err := check("10.0.0.1:22", remote, known); err != nil {
+		t.Errorf("known key: unexpected error: %v", err)
+	}
+
+	err = check("10.0.0.1:22", remote, testHostKey(t))
+	var hkErr *hostKeyError
+	if !errors.As(err, &hkErr) {
+		t.Fatalf("mismatched key: expected hostKeyError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "host key verification failed for 10.0.0.1") || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("mismatched key error = %q", err)
+	}
+
+	err = check("10.0.0.2:22", &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 22}, known)
+	if !errors.As(err, &hkErr) || !strings.Contains(err.Error(), "not in") {
+		t.Errorf("unknown host error = %v, want host not in known_hosts", err)
+	}
+}
+
+func TestKnownHostsCallbackMissingFile(t *testing.T) {
+	_, err := knownHostsCallback(filepath.Join(t.TempDir(), "known_hosts"))
+	if err == nil || !strings.Contains(err.Error(), "HOIST_SSH_INSECURE=1") {
+		t.Errorf("expected error pointing at HOIST_SSH_INSECURE, got %v", err)
+	}
+}
+
+func TestSSHHostKeyCallbackInsecure(t *testing.T) {
+	t.Setenv("HOIST_SSH_INSECURE", "1")
+	t.Setenv("HOME", t.TempDir()) // no known_hosts
+
+	check, err := sshHostKeyCallback()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := check("10.0.0.1:22", &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}, testHostKey(t)); err != nil {
+		t.Errorf("insecure mode should accept any key, got %v", err)
+	}
+}
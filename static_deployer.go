@@ -2,7 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
 	"strings"
 	"sync"
 	"time"
@@ -11,12 +16,16 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
 	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type s3DeployAPI interface {
 	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
 	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	s3GetObjectAPI
 }
 
 type cfInvalidateAPI interface {
@@ -27,20 +36,20 @@ type staticDeployer struct {
 	cfg        config
 	s3         s3DeployAPI
 	cloudfront cfInvalidateAPI
+
+	invalidateBestEffort bool          // log invalidation failures instead of failing the deploy
+	invalidateRetries    int           // 0 means use default (4)
+	invalidateBackoff    time.Duration // 0 means use default (1s); doubles per retry
+
+	// invalidateMu serializes CloudFront invalidations across services deployed
+	// in parallel by the same deployer, to stay under CloudFront's rate limits.
+	// S3 copies are not affected and still run concurrently.
+	invalidateMu sync.Mutex
 }
 
 func (d *staticDeployer) deploy(ctx context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error {
 	ec := d.cfg.Services[service].Env[env]
 	bucket := ec.Bucket
-	distID := ec.CloudFront
-
-	// Write previous-tag marker.
-	if oldTag != "" {
-		logf("writing previous-tag marker (%s) to s3://%s/previous-tag", oldTag, bucket)
-		if err := d.putMarker(ctx, bucket, "previous-tag", oldTag); err != nil {
-			return fmt.Errorf("writing previous-tag marker: %w", err)
-		}
-	}
 
 	// List build objects.
 	logf("listing build objects in s3://%s/builds/%s/", bucket, tag)
@@ -53,41 +62,193 @@ func (d *staticDeployer) deploy(ctx context.Context, service, env, tag, oldTag s
 	}
 	logf("found %d objects", len(keys))
 
-	// Copy build objects to current/.
+	// With prune, note what is live before the copy overwrites it.
 	buildPrefix := "builds/" + tag + "/"
+	var stale []string
+	if ec.Prune {
+		live, err := d.listObjects(ctx, bucket, "current/")
+		if err != nil {
+			return fmt.Errorf("listing s3://%s/current/: %w", bucket, err)
+		}
+		stale = staleObjects(live, keys, buildPrefix)
+	}
+
+	// Copy build objects to current/.
 	logf("copying %d objects from builds/%s/ to current/", len(keys), tag)
-	if err := d.copyObjects(ctx, bucket, buildPrefix, "current/", keys); err != nil {
+	if err := d.copyObjects(ctx, bucket, buildPrefix, "current/", keys, ec.CacheControl); err != nil {
 		return err
 	}
 	logf("objects copied")
 
+	// Markers are only written once the copy has succeeded, so a failed deploy
+	// leaves them describing what was live before.
+	if oldTag != "" {
+		logf("writing previous-tag marker (%s) to s3://%s/previous-tag", oldTag, bucket)
+		if err := d.putMarker(ctx, bucket, "previous-tag", oldTag); err != nil {
+			return fmt.Errorf("writing previous-tag marker: %w", err)
+		}
+	}
+
 	// Write current-tag marker.
 	logf("writing current-tag marker (%s) to s3://%s/current-tag", tag, bucket)
 	if err := d.putMarker(ctx, bucket, "current-tag", tag); err != nil {
 		return fmt.Errorf("writing current-tag marker: %w", err)
 	}
 
+	// Stale objects are only deleted once the new build is fully in place, so
+	// a failed copy leaves the old site working.
+	if len(stale) > 0 {
+		logf("deleting %d stale objects from current/", len(stale))
+		if err := d.deleteObjects(ctx, bucket, stale); err != nil {
+			// The deploy itself is live; only leftovers of the old build remain.
+			logf("warning: pruning current/ failed: %v", err)
+		}
+	}
+
+	if ec.DeployLog {
+		logf("appending to s3://%s/%s", bucket, deployLogKey)
+		if err := d.appendDeployLog(ctx, bucket, tag); err != nil {
+			// The deploy itself is live; only its history entry is missing.
+			logf("warning: appending to %s failed: %v", deployLogKey, err)
+		}
+	}
+
 	// Invalidate CloudFront.
-	logf("invalidating CloudFront distribution %s", distID)
+	if d.cfg.S3Endpoint != "" {
+		logf("custom S3 endpoint configured, skipping CloudFront invalidation")
+		return nil
+	}
+	for _, distID := range ec.CloudFront {
+		logf("invalidating %s on CloudFront distribution %s", strings.Join(ec.invalidationPaths(), ", "), distID)
+		if err := d.invalidate(ctx, distID, tag, ec.invalidationPaths(), logf); err != nil {
+			if !d.invalidateBestEffort {
+				return fmt.Errorf("invalidating CloudFront %s: %w", distID, err)
+			}
+			// Content is already live in S3; only the CDN cache is stale.
+			logf("warning: invalidating CloudFront %s failed: %v", distID, err)
+			continue
+		}
+		logf("CloudFront invalidation created")
+	}
+
+	return nil
+}
+
+// plan lists the build objects deploy would copy and logs the markers and
+// invalidations it would write. It only reads from S3.
+func (d *staticDeployer) plan(ctx context.Context, service, env, tag, oldTag string, logf func(string, ...any)) error {
+	ec := d.cfg.Services[service].Env[env]
+	bucket := ec.Bucket
+
+	keys, err := d.listBuildObjects(ctx, bucket, tag)
+	if err != nil {
+		return fmt.Errorf("listing build objects in s3://%s/builds/%s/: %w", bucket, tag, err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("build not found: s3://%s/builds/%s/", bucket, tag)
+	}
+
+	buildPrefix := "builds/" + tag + "/"
+	logf("would copy %d objects from s3://%s/%s to current/:", len(keys), bucket, buildPrefix)
+	for _, key := range keys {
+		relKey := strings.TrimPrefix(key, buildPrefix)
+		if cc := cacheControlFor(ec.CacheControl, relKey); cc != "" {
+			logf("  %s (Cache-Control: %s)", relKey, cc)
+			continue
+		}
+		logf("  %s", relKey)
+	}
+	if oldTag != "" {
+		logf("would write previous-tag marker (%s)", oldTag)
+	}
+	logf("would write current-tag marker (%s)", tag)
+	if ec.Prune {
+		live, err := d.listObjects(ctx, bucket, "current/")
+		if err != nil {
+			return fmt.Errorf("listing s3://%s/current/: %w", bucket, err)
+		}
+		if stale := staleObjects(live, keys, buildPrefix); len(stale) > 0 {
+			logf("would delete %d stale objects from current/:", len(stale))
+			for _, key := range stale {
+				logf("  %s", strings.TrimPrefix(key, "current/"))
+			}
+		}
+	}
+	if ec.DeployLog {
+		logf("would append to %s", deployLogKey)
+	}
+
+	if d.cfg.S3Endpoint != "" {
+		logf("custom S3 endpoint configured, would skip CloudFront invalidation")
+		return nil
+	}
+	for _, distID := range ec.CloudFront {
+		logf("would invalidate %s on CloudFront distribution %s", strings.Join(ec.invalidationPaths(), ", "), distID)
+	}
+	return nil
+}
+
+// invalidate creates a CloudFront invalidation, retrying with exponential
+// backoff when CloudFront throttles or returns a server error.
+func (d *staticDeployer) invalidate(ctx context.Context, distID, tag string, paths []string, logf func(string, ...any)) error {
+	d.invalidateMu.Lock()
+	defer d.invalidateMu.Unlock()
+
+	retries := d.invalidateRetries
+	if retries == 0 {
+		retries = 4
+	}
+	backoff := d.invalidateBackoff
+	if backoff == 0 {
+		backoff = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := d.createInvalidation(ctx, distID, tag, paths)
+		if err == nil || attempt >= retries || !isRetryableInvalidationError(err) {
+			return err
+		}
+		logf("CloudFront invalidation failed (%v), retrying in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (d *staticDeployer) createInvalidation(ctx context.Context, distID, tag string, paths []string) error {
 	callerRef := fmt.Sprintf("hoist-%s-%d", tag, time.Now().UnixNano())
-	path := "/*"
-	quantity := int32(1)
-	_, err = d.cloudfront.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
+	quantity := int32(len(paths))
+	_, err := d.cloudfront.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
 		DistributionId: &distID,
 		InvalidationBatch: &cftypes.InvalidationBatch{
 			CallerReference: &callerRef,
 			Paths: &cftypes.Paths{
 				Quantity: &quantity,
-				Items:    []string{path},
+				Items:    paths,
 			},
 		},
 	})
-	if err != nil {
-		return fmt.Errorf("invalidating CloudFront %s: %w", distID, err)
-	}
-	logf("CloudFront invalidation created")
+	return err
+}
 
-	return nil
+// isRetryableInvalidationError reports whether a CreateInvalidation error is
+// worth retrying: throttling responses and 5xx server errors.
+func isRetryableInvalidationError(err error) bool {
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		switch coded.ErrorCode() {
+		case "Throttling", "ThrottlingException", "TooManyRequestsException", "TooManyInvalidationsInProgress":
+			return true
+		}
+	}
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) && status.HTTPStatusCode() >= 500 {
+		return true
+	}
+	return false
 }
 
 func (d *staticDeployer) putMarker(ctx context.Context, bucket, key, value string) error {
@@ -100,9 +261,42 @@ func (d *staticDeployer) putMarker(ctx context.Context, bucket, key, value strin
 	return err
 }
 
+// deployLogKey is the object static deploys are recorded in when deploy_log
+// is enabled, one "<time>\t<tag>\t<user>" line per deploy.
+const deployLogKey = "deploys.log"
+
+// appendDeployLog adds a line for tag to the deploy log. S3 has no append, so
+// the object is read and rewritten; two deploys to the same bucket at once
+// can race and lose a line.
+func (d *staticDeployer) appendDeployLog(ctx context.Context, bucket, tag string) error {
+	var existing []byte
+	out, err := d.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    aws.String(deployLogKey),
+	})
+	if err != nil {
+		var nsk *s3types.NoSuchKey
+		if !errors.As(err, &nsk) {
+			return fmt.Errorf("reading %s: %w", deployLogKey, err)
+		}
+	} else {
+		existing, err = io.ReadAll(out.Body)
+		out.Body.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s body: %w", deployLogKey, err)
+		}
+	}
+
+	line := fmt.Sprintf("%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), tag, os.Getenv("USER"))
+	return d.putMarker(ctx, bucket, deployLogKey, string(existing)+line)
+}
+
 func (d *staticDeployer) listBuildObjects(ctx context.Context, bucket, tag string) ([]string, error) {
+	return d.listObjects(ctx, bucket, "builds/"+tag+"/")
+}
+
+func (d *staticDeployer) listObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
 	var keys []string
-	prefix := "builds/" + tag + "/"
 	input := &s3.ListObjectsV2Input{
 		Bucket: &bucket,
 		Prefix: &prefix,
@@ -127,7 +321,106 @@ func (d *staticDeployer) listBuildObjects(ctx context.Context, bucket, tag strin
 	return keys, nil
 }
 
-func (d *staticDeployer) copyObjects(ctx context.Context, bucket, srcPrefix, dstPrefix string, keys []string) error {
+// staleObjects returns the keys under current/ that the build being deployed,
+// whose keys are under buildPrefix, doesn't have.
+func staleObjects(live, buildKeys []string, buildPrefix string) []string {
+	keep := make(map[string]bool, len(buildKeys))
+	for _, key := range buildKeys {
+		keep["current/"+strings.TrimPrefix(key, buildPrefix)] = true
+	}
+	var stale []string
+	for _, key := range live {
+		if !keep[key] {
+			stale = append(stale, key)
+		}
+	}
+	return stale
+}
+
+// deleteObjects deletes keys in batches of 1000, the most DeleteObjects
+// accepts per request.
+func (d *staticDeployer) deleteObjects(ctx context.Context, bucket string, keys []string) error {
+	const batchSize = 1000
+	for start := 0; start < len(keys); start += batchSize {
+		batch := keys[start:min(start+batchSize, len(keys))]
+		ids := make([]s3types.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			ids[i] = s3types.ObjectIdentifier{Key: aws.String(key)}
+		}
+		out, err := d.s3.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: &bucket,
+			Delete: &s3types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("deleting objects in s3://%s: %w", bucket, err)
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return fmt.Errorf("deleting s3://%s/%s: %s (%d objects failed)", bucket, aws.ToString(e.Key), aws.ToString(e.Message), len(out.Errors))
+		}
+	}
+	return nil
+}
+
+// cacheControlFor returns the Cache-Control header the cache_control globs
+// give relKey, or "" if none match. A pattern without a "/" matches the file
+// name, one with a "/" the whole key under current/. When several match, the
+// longest pattern wins.
+func cacheControlFor(rules map[string]string, relKey string) string {
+	best := ""
+	for pattern := range rules {
+		name := relKey
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(relKey)
+		}
+		if ok, _ := path.Match(pattern, name); !ok {
+			continue
+		}
+		if best == "" || len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best) {
+			best = pattern
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return rules[best]
+}
+
+// contentTypeFor guesses a Content-Type from the key's extension. Copies that
+// replace metadata need it, since S3 doesn't keep the source's.
+func contentTypeFor(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// replaceMetadata sets input up to copy with Cache-Control cc. REPLACE
+// drops every header not given, so the source's are read and carried over.
+func (d *staticDeployer) replaceMetadata(ctx context.Context, bucket, key, relKey, cc string, input *s3.CopyObjectInput) error {
+	head, err := d.s3.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return fmt.Errorf("reading metadata of s3://%s/%s: %w", bucket, key, err)
+	}
+	input.MetadataDirective = s3types.MetadataDirectiveReplace
+	input.CacheControl = aws.String(cc)
+	input.ContentType = head.ContentType
+	if aws.ToString(input.ContentType) == "" {
+		input.ContentType = aws.String(contentTypeFor(relKey))
+	}
+	input.ContentEncoding = head.ContentEncoding
+	input.ContentLanguage = head.ContentLanguage
+	input.ContentDisposition = head.ContentDisposition
+	input.Expires = head.Expires
+	input.WebsiteRedirectLocation = head.WebsiteRedirectLocation
+	input.Metadata = head.Metadata
+	return nil
+}
+
+// copyObjects copies keys from srcPrefix to dstPrefix. Objects matching a
+// cacheControl glob get that Cache-Control header, on top of the source's
+// metadata; the rest are plain copies that keep their metadata.
+func (d *staticDeployer) copyObjects(ctx context.Context, bucket, srcPrefix, dstPrefix string, keys []string, cacheControl map[string]string) error {
 	const maxWorkers = 20
 
 	sem := make(chan struct{}, maxWorkers)
@@ -146,15 +439,25 @@ func (d *staticDeployer) copyObjects(ctx context.Context, bucket, srcPrefix, dst
 			dst := dstPrefix + relKey
 			src := bucket + "/" + key
 
-			_, err := d.s3.CopyObject(ctx, &s3.CopyObjectInput{
+			input := &s3.CopyObjectInput{
 				Bucket:     &bucket,
 				Key:        aws.String(dst),
 				CopySource: aws.String(src),
-			})
+			}
+			var err error
+			if cc := cacheControlFor(cacheControl, relKey); cc != "" {
+				err = d.replaceMetadata(ctx, bucket, key, relKey, cc, input)
+			}
+			if err == nil {
+				_, err = d.s3.CopyObject(ctx, input)
+				if err != nil {
+					err = fmt.Errorf("copying s3://%s/%s to s3://%s/%s: %w", bucket, key, bucket, dst, err)
+				}
+			}
 			if err != nil {
 				mu.Lock()
 				if firstErr == nil {
-					firstErr = fmt.Errorf("copying s3://%s/%s to s3://%s/%s: %w", bucket, key, bucket, dst, err)
+					firstErr = err
 				}
 				mu.Unlock()
 			}
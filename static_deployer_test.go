@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
@@ -16,26 +18,30 @@ import (
 )
 
 type stubS3Deploy struct {
-	mu         sync.Mutex
-	listPages  []s3.ListObjectsV2Output
-	copyInputs []s3.CopyObjectInput
-	putInputs  []s3.PutObjectInput
-	listErr    error
-	copyErr    error
-	putErr     error
+	mu           sync.Mutex
+	listPages    []s3.ListObjectsV2Output
+	copyInputs   []s3.CopyObjectInput
+	putInputs    []s3.PutObjectInput
+	deleteInputs []s3.DeleteObjectsInput
+	objects      map[string]string              // bucket/key -> body, served by GetObject
+	heads        map[string]s3.HeadObjectOutput // bucket/key -> metadata, served by HeadObject
+	listErr      error
+	copyErr      error
+	putErr       error
+	deleteErr    error
 }
 
 func (s *stubS3Deploy) ListObjectsV2(_ context.Context, _ *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
 	if s.listErr != nil {
 		return nil, s.listErr
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if len(s.listPages) == 0 {
 		return &s3.ListObjectsV2Output{}, nil
 	}
-	s.mu.Lock()
 	page := s.listPages[0]
 	s.listPages = s.listPages[1:]
-	s.mu.Unlock()
 	return &page, nil
 }
 
@@ -49,6 +55,11 @@ func (s *stubS3Deploy) CopyObject(_ context.Context, params *s3.CopyObjectInput,
 	return &s3.CopyObjectOutput{}, nil
 }
 
+func (s *stubS3Deploy) HeadObject(_ context.Context, params *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	head := s.heads[*params.Bucket+"/"+*params.Key]
+	return &head, nil
+}
+
 func (s *stubS3Deploy) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -59,6 +70,26 @@ func (s *stubS3Deploy) PutObject(_ context.Context, params *s3.PutObjectInput, _
 	return &s3.PutObjectOutput{}, nil
 }
 
+func (s *stubS3Deploy) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteInputs = append(s.deleteInputs, *params)
+	if s.deleteErr != nil {
+		return nil, s.deleteErr
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func (s *stubS3Deploy) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.objects[*params.Bucket+"/"+*params.Key]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
 type stubCFInvalidate struct {
 	mu    sync.Mutex
 	input *cloudfront.CreateInvalidationInput
@@ -149,6 +180,214 @@ func TestStaticDeployHappyPath(t *testing.T) {
 	}
 }
 
+func TestStaticDeployInvalidationPaths(t *testing.T) {
+	cfg := testConfig()
+	envCfg := cfg.Services["frontend"].Env["staging"]
+	envCfg.InvalidationPaths = []string{"/index.html", "/asset-manifest.json"}
+	cfg.Services["frontend"].Env["staging"] = envCfg
+
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	cf := &stubCFInvalidate{}
+
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf}
+
+	err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "main-old1234-20241231000000", nopLogf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cf.input == nil {
+		t.Fatal("expected CloudFront invalidation")
+	}
+	paths := cf.input.InvalidationBatch.Paths
+	if *paths.Quantity != 2 {
+		t.Errorf("Quantity = %d, want 2", *paths.Quantity)
+	}
+	if len(paths.Items) != 2 || paths.Items[0] != "/index.html" || paths.Items[1] != "/asset-manifest.json" {
+		t.Errorf("invalidation paths = %v, want [/index.html /asset-manifest.json]", paths.Items)
+	}
+}
+
+func TestStaticDeployPrune(t *testing.T) {
+	for _, prune := range []bool{true, false} {
+		t.Run(fmt.Sprintf("prune=%v", prune), func(t *testing.T) {
+			cfg := testConfig()
+			envCfg := cfg.Services["frontend"].Env["staging"]
+			envCfg.Prune = prune
+			cfg.Services["frontend"].Env["staging"] = envCfg
+
+			// With prune, the second listing is of current/.
+			stub := &stubS3Deploy{
+				listPages: []s3.ListObjectsV2Output{
+					{Contents: s3Objects(
+						"builds/main-abc1234-20250101000000/index.html",
+						"builds/main-abc1234-20250101000000/app.js",
+					)},
+					{Contents: s3Objects("current/index.html", "current/app.js", "current/old.js")},
+				},
+			}
+			d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: &stubCFInvalidate{}}
+
+			err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !prune {
+				if len(stub.deleteInputs) != 0 {
+					t.Errorf("expected no DeleteObjects calls, got %d", len(stub.deleteInputs))
+				}
+				return
+			}
+			if len(stub.deleteInputs) != 1 {
+				t.Fatalf("expected 1 DeleteObjects call, got %d", len(stub.deleteInputs))
+			}
+			objs := stub.deleteInputs[0].Delete.Objects
+			if len(objs) != 1 || *objs[0].Key != "current/old.js" {
+				t.Errorf("deleted objects = %+v, want [current/old.js]", objs)
+			}
+		})
+	}
+}
+
+func TestStaticDeployPruneSkippedOnCopyFailure(t *testing.T) {
+	cfg := testConfig()
+	envCfg := cfg.Services["frontend"].Env["staging"]
+	envCfg.Prune = true
+	cfg.Services["frontend"].Env["staging"] = envCfg
+
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+			{Contents: s3Objects("current/index.html", "current/old.js")},
+		},
+		copyErr: fmt.Errorf("access denied"),
+	}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: &stubCFInvalidate{}}
+
+	err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err == nil {
+		t.Fatal("expected copy error")
+	}
+	if len(stub.deleteInputs) != 0 {
+		t.Errorf("expected no DeleteObjects calls after a failed copy, got %d", len(stub.deleteInputs))
+	}
+}
+
+func TestStaticDeployCacheControl(t *testing.T) {
+	cfg := testConfig()
+	envCfg := cfg.Services["frontend"].Env["staging"]
+	envCfg.CacheControl = map[string]string{
+		"*.html":      "no-cache",
+		"*.js":        "max-age=31536000",
+		"sw/*.js":     "no-cache",
+		"favicon.ico": "max-age=86400",
+	}
+	cfg.Services["frontend"].Env["staging"] = envCfg
+
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects(
+				"builds/main-abc1234-20250101000000/index.html",
+				"builds/main-abc1234-20250101000000/assets/app.js",
+				"builds/main-abc1234-20250101000000/sw/worker.js",
+				"builds/main-abc1234-20250101000000/robots.txt",
+			)},
+		},
+	}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: &stubCFInvalidate{}}
+
+	err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Content types come from the extension; the exact value for .js depends
+	// on the system's mime tables.
+	want := map[string][2]string{
+		"current/index.html":    {"no-cache", "html"},
+		"current/assets/app.js": {"max-age=31536000", "javascript"},
+		"current/sw/worker.js":  {"no-cache", "javascript"},
+	}
+	for _, c := range stub.copyInputs {
+		w, ok := want[*c.Key]
+		if !ok {
+			if c.CacheControl != nil || c.MetadataDirective != "" {
+				t.Errorf("%s: expected a plain copy, got CacheControl=%v MetadataDirective=%q", *c.Key, c.CacheControl, c.MetadataDirective)
+			}
+			continue
+		}
+		if aws.ToString(c.CacheControl) != w[0] {
+			t.Errorf("%s: CacheControl = %q, want %q", *c.Key, aws.ToString(c.CacheControl), w[0])
+		}
+		if !strings.Contains(aws.ToString(c.ContentType), w[1]) {
+			t.Errorf("%s: ContentType = %q, want %s", *c.Key, aws.ToString(c.ContentType), w[1])
+		}
+		if c.MetadataDirective != s3types.MetadataDirectiveReplace {
+			t.Errorf("%s: MetadataDirective = %q, want REPLACE", *c.Key, c.MetadataDirective)
+		}
+	}
+}
+
+func TestStaticDeployCacheControlKeepsMetadata(t *testing.T) {
+	cfg := testConfig()
+	ec := cfg.Services["frontend"].Env["staging"]
+	ec.CacheControl = map[string]string{"*.html": "no-cache"}
+	cfg.Services["frontend"].Env["staging"] = ec
+
+	src := "builds/main-abc1234-20250101000000/index.html"
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{{Contents: s3Objects(src)}},
+		heads: map[string]s3.HeadObjectOutput{
+			ec.Bucket + "/" + src: {
+				ContentType:     aws.String("text/html; charset=utf-8"),
+				ContentEncoding: aws.String("gzip"),
+				Metadata:        map[string]string{"build": "abc1234"},
+			},
+		},
+	}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: &stubCFInvalidate{}}
+
+	if err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := stub.copyInputs[0]
+	if aws.ToString(c.CacheControl) != "no-cache" {
+		t.Errorf("CacheControl = %q, want no-cache", aws.ToString(c.CacheControl))
+	}
+	if aws.ToString(c.ContentType) != "text/html; charset=utf-8" {
+		t.Errorf("ContentType = %q, want the source's", aws.ToString(c.ContentType))
+	}
+	if aws.ToString(c.ContentEncoding) != "gzip" {
+		t.Errorf("ContentEncoding = %q, want gzip", aws.ToString(c.ContentEncoding))
+	}
+	if c.Metadata["build"] != "abc1234" {
+		t.Errorf("Metadata = %v, want the source's", c.Metadata)
+	}
+}
+
+func TestStaticDeployNoCacheControlPlainCopy(t *testing.T) {
+	cfg := testConfig()
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: &stubCFInvalidate{}}
+
+	if err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := stub.copyInputs[0]
+	if c.CacheControl != nil || c.ContentType != nil || c.MetadataDirective != "" {
+		t.Errorf("expected a plain copy, got CacheControl=%v ContentType=%v MetadataDirective=%q", c.CacheControl, c.ContentType, c.MetadataDirective)
+	}
+}
+
 func TestStaticDeployNoOldTag(t *testing.T) {
 	cfg := testConfig()
 	stub := &stubS3Deploy{
@@ -353,7 +592,7 @@ func TestStaticDeployLogOutput(t *testing.T) {
 		"copying 2 objects",
 		"objects copied",
 		"writing current-tag marker",
-		"invalidating CloudFront",
+		"invalidating /* on CloudFront distribution E1234567890",
 		"CloudFront invalidation created",
 	}
 	for _, e := range expected {
@@ -362,3 +601,327 @@ func TestStaticDeployLogOutput(t *testing.T) {
 		}
 	}
 }
+
+// countingCFInvalidate tracks the maximum number of concurrent invalidations.
+type countingCFInvalidate struct {
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+	calls    int
+}
+
+func (c *countingCFInvalidate) CreateInvalidation(_ context.Context, _ *cloudfront.CreateInvalidationInput, _ ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.calls++
+	c.maxSeen = max(c.maxSeen, c.inFlight)
+	c.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	return &cloudfront.CreateInvalidationOutput{}, nil
+}
+
+func TestStaticDeploySerializesInvalidations(t *testing.T) {
+	cfg := testConfig()
+	site := cfg.Services["frontend"]
+	cfg.Services["docs"] = site
+	cfg.Services["admin"] = site
+
+	stub := &stubS3Deploy{}
+	for range 3 {
+		stub.listPages = append(stub.listPages, s3.ListObjectsV2Output{
+			Contents: s3Objects("builds/main-abc1234-20250101000000/index.html"),
+		})
+	}
+	cf := &countingCFInvalidate{}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf}
+
+	var wg sync.WaitGroup
+	for _, svc := range []string{"frontend", "docs", "admin"} {
+		wg.Add(1)
+		go func(svc string) {
+			defer wg.Done()
+			if err := d.deploy(context.Background(), svc, "staging", "main-abc1234-20250101000000", "", nopLogf); err != nil {
+				t.Errorf("%s: unexpected error: %v", svc, err)
+			}
+		}(svc)
+	}
+	wg.Wait()
+
+	if cf.calls != 3 {
+		t.Fatalf("expected 3 invalidations, got %d", cf.calls)
+	}
+	if cf.maxSeen != 1 {
+		t.Errorf("expected invalidations to be serialized, saw %d in flight", cf.maxSeen)
+	}
+}
+
+type codedError struct {
+	code string
+}
+
+func (e *codedError) Error() string     { return e.code }
+func (e *codedError) ErrorCode() string { return e.code }
+
+// flakyCFInvalidate fails with the queued errors before succeeding.
+type flakyCFInvalidate struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyCFInvalidate) CreateInvalidation(_ context.Context, _ *cloudfront.CreateInvalidationInput, _ ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error) {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &cloudfront.CreateInvalidationOutput{}, nil
+}
+
+func TestStaticDeployRetriesThrottledInvalidation(t *testing.T) {
+	cfg := testConfig()
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	cf := &flakyCFInvalidate{errs: []error{
+		&codedError{"Throttling"},
+		&codedError{"TooManyInvalidationsInProgress"},
+	}}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf, invalidateBackoff: time.Millisecond}
+
+	err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cf.calls != 3 {
+		t.Errorf("expected 3 invalidation attempts, got %d", cf.calls)
+	}
+}
+
+func TestStaticDeployInvalidationRetriesExhausted(t *testing.T) {
+	cfg := testConfig()
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	throttled := &codedError{"Throttling"}
+	cf := &flakyCFInvalidate{errs: []error{throttled, throttled, throttled}}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf, invalidateRetries: 2, invalidateBackoff: time.Millisecond}
+
+	err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "invalidating CloudFront") {
+		t.Errorf("expected 'invalidating CloudFront' error, got: %v", err)
+	}
+	if cf.calls != 3 {
+		t.Errorf("expected 3 invalidation attempts, got %d", cf.calls)
+	}
+}
+
+func TestStaticDeployInvalidationNoRetryOnClientError(t *testing.T) {
+	cfg := testConfig()
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	cf := &flakyCFInvalidate{errs: []error{&codedError{"AccessDenied"}}}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf, invalidateBackoff: time.Millisecond}
+
+	err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if cf.calls != 1 {
+		t.Errorf("expected 1 invalidation attempt, got %d", cf.calls)
+	}
+}
+
+func TestStaticDeployInvalidationBestEffort(t *testing.T) {
+	cfg := testConfig()
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	cf := &stubCFInvalidate{err: fmt.Errorf("service unavailable")}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf, invalidateBestEffort: true}
+
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	logf := newServiceLogf(&buf, &mu, "frontend", 8)
+	err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", logf)
+	if err != nil {
+		t.Fatalf("expected success with best-effort invalidation, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "warning: invalidating CloudFront E1234567890 failed") {
+		t.Errorf("expected warning in output, got:\n%s", buf.String())
+	}
+}
+
+// recordingCFInvalidate records the distribution ID of every invalidation.
+type recordingCFInvalidate struct {
+	dists []string
+}
+
+func (r *recordingCFInvalidate) CreateInvalidation(_ context.Context, params *cloudfront.CreateInvalidationInput, _ ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error) {
+	r.dists = append(r.dists, *params.DistributionId)
+	return &cloudfront.CreateInvalidationOutput{}, nil
+}
+
+func TestStaticDeployMultipleDistributions(t *testing.T) {
+	cfg := testConfig()
+	site := cfg.Services["frontend"]
+	ec := site.Env["production"]
+	ec.CloudFront = stringList{"EUS123", "EEU456"}
+	site.Env = map[string]envConfig{"production": ec}
+	cfg.Services["frontend"] = site
+
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	cf := &recordingCFInvalidate{}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf}
+
+	err := d.deploy(context.Background(), "frontend", "production", "main-abc1234-20250101000000", "", nopLogf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(cf.dists, ",") != "EUS123,EEU456" {
+		t.Errorf("invalidated distributions = %v, want [EUS123 EEU456]", cf.dists)
+	}
+}
+
+func TestStaticDeployCustomEndpointSkipsCloudFront(t *testing.T) {
+	cfg := testConfig()
+	cfg.S3Endpoint = "http://localhost:9000"
+
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	cf := &recordingCFInvalidate{}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf}
+
+	err := d.deploy(context.Background(), "frontend", "production", "main-abc1234-20250101000000", "", nopLogf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cf.dists) != 0 {
+		t.Errorf("expected no CloudFront invalidations, got %v", cf.dists)
+	}
+	if len(stub.copyInputs) != 1 {
+		t.Errorf("expected 1 copy, got %d", len(stub.copyInputs))
+	}
+}
+
+func TestStaticDeployAppendsDeployLog(t *testing.T) {
+	cfg := testConfig()
+	ec := cfg.Services["frontend"].Env["staging"]
+	ec.DeployLog = true
+	cfg.Services["frontend"].Env["staging"] = ec
+
+	previous := "2025-01-01T00:00:00Z\tmain-old1234-20241231000000\talice\n"
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+		objects: map[string]string{"frontend-staging/deploys.log": previous},
+	}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: &stubCFInvalidate{}}
+
+	if err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "main-old1234-20241231000000", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var keys []string
+	var log string
+	for _, p := range stub.putInputs {
+		keys = append(keys, *p.Key)
+		if *p.Key == "deploys.log" {
+			body, _ := io.ReadAll(p.Body)
+			log = string(body)
+		}
+	}
+	if strings.Join(keys, ",") != "previous-tag,current-tag,deploys.log" {
+		t.Errorf("put keys = %v, want markers then deploys.log", keys)
+	}
+	if !strings.HasPrefix(log, previous) {
+		t.Errorf("deploys.log should keep earlier entries, got %q", log)
+	}
+	lines := strings.Split(strings.TrimSuffix(log, "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "\tmain-abc1234-20250101000000\t") {
+		t.Errorf("deploys.log = %q, want a new line for the deployed tag", log)
+	}
+}
+
+func TestStaticDeployLogDisabledByDefault(t *testing.T) {
+	cfg := testConfig()
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects("builds/main-abc1234-20250101000000/index.html")},
+		},
+	}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: &stubCFInvalidate{}}
+
+	if err := d.deploy(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range stub.putInputs {
+		if *p.Key == "deploys.log" {
+			t.Error("deploys.log should not be written unless deploy_log is set")
+		}
+	}
+}
+
+func TestStaticDeployerPlan(t *testing.T) {
+	cfg := testConfig()
+	stub := &stubS3Deploy{
+		listPages: []s3.ListObjectsV2Output{
+			{Contents: s3Objects(
+				"builds/main-abc1234-20250101000000/index.html",
+				"builds/main-abc1234-20250101000000/app.js",
+			)},
+		},
+	}
+	cf := &stubCFInvalidate{}
+	d := &staticDeployer{cfg: cfg, s3: stub, cloudfront: cf}
+
+	var logs []string
+	logf := func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	if err := d.plan(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "main-old1234-20241231000000", logf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(stub.copyInputs) != 0 || len(stub.putInputs) != 0 || cf.input != nil {
+		t.Fatal("plan should not copy, write markers or invalidate")
+	}
+	joined := strings.Join(logs, "\n")
+	for _, want := range []string{"would copy 2 objects", "  index.html", "  app.js", "previous-tag marker (main-old1234-20241231000000)", "would invalidate /* on CloudFront"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("plan should mention %q, got:\n%s", want, joined)
+		}
+	}
+}
+
+func TestStaticDeployerPlanMissingBuild(t *testing.T) {
+	d := &staticDeployer{cfg: testConfig(), s3: &stubS3Deploy{}, cloudfront: &stubCFInvalidate{}}
+
+	err := d.plan(context.Background(), "frontend", "staging", "main-abc1234-20250101000000", "", nopLogf)
+	if err == nil || !strings.Contains(err.Error(), "build not found") {
+		t.Errorf("expected build not found, got: %v", err)
+	}
+}
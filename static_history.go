@@ -26,10 +26,111 @@ func (p *staticHistoryProvider) current(ctx context.Context, service, env string
 	return p.readMarker(ctx, service, env, "current-tag")
 }
 
+// previous returns the deploy before the live one according to deploys.log
+// when deploy_log is enabled (see deployLogPrevious), falling back to the
+// previous-tag marker when the log is missing or has fewer than two entries.
+// Once rollbacks have unwound the log to its first entry there is no previous
+// deploy: the marker would point back at the build rolled away from.
 func (p *staticHistoryProvider) previous(ctx context.Context, service, env string) (deploy, error) {
+	if p.cfg.Services[service].Env[env].DeployLog {
+		entries, err := p.readDeployLog(ctx, service, env)
+		if err != nil {
+			return deploy{}, err
+		}
+		if len(entries) >= 2 {
+			e, ok := deployLogPrevious(entries)
+			if !ok {
+				return deploy{}, nil
+			}
+			return deploy{Service: service, Env: env, Tag: e.Tag, Uptime: p.since(e.Time)}, nil
+		}
+	}
 	return p.readMarker(ctx, service, env, "previous-tag")
 }
 
+// deployLogPrevious replays the log as a stack of live deploys and returns the
+// one below the top. The log doesn't say which lines are rollbacks, so a line
+// going back to the entry below the top is taken as one and pops the stack:
+// after A, B, C and a rollback to B, the previous deploy is A, not C.
+func deployLogPrevious(entries []staticDeployLogEntry) (staticDeployLogEntry, bool) {
+	var stack []staticDeployLogEntry
+	for _, e := range entries {
+		switch n := len(stack); {
+		case n > 0 && stack[n-1].Tag == e.Tag:
+			// A redeploy of what is live.
+		case n > 1 && stack[n-2].Tag == e.Tag:
+			stack = stack[:n-1]
+		default:
+			stack = append(stack, e)
+		}
+	}
+	if len(stack) < 2 {
+		return staticDeployLogEntry{}, false
+	}
+	return stack[len(stack)-2], true
+}
+
+// staticDeployLogEntry is one line of deploys.log (see appendDeployLog).
+type staticDeployLogEntry struct {
+	Time time.Time
+	Tag  string
+	User string
+}
+
+// readDeployLog returns the entries of the env's deploys.log, oldest first,
+// or nil if there is no log. Malformed lines are skipped.
+func (p *staticHistoryProvider) readDeployLog(ctx context.Context, service, env string) ([]staticDeployLogEntry, error) {
+	bucket := p.cfg.Services[service].Env[env].Bucket
+	key := deployLogKey
+
+	out, err := p.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading %s from s3://%s: %w", key, bucket, err)
+	}
+	defer out.Body.Close()
+
+	body, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s body: %w", key, err)
+	}
+	return parseDeployLog(string(body)), nil
+}
+
+func parseDeployLog(content string) []staticDeployLogEntry {
+	var entries []staticDeployLogEntry
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || fields[1] == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			continue
+		}
+		e := staticDeployLogEntry{Time: t, Tag: fields[1]}
+		if len(fields) > 2 {
+			e.User = fields[2]
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (p *staticHistoryProvider) since(t time.Time) time.Duration {
+	now := p.now
+	if now == nil {
+		now = time.Now
+	}
+	return now().Sub(t)
+}
+
 func (p *staticHistoryProvider) readMarker(ctx context.Context, service, env, key string) (deploy, error) {
 	bucket := p.cfg.Services[service].Env[env].Bucket
 
@@ -58,11 +159,7 @@ func (p *staticHistoryProvider) readMarker(ctx context.Context, service, env, ke
 
 	var uptime time.Duration
 	if out.LastModified != nil {
-		now := p.now
-		if now == nil {
-			now = time.Now
-		}
-		uptime = now().Sub(*out.LastModified)
+		uptime = p.since(*out.LastModified)
 	}
 
 	return deploy{
@@ -19,7 +19,7 @@ type stubS3Object struct {
 
 type stubS3 struct {
 	objects map[string]stubS3Object // keyed by "bucket/key"
-	err     error                  // global error to return
+	err     error                   // global error to return
 }
 
 func (s *stubS3) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
@@ -146,3 +146,90 @@ func TestStaticHistoryPreviousNoMarker(t *testing.T) {
 		t.Errorf("expected empty tag, got %q", d.Tag)
 	}
 }
+
+func staticDeployLogConfig() config {
+	cfg := testConfig()
+	ec := cfg.Services["frontend"].Env["staging"]
+	ec.DeployLog = true
+	cfg.Services["frontend"].Env["staging"] = ec
+	return cfg
+}
+
+func TestStaticHistoryPreviousFromDeployLog(t *testing.T) {
+	deployed := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	log := "2025-01-01T00:00:00Z\tmain-aaa1111-20250101000000\talice\n" +
+		"2025-01-02T00:00:00Z\tmain-bbb2222-20250102000000\tbob\n" +
+		"not a log line\n" +
+		"2025-01-03T00:00:00Z\tmain-ccc3333-20250103000000\tcarol\n"
+
+	p := &staticHistoryProvider{
+		cfg: staticDeployLogConfig(),
+		s3: &stubS3{
+			objects: map[string]stubS3Object{
+				"frontend-staging/deploys.log":  {body: log},
+				"frontend-staging/previous-tag": {body: "main-stale00-20240101000000"},
+			},
+		},
+		now: func() time.Time { return deployed.Add(36 * time.Hour) },
+	}
+
+	d, err := p.previous(context.Background(), "frontend", "staging")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Tag != "main-bbb2222-20250102000000" {
+		t.Errorf("tag = %q, want second-to-last log entry", d.Tag)
+	}
+	if d.Uptime != 36*time.Hour {
+		t.Errorf("uptime = %v, want %v", d.Uptime, 36*time.Hour)
+	}
+}
+
+func TestDeployLogPrevious(t *testing.T) {
+	entry := func(tag string) staticDeployLogEntry { return staticDeployLogEntry{Tag: tag} }
+	tests := []struct {
+		name string
+		tags []string
+		want string // "" means no previous deploy
+	}{
+		{"plain deploys", []string{"a", "b", "c"}, "b"},
+		{"after a rollback", []string{"a", "b", "c", "b"}, "a"},
+		{"after two rollbacks", []string{"a", "b", "c", "b", "a"}, ""},
+		{"redeploy of the live build", []string{"a", "b", "b"}, "a"},
+		{"deploy after a rollback", []string{"a", "b", "c", "b", "d"}, "b"},
+	}
+	for _, tt := range tests {
+		var entries []staticDeployLogEntry
+		for _, tag := range tt.tags {
+			entries = append(entries, entry(tag))
+		}
+		e, ok := deployLogPrevious(entries)
+		if got := e.Tag; got != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: previous = %q (%v), want %q", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestStaticHistoryPreviousFallsBackToMarker(t *testing.T) {
+	for name, objects := range map[string]map[string]stubS3Object{
+		"no log": {
+			"frontend-staging/previous-tag": {body: "main-old1234-20241231000000"},
+		},
+		"single entry": {
+			"frontend-staging/deploys.log":  {body: "2025-01-01T00:00:00Z\tmain-abc1234-20250101000000\talice\n"},
+			"frontend-staging/previous-tag": {body: "main-old1234-20241231000000"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := &staticHistoryProvider{cfg: staticDeployLogConfig(), s3: &stubS3{objects: objects}}
+
+			d, err := p.previous(context.Background(), "frontend", "staging")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d.Tag != "main-old1234-20241231000000" {
+				t.Errorf("tag = %q, want previous-tag marker", d.Tag)
+			}
+		})
+	}
+}
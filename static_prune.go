@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type s3PruneAPI interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// s3DeleteBatchSize is the maximum number of keys DeleteObjects accepts per request.
+const s3DeleteBatchSize = 1000
+
+type staticPruner struct {
+	s3     s3PruneAPI
+	bucket string
+}
+
+// deleteBuild removes every object under builds/<tag>/ and returns how many were deleted.
+func (p *staticPruner) deleteBuild(ctx context.Context, tag string) (int, error) {
+	prefix := "builds/" + tag + "/"
+	input := &s3.ListObjectsV2Input{
+		Bucket: &p.bucket,
+		Prefix: &prefix,
+	}
+
+	var keys []string
+	for {
+		out, err := p.s3.ListObjectsV2(ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("listing s3://%s/%s: %w", p.bucket, prefix, err)
+		}
+		for _, obj := range out.Contents {
+			if obj.Key != nil {
+				keys = append(keys, *obj.Key)
+			}
+		}
+		if out.IsTruncated == nil || !*out.IsTruncated {
+			break
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += s3DeleteBatchSize {
+		end := min(start+s3DeleteBatchSize, len(keys))
+		objs := make([]types.ObjectIdentifier, 0, end-start)
+		for _, k := range keys[start:end] {
+			objs = append(objs, types.ObjectIdentifier{Key: &k})
+		}
+		out, err := p.s3.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: &p.bucket,
+			Delete: &types.Delete{Objects: objs},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("deleting objects in s3://%s/%s: %w", p.bucket, prefix, err)
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return deleted, fmt.Errorf("deleting %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+		deleted += len(objs)
+	}
+	return deleted, nil
+}
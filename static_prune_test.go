@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type stubS3Prune struct {
+	stubS3List
+	deleteInputs []s3.DeleteObjectsInput
+	deleteErrors []types.Error
+}
+
+func (s *stubS3Prune) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	s.deleteInputs = append(s.deleteInputs, *params)
+	return &s3.DeleteObjectsOutput{Errors: s.deleteErrors}, nil
+}
+
+func TestStaticPrunerDeleteBuild(t *testing.T) {
+	stub := &stubS3Prune{stubS3List: stubS3List{pages: []s3.ListObjectsV2Output{
+		{Contents: s3Objects("builds/old/index.html", "builds/old/app.js"), IsTruncated: aws.Bool(true), NextContinuationToken: aws.String("next")},
+		{Contents: s3Objects("builds/old/app.css")},
+	}}}
+	p := &staticPruner{s3: stub, bucket: "my-bucket"}
+
+	n, err := p.deleteBuild(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("deleted = %d, want 3", n)
+	}
+	if len(stub.deleteInputs) != 1 {
+		t.Fatalf("expected 1 DeleteObjects call, got %d", len(stub.deleteInputs))
+	}
+	if got := len(stub.deleteInputs[0].Delete.Objects); got != 3 {
+		t.Errorf("DeleteObjects got %d keys, want 3", got)
+	}
+}
+
+func TestStaticPrunerDeleteBuildBatches(t *testing.T) {
+	var keys []string
+	for i := range 2500 {
+		keys = append(keys, fmt.Sprintf("builds/old/%d", i))
+	}
+	stub := &stubS3Prune{stubS3List: stubS3List{pages: []s3.ListObjectsV2Output{{Contents: s3Objects(keys...)}}}}
+	p := &staticPruner{s3: stub, bucket: "my-bucket"}
+
+	n, err := p.deleteBuild(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2500 {
+		t.Errorf("deleted = %d, want 2500", n)
+	}
+	if len(stub.deleteInputs) != 3 {
+		t.Errorf("expected 3 DeleteObjects calls, got %d", len(stub.deleteInputs))
+	}
+}
+
+func TestStaticPrunerDeleteBuildPartialFailure(t *testing.T) {
+	stub := &stubS3Prune{
+		stubS3List:   stubS3List{pages: []s3.ListObjectsV2Output{{Contents: s3Objects("builds/old/index.html")}}},
+		deleteErrors: []types.Error{{Key: aws.String("builds/old/index.html"), Message: aws.String("Access Denied")}},
+	}
+	p := &staticPruner{s3: stub, bucket: "my-bucket"}
+
+	_, err := p.deleteBuild(context.Background(), "old")
+	if err == nil || !strings.Contains(err.Error(), "Access Denied") {
+		t.Errorf("expected Access Denied error, got: %v", err)
+	}
+}
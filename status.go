@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -15,20 +17,35 @@ type statusRow struct {
 	Tag      string
 	Type     string
 	Uptime   time.Duration
-	Health   string // server only
-	Schedule string // cronjob only
-	LastRun  string // cronjob only: "2h ago (exit 0)"
+	Health   string            // server only
+	NodeTags map[string]string // server only: tag per node when deployed to several
+	Warning  string            // server only: e.g. "2 containers running"
+	Schedule string            // cronjob only
+	LastRun  string            // cronjob only: "2h ago (exit 0)"
 }
 
-func getStatus(ctx context.Context, cfg config, p providers, envFilter string) ([]statusRow, error) {
+// defaultStatusConcurrency bounds how many status queries run at once.
+const defaultStatusConcurrency = 8
+
+// getStatus queries the current deploy of every service/env, optionally limited
+// to one environment, service type and set of services (nil means all),
+// running at most concurrency queries at once (0 means defaultStatusConcurrency).
+func getStatus(ctx context.Context, cfg config, p providers, envFilter, typeFilter string, serviceFilter []string, concurrency int) ([]statusRow, error) {
 	type query struct {
 		name string
 		env  string
 		svc  serviceConfig
 	}
 
+	names := sortedServiceNames(cfg)
+	if serviceFilter != nil {
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return !slices.Contains(serviceFilter, name)
+		})
+	}
+
 	var queries []query
-	for _, name := range sortedServiceNames(cfg) {
+	for _, name := range filterServicesByType(cfg, names, typeFilter) {
 		svc := cfg.Services[name]
 		envs := make([]string, 0, len(svc.Env))
 		for e := range svc.Env {
@@ -53,12 +70,19 @@ func getStatus(ctx context.Context, cfg config, p providers, envFilter string) (
 		err   error
 	}
 
+	if concurrency <= 0 {
+		concurrency = defaultStatusConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+
 	results := make([]result, len(queries))
 	var wg sync.WaitGroup
 	for i, q := range queries {
 		wg.Add(1)
 		go func(i int, q query) {
 			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 			hp := p.history[q.svc.Type]
 			cur, err := hp.current(ctx, q.name, q.env)
 			if err != nil {
@@ -76,7 +100,11 @@ func getStatus(ctx context.Context, cfg config, p providers, envFilter string) (
 
 			switch q.svc.Type {
 			case "server":
-				row.Health = "healthy"
+				row.Health = cur.Health
+				row.NodeTags = cur.NodeTags
+				if cur.Running > 1 {
+					row.Warning = fmt.Sprintf("%d containers running", cur.Running)
+				}
 			case "cronjob":
 				row.Schedule = q.svc.Schedule
 				if cur.Uptime > 0 {
@@ -111,6 +139,44 @@ func formatUptime(d time.Duration) string {
 	return fmt.Sprintf("%dd", int(d.Hours()/24))
 }
 
+// statusRowJSON is a statusRow as printed by status -o json. Uptime is given
+// both as displayed and in whole seconds.
+type statusRowJSON struct {
+	Service       string            `json:"service"`
+	Env           string            `json:"env"`
+	Type          string            `json:"type"`
+	Tag           string            `json:"tag"`
+	Uptime        string            `json:"uptime"`
+	UptimeSeconds int64             `json:"uptime_seconds"`
+	Health        string            `json:"health,omitempty"`
+	NodeTags      map[string]string `json:"node_tags,omitempty"`
+	Drift         bool              `json:"drift,omitempty"`
+	Warning       string            `json:"warning,omitempty"`
+	Schedule      string            `json:"schedule,omitempty"`
+	LastRun       string            `json:"last_run,omitempty"`
+}
+
+func formatStatusJSON(rows []statusRow) ([]byte, error) {
+	out := make([]statusRowJSON, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, statusRowJSON{
+			Service:       r.Service,
+			Env:           r.Env,
+			Type:          r.Type,
+			Tag:           r.Tag,
+			Uptime:        formatUptime(r.Uptime),
+			UptimeSeconds: int64(r.Uptime / time.Second),
+			Health:        r.Health,
+			NodeTags:      r.NodeTags,
+			Drift:         deploy{NodeTags: r.NodeTags}.drifted(),
+			Warning:       r.Warning,
+			Schedule:      r.Schedule,
+			LastRun:       r.LastRun,
+		})
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
 func formatStatusTable(rows []statusRow) string {
 	if len(rows) == 0 {
 		return "No services found.\n"
@@ -165,15 +231,53 @@ func formatServerSection(b *strings.Builder, rows []statusRow) {
 	for _, r := range rows {
 		svcW = max(svcW, len(r.Service))
 		envW = max(envW, len(r.Env))
-		tagW = max(tagW, len(r.Tag))
+		tagW = max(tagW, len(serverTagCell(r)))
 		upW = max(upW, len(formatUptime(r.Uptime)))
 		healthW = max(healthW, len(r.Health))
 	}
 
 	fmt.Fprintf(b, "%-*s  %-*s  %-*s  %-*s  %-*s\n", svcW, "SERVICE", envW, "ENV", tagW, "TAG", upW, "UPTIME", healthW, "HEALTH")
 	for _, r := range rows {
-		fmt.Fprintf(b, "%-*s  %-*s  %-*s  %-*s  %-*s\n", svcW, r.Service, envW, r.Env, tagW, r.Tag, upW, formatUptime(r.Uptime), healthW, r.Health)
+		fmt.Fprintf(b, "%-*s  %-*s  %-*s  %-*s  %-*s\n", svcW, r.Service, envW, r.Env, tagW, serverTagCell(r), upW, formatUptime(r.Uptime), healthW, r.Health)
+	}
+	for _, r := range rows {
+		if (deploy{NodeTags: r.NodeTags}).drifted() {
+			fmt.Fprintf(b, "drift: %s/%s: %s\n", r.Service, r.Env, formatNodeTags(r.NodeTags))
+		}
+	}
+	for _, r := range rows {
+		if r.Warning != "" {
+			fmt.Fprintf(b, "warning: %s/%s: %s\n", r.Service, r.Env, r.Warning)
+		}
+	}
+}
+
+// serverTagCell is the TAG column for a server row, marked when its nodes
+// disagree.
+func serverTagCell(r statusRow) string {
+	if (deploy{NodeTags: r.NodeTags}).drifted() {
+		return r.Tag + " (drift)"
+	}
+	return r.Tag
+}
+
+// formatNodeTags lists the tag on each node, sorted by node name, with "-"
+// for a node running nothing.
+func formatNodeTags(tags map[string]string) string {
+	nodes := make([]string, 0, len(tags))
+	for node := range tags {
+		nodes = append(nodes, node)
+	}
+	slices.Sort(nodes)
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		tag := tags[node]
+		if tag == "" {
+			tag = "-"
+		}
+		parts[i] = node + "=" + tag
 	}
+	return strings.Join(parts, ", ")
 }
 
 func formatStaticSection(b *strings.Builder, rows []statusRow) {
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// cachedStatusRow is a status row as last seen live, with when it was seen.
+// It keeps when the deploy started rather than its uptime, so the uptime
+// shown from the cache keeps counting.
+type cachedStatusRow struct {
+	Service  string            `json:"service"`
+	Env      string            `json:"env"`
+	Tag      string            `json:"tag"`
+	Type     string            `json:"type"`
+	Started  time.Time         `json:"started,omitzero"` // zero when the uptime wasn't known
+	Health   string            `json:"health,omitempty"`
+	NodeTags map[string]string `json:"node_tags,omitempty"`
+	Warning  string            `json:"warning,omitempty"`
+	Schedule string            `json:"schedule,omitempty"`
+	LastRun  string            `json:"last_run,omitempty"`
+	Seen     time.Time         `json:"seen"`
+}
+
+func newCachedStatusRow(r statusRow, seen time.Time) cachedStatusRow {
+	c := cachedStatusRow{
+		Service:  r.Service,
+		Env:      r.Env,
+		Tag:      r.Tag,
+		Type:     r.Type,
+		Health:   r.Health,
+		NodeTags: r.NodeTags,
+		Warning:  r.Warning,
+		Schedule: r.Schedule,
+		LastRun:  r.LastRun,
+		Seen:     seen,
+	}
+	if r.Uptime > 0 {
+		c.Started = seen.Add(-r.Uptime)
+	}
+	return c
+}
+
+// row returns the cached row as a status row, with the uptime as of now.
+func (c cachedStatusRow) row(now time.Time) statusRow {
+	r := statusRow{
+		Service:  c.Service,
+		Env:      c.Env,
+		Tag:      c.Tag,
+		Type:     c.Type,
+		Health:   c.Health,
+		NodeTags: c.NodeTags,
+		Warning:  c.Warning,
+		Schedule: c.Schedule,
+		LastRun:  c.LastRun,
+	}
+	if !c.Started.IsZero() {
+		r.Uptime = now.Sub(c.Started)
+	}
+	return r
+}
+
+// statusCacheDir returns ~/.hoist, where status keeps its last good result.
+func statusCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding status cache: %w", err)
+	}
+	return filepath.Join(home, ".hoist"), nil
+}
+
+func statusCachePath(dir, project string) string {
+	return filepath.Join(dir, "status-"+project+".json")
+}
+
+// loadStatusCache reads the cached rows for project. A missing cache is not an
+// error and returns no rows.
+func loadStatusCache(dir, project string) ([]cachedStatusRow, error) {
+	data, err := os.ReadFile(statusCachePath(dir, project))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading status cache: %w", err)
+	}
+	var rows []cachedStatusRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("parsing status cache: %w", err)
+	}
+	return rows, nil
+}
+
+// saveStatusCache merges rows, seen live at now, into the cache for project.
+// Rows for other services and environments keep their older entries, so a
+// filtered status doesn't drop the rest of the snapshot.
+func saveStatusCache(dir, project string, rows []statusRow, now time.Time) error {
+	cached, err := loadStatusCache(dir, project)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		i := slices.IndexFunc(cached, func(c cachedStatusRow) bool {
+			return c.Service == r.Service && c.Env == r.Env
+		})
+		if i < 0 {
+			cached = append(cached, newCachedStatusRow(r, now))
+			continue
+		}
+		cached[i] = newCachedStatusRow(r, now)
+	}
+	slices.SortFunc(cached, func(a, b cachedStatusRow) int {
+		return cmp.Or(cmp.Compare(a.Service, b.Service), cmp.Compare(a.Env, b.Env))
+	})
+
+	body, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("creating status cache dir: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, ".hoist-status-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statusCachePath(dir, project))
+}
+
+// cachedStatus picks the cached rows matching the same filters getStatus
+// takes, with uptimes as of now, and returns when the oldest of them was seen.
+func cachedStatus(cached []cachedStatusRow, now time.Time, envFilter, typeFilter string, serviceFilter []string) ([]statusRow, time.Time) {
+	var rows []statusRow
+	var oldest time.Time
+	for _, c := range cached {
+		if envFilter != "" && c.Env != envFilter {
+			continue
+		}
+		if typeFilter != "" && c.Type != typeFilter {
+			continue
+		}
+		if serviceFilter != nil && !slices.Contains(serviceFilter, c.Service) {
+			continue
+		}
+		rows = append(rows, c.row(now))
+		if oldest.IsZero() || c.Seen.Before(oldest) {
+			oldest = c.Seen
+		}
+	}
+	return rows, oldest
+}
+
+// formatStaleBanner is printed above cached status, with seen shown in loc
+// like the times of hoist builds.
+func formatStaleBanner(seen time.Time, loc *time.Location, now time.Time) string {
+	ts := formatBuildTime(seen, loc, now)
+	if loc == time.UTC {
+		ts += " UTC"
+	}
+	return fmt.Sprintf("Nodes not queried: showing cached status, stale as of %s\n", ts)
+}
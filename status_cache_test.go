@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatusCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	first := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	if err := saveStatusCache(dir, "myapp", []statusRow{
+		{Service: "backend", Env: "staging", Tag: "old-tag", Type: "server", Uptime: 3 * time.Hour, Health: "healthy"},
+		{Service: "backend", Env: "production", Tag: "prod-tag", Type: "server"},
+	}, first); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	// A filtered status only updates the rows it queried.
+	if err := saveStatusCache(dir, "myapp", []statusRow{
+		{Service: "backend", Env: "staging", Tag: "new-tag", Type: "server"},
+	}, second); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	cached, err := loadStatusCache(dir, "myapp")
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if len(cached) != 2 {
+		t.Fatalf("expected 2 cached rows, got %d: %+v", len(cached), cached)
+	}
+	if cached[0].Env != "production" || cached[1].Tag != "new-tag" || !cached[1].Seen.Equal(second) {
+		t.Errorf("unexpected cache: %+v", cached)
+	}
+
+	rows, seen := cachedStatus(cached, second, "", "", nil)
+	if len(rows) != 2 || !seen.Equal(first) {
+		t.Errorf("all rows = %d seen %s, want 2 seen %s", len(rows), seen, first)
+	}
+	rows, seen = cachedStatus(cached, second, "staging", "", nil)
+	if len(rows) != 1 || rows[0].Tag != "new-tag" || !seen.Equal(second) {
+		t.Errorf("staging rows = %+v seen %s", rows, seen)
+	}
+	if rows, _ := cachedStatus(cached, second, "", "static", nil); len(rows) != 0 {
+		t.Errorf("expected no static rows, got %+v", rows)
+	}
+}
+
+func TestStatusCacheUptimeKeepsCounting(t *testing.T) {
+	dir := t.TempDir()
+	seen := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := saveStatusCache(dir, "myapp", []statusRow{
+		{Service: "backend", Env: "staging", Tag: "tag", Type: "server", Uptime: 3 * time.Hour},
+		{Service: "report", Env: "staging", Tag: "tag", Type: "cronjob"},
+	}, seen); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	cached, err := loadStatusCache(dir, "myapp")
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+
+	rows, _ := cachedStatus(cached, seen.Add(2*time.Hour), "", "", nil)
+	if len(rows) != 2 || rows[0].Uptime != 5*time.Hour {
+		t.Errorf("uptime two hours after caching = %+v, want 5h", rows)
+	}
+	if rows[1].Uptime != 0 {
+		t.Errorf("unknown uptime should stay unknown, got %v", rows[1].Uptime)
+	}
+}
+
+func TestLoadStatusCacheMissing(t *testing.T) {
+	cached, err := loadStatusCache(t.TempDir(), "myapp")
+	if err != nil || cached != nil {
+		t.Errorf("missing cache = %v, %v; want nil, nil", cached, err)
+	}
+}
+
+func TestFormatStaleBanner(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	seen := time.Date(2025, 6, 30, 22, 15, 0, 0, time.UTC)
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := formatStaleBanner(seen, time.UTC, now), "Nodes not queried: showing cached status, stale as of Jun 30 22:15 UTC\n"; got != want {
+		t.Errorf("UTC banner = %q, want %q", got, want)
+	}
+	if got, want := formatStaleBanner(seen, nyc, now), "Nodes not queried: showing cached status, stale as of Jun 30 18:15\n"; got != want {
+		t.Errorf("local banner = %q, want %q", got, want)
+	}
+}
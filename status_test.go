@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -20,7 +22,7 @@ func TestGetStatusAllServices(t *testing.T) {
 	}
 	p, _ := testProviders(nil, deploys)
 
-	rows, err := getStatus(context.Background(), cfg, p, "")
+	rows, err := getStatus(context.Background(), cfg, p, "", "", nil, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -55,7 +57,7 @@ func TestGetStatusFilteredByEnv(t *testing.T) {
 	}
 	p, _ := testProviders(nil, deploys)
 
-	rows, err := getStatus(context.Background(), cfg, p, "staging")
+	rows, err := getStatus(context.Background(), cfg, p, "staging", "", nil, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -69,15 +71,71 @@ func TestGetStatusFilteredByEnv(t *testing.T) {
 	}
 }
 
-func TestGetStatusTypeField(t *testing.T) {
+func TestGetStatusUnhealthyServer(t *testing.T) {
+	cfg := testConfig()
+	deploys := map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "tag1", Uptime: time.Minute, Health: "unhealthy"},
+	}
+	p, _ := testProviders(nil, deploys)
+
+	rows, err := getStatus(context.Background(), cfg, p, "staging", "server", nil, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	if rows[0].Health != "unhealthy" {
+		t.Errorf("health = %q, want %q", rows[0].Health, "unhealthy")
+	}
+}
+
+func TestGetStatusMultipleContainersWarning(t *testing.T) {
+	cfg := testConfig()
+	deploys := map[string]deploy{
+		"backend:staging": {Service: "backend", Env: "staging", Tag: "tag1", Health: "healthy", Running: 2},
+	}
+	p, _ := testProviders(nil, deploys)
+
+	rows, err := getStatus(context.Background(), cfg, p, "staging", "server", nil, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Warning != "2 containers running" {
+		t.Errorf("rows = %+v, want one with a 2 containers running warning", rows)
+	}
+}
+
+func TestGetStatusFilteredByService(t *testing.T) {
 	cfg := testConfig()
 	deploys := map[string]deploy{
 		"backend:staging":  {Service: "backend", Env: "staging", Tag: "tag1", Uptime: time.Hour},
 		"frontend:staging": {Service: "frontend", Env: "staging", Tag: "tag2", Uptime: time.Hour},
+		"report:staging":   {Service: "report", Env: "staging", Tag: "tag3"},
+	}
+	p, _ := testProviders(nil, deploys)
+
+	rows, err := getStatus(context.Background(), cfg, p, "staging", "", []string{"report", "backend"}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].Service != "backend" || rows[1].Service != "report" {
+		t.Errorf("got services %s, %s, want backend, report", rows[0].Service, rows[1].Service)
+	}
+}
+
+func TestGetStatusTypeField(t *testing.T) {
+	cfg := testConfig()
+	deploys := map[string]deploy{
+		"backend:staging":  {Service: "backend", Env: "staging", Tag: "tag1", Uptime: time.Hour, Health: "healthy"},
+		"frontend:staging": {Service: "frontend", Env: "staging", Tag: "tag2", Uptime: time.Hour},
 	}
 	p, _ := testProviders(nil, deploys)
 
-	rows, err := getStatus(context.Background(), cfg, p, "staging")
+	rows, err := getStatus(context.Background(), cfg, p, "staging", "", nil, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -110,7 +168,7 @@ func TestGetStatusMissingDeploy(t *testing.T) {
 	}
 	p, _ := testProviders(nil, deploys)
 
-	rows, err := getStatus(context.Background(), cfg, p, "staging")
+	rows, err := getStatus(context.Background(), cfg, p, "staging", "", nil, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -139,7 +197,7 @@ func TestGetStatusProviderError(t *testing.T) {
 		},
 	}
 
-	_, err := getStatus(context.Background(), cfg, p, "staging")
+	_, err := getStatus(context.Background(), cfg, p, "staging", "", nil, 0)
 	if err == nil {
 		t.Fatal("expected error from history provider")
 	}
@@ -168,6 +226,46 @@ func TestFormatUptime(t *testing.T) {
 	}
 }
 
+func TestFormatStatusJSON(t *testing.T) {
+	rows := []statusRow{
+		{Service: "backend", Env: "prod", Tag: "tag1", Type: "server", Uptime: 3 * time.Hour, Health: "healthy"},
+		{Service: "report", Env: "prod", Tag: "tag2", Type: "cronjob", Schedule: "0 0 * * *", LastRun: "never"},
+	}
+
+	out, err := formatStatusJSON(rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(got))
+	}
+	if got[0]["uptime"] != "3h" || got[0]["uptime_seconds"] != float64(10800) {
+		t.Errorf("uptime = %v / %v, want 3h / 10800", got[0]["uptime"], got[0]["uptime_seconds"])
+	}
+	if got[0]["health"] != "healthy" {
+		t.Errorf("health = %v, want healthy", got[0]["health"])
+	}
+	if _, ok := got[1]["health"]; ok {
+		t.Error("expected no health field for cronjob")
+	}
+	if got[1]["schedule"] != "0 0 * * *" {
+		t.Errorf("schedule = %v, want 0 0 * * *", got[1]["schedule"])
+	}
+	if got[1]["last_run"] != "never" {
+		t.Errorf("last_run = %v, want never", got[1]["last_run"])
+	}
+
+	empty, err := formatStatusJSON(nil)
+	if err != nil || string(empty) != "[]" {
+		t.Errorf("empty rows = %s, %v; want []", empty, err)
+	}
+}
+
 func TestFormatStatusTableGroupedByType(t *testing.T) {
 	rows := []statusRow{
 		{Service: "backend", Env: "staging", Tag: "main-abc1234-20250101000000", Type: "server", Uptime: 3 * time.Hour, Health: "healthy"},
@@ -196,6 +294,46 @@ func TestFormatStatusTableGroupedByType(t *testing.T) {
 	}
 }
 
+func TestFormatStatusTableDrift(t *testing.T) {
+	rows := []statusRow{
+		{Service: "backend", Env: "prod", Tag: "new-tag", Type: "server", Health: "healthy", NodeTags: map[string]string{"web2": "old-tag", "web1": "new-tag"}},
+	}
+	output := formatStatusTable(rows)
+
+	if !contains(output, "new-tag (drift)") {
+		t.Errorf("expected drift marker in tag column:\n%s", output)
+	}
+	if !contains(output, "drift: backend/prod: web1=new-tag, web2=old-tag") {
+		t.Errorf("expected per-node tags:\n%s", output)
+	}
+
+	out, err := formatStatusJSON(rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !contains(string(out), `"drift": true`) {
+		t.Errorf("expected drift in JSON: %s", out)
+	}
+}
+
+func TestFormatStatusTableWarning(t *testing.T) {
+	rows := []statusRow{
+		{Service: "backend", Env: "prod", Tag: "new-tag", Type: "server", Health: "healthy", Warning: "2 containers running"},
+	}
+	output := formatStatusTable(rows)
+	if !contains(output, "warning: backend/prod: 2 containers running") {
+		t.Errorf("expected warning line:\n%s", output)
+	}
+
+	out, err := formatStatusJSON(rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !contains(string(out), `"warning": "2 containers running"`) {
+		t.Errorf("expected warning in JSON: %s", out)
+	}
+}
+
 func TestFormatStatusTableCronjobSection(t *testing.T) {
 	rows := []statusRow{
 		{Service: "report", Env: "prod", Tag: "main-abc1234-20250101000000", Type: "cronjob", Schedule: "0 0 * * *", LastRun: "2h ago (exit 0)"},
@@ -277,3 +415,61 @@ func searchString(s, substr string) bool {
 	}
 	return false
 }
+
+type concurrencyHistoryProvider struct {
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (h *concurrencyHistoryProvider) current(_ context.Context, service, env string) (deploy, error) {
+	h.mu.Lock()
+	h.active++
+	h.maxSeen = max(h.maxSeen, h.active)
+	h.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	h.mu.Lock()
+	h.active--
+	h.mu.Unlock()
+	return deploy{Service: service, Env: env}, nil
+}
+
+func (h *concurrencyHistoryProvider) previous(_ context.Context, _, _ string) (deploy, error) {
+	return deploy{}, nil
+}
+
+func TestGetStatusBoundedConcurrency(t *testing.T) {
+	cfg := testConfig()
+	hp := &concurrencyHistoryProvider{}
+	p := providers{history: map[string]historyProvider{"server": hp, "static": hp, "cronjob": hp}}
+
+	rows, err := getStatus(context.Background(), cfg, p, "", "", nil, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("expected 6 rows, got %d", len(rows))
+	}
+	if hp.maxSeen > 2 {
+		t.Errorf("ran %d queries at once, want at most 2", hp.maxSeen)
+	}
+}
+
+func TestGetStatusTypeFilter(t *testing.T) {
+	cfg := testConfig()
+	p, _ := testProviders(nil, map[string]deploy{
+		"backend:staging":  {Service: "backend", Env: "staging", Tag: "main-abc1234-20250101000000"},
+		"frontend:staging": {Service: "frontend", Env: "staging", Tag: "main-abc1234-20250101000000"},
+		"report:staging":   {Service: "report", Env: "staging", Tag: "main-abc1234-20250101000000"},
+	})
+
+	rows, err := getStatus(context.Background(), cfg, p, "staging", "static", nil, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Service != "frontend" {
+		t.Errorf("rows = %+v, want only frontend", rows)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -17,25 +19,58 @@ type tag struct {
 
 var sanitizeRe = regexp.MustCompile(`[^a-zA-Z0-9.\-]`)
 
-func sanitizeBranch(s string) string {
+// defaultMaxBranchLen is how much of the branch name a tag keeps when the
+// config doesn't set max_branch_length.
+const defaultMaxBranchLen = 40
+
+// branchHashLen is the length of the hash sanitizeBranch appends to a
+// truncated branch name, plus its separating hyphen.
+const branchHashLen = 7
+
+// sanitizeBranch makes a branch name safe for a tag, keeping at most maxLen
+// characters (0 means defaultMaxBranchLen). Names that are cut short end in a
+// hash of the full name, so long branches sharing a prefix still get distinct
+// tags. Already-sanitized names come back unchanged.
+func sanitizeBranch(s string, maxLen int) string {
+	if maxLen <= 0 {
+		maxLen = defaultMaxBranchLen
+	}
+	full := s
 	s = sanitizeRe.ReplaceAllString(s, "-")
-	if len(s) > 40 {
-		s = s[:40]
+	if len(s) > maxLen {
+		sum := sha256.Sum256([]byte(full))
+		s = s[:maxLen-branchHashLen] + "-" + hex.EncodeToString(sum[:])[:branchHashLen-1]
 	}
 	return s
 }
 
-func generateTag(branch, sha string, ts time.Time, attempt int) string {
-	b := sanitizeBranch(branch)
+// branchMatches reports whether a build's branch is branch, either as given
+// or sanitized as in tags. Builds tagged before truncated names got a hash
+// have the branch cut at defaultMaxBranchLen characters, so that form matches
+// too.
+func branchMatches(buildBranch, branch string, maxLen int) bool {
+	if buildBranch == branch || buildBranch == sanitizeBranch(branch, maxLen) {
+		return true
+	}
+	legacy := sanitizeRe.ReplaceAllString(branch, "-")
+	return len(legacy) > defaultMaxBranchLen && buildBranch == legacy[:defaultMaxBranchLen]
+}
+
+func generateTag(branch, sha string, ts time.Time, attempt, maxBranchLen int) string {
 	if len(sha) > 7 {
 		sha = sha[:7]
 	}
-	stamp := ts.UTC().Format("20060102150405")
-	t := fmt.Sprintf("%s-%s-%s", b, sha, stamp)
-	if attempt >= 2 {
-		t = fmt.Sprintf("%s-%d", t, attempt)
+	return tag{Branch: sanitizeBranch(branch, maxBranchLen), SHA: sha, Time: ts, Attempt: attempt}.String()
+}
+
+// String formats t as a tag. The branch is used as is, so a parsed tag
+// formats back to the string it came from.
+func (t tag) String() string {
+	s := fmt.Sprintf("%s-%s-%s", t.Branch, t.SHA, t.Time.UTC().Format("20060102150405"))
+	if t.Attempt >= 2 {
+		s = fmt.Sprintf("%s-%d", s, t.Attempt)
 	}
-	return t
+	return s
 }
 
 var (
@@ -56,12 +91,15 @@ func parseTag(s string) (tag, error) {
 
 	attempt := 0
 
-	// Check if last segment is a numeric attempt (not a 14-digit timestamp)
+	// Check if last segment is a numeric attempt (not a 14-digit timestamp).
+	// Only the last segment can be one: numeric segments further left belong
+	// to the branch. generateTag writes attempts from 2 up without leading
+	// zeros, so anything else would not round-trip.
 	last := parts[len(parts)-1]
 	if digitRe.MatchString(last) && len(last) != 14 {
 		var err error
 		attempt, err = strconv.Atoi(last)
-		if err != nil {
+		if err != nil || attempt < 2 || strconv.Itoa(attempt) != last {
 			return tag{}, fmt.Errorf("invalid attempt: %q", last)
 		}
 		parts = parts[:len(parts)-1]
@@ -88,11 +126,10 @@ func parseTag(s string) (tag, error) {
 	}
 
 	// Everything before is the branch
-	branchParts := parts[:len(parts)-2]
-	if len(branchParts) == 0 {
+	branch := strings.Join(parts[:len(parts)-2], "-")
+	if branch == "" {
 		return tag{}, fmt.Errorf("empty branch in tag: %q", s)
 	}
-	branch := strings.Join(branchParts, "-")
 
 	return tag{
 		Branch:  branch,
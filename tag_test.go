@@ -54,12 +54,12 @@ func TestRoundTrip(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			generated := generateTag(tt.branch, tt.sha, tt.time, tt.attempt)
+			generated := generateTag(tt.branch, tt.sha, tt.time, tt.attempt, 0)
 			parsed, err := parseTag(generated)
 			if err != nil {
 				t.Fatalf("parseTag(%q) error: %v", generated, err)
 			}
-			wantBranch := sanitizeBranch(tt.branch)
+			wantBranch := sanitizeBranch(tt.branch, 0)
 			if parsed.Branch != wantBranch {
 				t.Errorf("branch = %q, want %q", parsed.Branch, wantBranch)
 			}
@@ -96,12 +96,13 @@ func TestSanitizeBranch(t *testing.T) {
 		{"dots preserved", "v1.2.3", "v1.2.3"},
 		{"hyphens preserved", "fix-bug", "fix-bug"},
 		{"spaces to hyphens", "my branch", "my-branch"},
-		{"truncation at 40", "a123456789012345678901234567890123456789extra", "a123456789012345678901234567890123456789"},
+		{"truncation at 40 with hash", "a123456789012345678901234567890123456789extra", "a12345678901234567890123456789012-fce0a1"},
+		{"exactly 40 kept", "a123456789012345678901234567890123456789", "a123456789012345678901234567890123456789"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := sanitizeBranch(tt.input)
+			got := sanitizeBranch(tt.input, 0)
 			if got != tt.want {
 				t.Errorf("sanitizeBranch(%q) = %q, want %q", tt.input, got, tt.want)
 			}
@@ -151,6 +152,9 @@ func TestParseTagErrors(t *testing.T) {
 		{"bad SHA wrong length", "main-abc12-20260213110000"},
 		{"bad timestamp", "main-abc1234-notadate"},
 		{"empty branch", "abc1234-20260213110000"},
+		{"empty branch segment", "-abc1234-20260213110000"},
+		{"attempt one", "main-abc1234-20260213110000-1"},
+		{"attempt with leading zero", "main-abc1234-20260213110000-02"},
 	}
 
 	for _, tt := range tests {
@@ -162,3 +166,74 @@ func TestParseTagErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTagNumericBranchRoundTrip(t *testing.T) {
+	ts := time.Date(2026, 2, 13, 11, 0, 0, 0, time.UTC)
+	branches := []string{
+		"2024",
+		"0",
+		"007",
+		"release-2024",
+		"2024-release",
+		"123-456",
+		"1.2.3",
+		"v2-3",
+		"20260213110000",
+		"fix-deadbee",
+	}
+
+	for _, branch := range branches {
+		for _, attempt := range []int{0, 2, 15} {
+			input := generateTag(branch, "abc1234", ts, attempt, 0)
+			t.Run(input, func(t *testing.T) {
+				parsed, err := parseTag(input)
+				if err != nil {
+					t.Fatalf("parseTag(%q) error: %v", input, err)
+				}
+				if parsed.Branch != branch {
+					t.Errorf("branch = %q, want %q", parsed.Branch, branch)
+				}
+				if parsed.Attempt != attempt {
+					t.Errorf("attempt = %d, want %d", parsed.Attempt, attempt)
+				}
+				if got := parsed.String(); got != input {
+					t.Errorf("round trip = %q, want %q", got, input)
+				}
+			})
+		}
+	}
+}
+
+func TestSanitizeBranchLongPrefixCollision(t *testing.T) {
+	a := sanitizeBranch("feature/JIRA-1234-rework-the-billing-pipeline-for-invoices", 0)
+	b := sanitizeBranch("feature/JIRA-1234-rework-the-billing-pipeline-for-refunds", 0)
+	if a == b {
+		t.Errorf("branches sharing a long prefix both sanitized to %q", a)
+	}
+	if len(a) != defaultMaxBranchLen || len(b) != defaultMaxBranchLen {
+		t.Errorf("lengths = %d, %d; want %d", len(a), len(b), defaultMaxBranchLen)
+	}
+	if again := sanitizeBranch(a, 0); again != a {
+		t.Errorf("sanitizing %q again gave %q", a, again)
+	}
+}
+
+func TestGenerateTagMaxBranchLength(t *testing.T) {
+	ts := time.Date(2026, 2, 13, 11, 0, 0, 0, time.UTC)
+	branch := "feature/a-rather-long-branch-name-that-goes-past-forty-characters"
+
+	input := generateTag(branch, "abc1234", ts, 2, 60)
+	parsed, err := parseTag(input)
+	if err != nil {
+		t.Fatalf("parseTag(%q) error: %v", input, err)
+	}
+	if len(parsed.Branch) != 60 {
+		t.Errorf("branch %q has length %d, want 60", parsed.Branch, len(parsed.Branch))
+	}
+	if got := parsed.String(); got != input {
+		t.Errorf("round trip = %q, want %q", got, input)
+	}
+	if got := generateTag(parsed.Branch, parsed.SHA, parsed.Time, parsed.Attempt, 60); got != input {
+		t.Errorf("regenerated = %q, want %q", got, input)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -38,8 +39,17 @@ type buildPickerModel struct {
 	cancelled      bool
 	fetchHistory   func(ctx context.Context) (map[string]bool, map[string]string, error)
 	historyErr     error
+	title          string
+	previousMarks  map[string]bool // rollback: tags marked [PREVIOUS]
+	blocked        map[string]bool // tags marked [BLOCKED], which can't be selected
+	staleAfter     time.Duration   // 0 means use default (defaultStaleBuildAge)
+	now            func() time.Time
 }
 
+// defaultStaleBuildAge is how old the newest build can be before the picker
+// warns that CI may not be pushing builds.
+const defaultStaleBuildAge = 7 * 24 * time.Hour
+
 func newBuildPickerModel(bp buildsProvider, env string, fetchHistory func(ctx context.Context) (map[string]bool, map[string]string, error)) buildPickerModel {
 	return buildPickerModel{
 		bp:             bp,
@@ -48,9 +58,19 @@ func newBuildPickerModel(bp buildsProvider, env string, fetchHistory func(ctx co
 		historyLoading: fetchHistory != nil,
 		pageSize:       20,
 		fetchHistory:   fetchHistory,
+		title:          "Select a build:",
 	}
 }
 
+// newRollbackPickerModel returns a build picker for choosing a rollback target.
+// Builds in previous are marked [PREVIOUS] and the cursor starts on the first one.
+func newRollbackPickerModel(bp buildsProvider, env string, fetchHistory func(ctx context.Context) (map[string]bool, map[string]string, error), previous map[string]bool) buildPickerModel {
+	m := newBuildPickerModel(bp, env, fetchHistory)
+	m.title = "Select a rollback target:"
+	m.previousMarks = previous
+	return m
+}
+
 func (m buildPickerModel) Init() tea.Cmd {
 	fetchBuilds := m.fetchBuilds(m.pageSize, 0)
 	if m.fetchHistory == nil {
@@ -84,7 +104,16 @@ func (m buildPickerModel) fetchBuilds(limit, offset int) tea.Cmd {
 func (m buildPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case buildsLoadedMsg:
+		firstPage := len(m.builds) == 0
 		m.builds = append(m.builds, msg.builds...)
+		if firstPage {
+			for i, b := range m.builds {
+				if m.previousMarks[b.Tag] {
+					m.cursor = i
+					break
+				}
+			}
+		}
 		m.offset = len(m.builds)
 		m.hasMore = msg.hasMore
 		m.loading = false
@@ -128,6 +157,9 @@ func (m buildPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "enter":
 			if m.cursor < len(m.builds) {
+				if m.blocked[m.builds[m.cursor].Tag] {
+					return m, nil
+				}
 				m.done = true
 				return m, tea.Quit
 			}
@@ -141,6 +173,31 @@ func (m buildPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// staleWarning returns a warning when the newest build is older than
+// staleAfter, or "" if it is recent enough.
+func (m buildPickerModel) staleWarning() string {
+	if len(m.builds) == 0 || m.builds[0].Time.IsZero() {
+		return ""
+	}
+	threshold := m.staleAfter
+	if threshold == 0 {
+		threshold = defaultStaleBuildAge
+	}
+	now := time.Now
+	if m.now != nil {
+		now = m.now
+	}
+	age := now().Sub(m.builds[0].Time)
+	if age <= threshold {
+		return ""
+	}
+	days := int(age / (24 * time.Hour))
+	if days == 1 {
+		return "newest build is 1 day old"
+	}
+	return fmt.Sprintf("newest build is %d days old", days)
+}
+
 func (m buildPickerModel) View() string {
 	if m.done || m.cancelled {
 		return ""
@@ -162,7 +219,11 @@ func (m buildPickerModel) View() string {
 		fmt.Fprintf(&b, "Currently live in %s: %s\n\n", m.env, strings.Join(liveTags, ", "))
 	}
 
-	b.WriteString("Select a build:\n\n")
+	if warning := m.staleWarning(); warning != "" {
+		fmt.Fprintf(&b, "warning: %s\n\n", warning)
+	}
+
+	fmt.Fprintf(&b, "%s\n\n", m.title)
 
 	for i, build := range m.builds {
 		cursor := "  "
@@ -173,6 +234,12 @@ func (m buildPickerModel) View() string {
 		if m.liveTags[build.Tag] {
 			live = " [LIVE]"
 		}
+		if m.previousMarks[build.Tag] {
+			live += " [PREVIOUS]"
+		}
+		if m.blocked[build.Tag] {
+			live += " [BLOCKED]"
+		}
 		fmt.Fprintf(&b, "%s%s%s\n", cursor, build.Tag, live)
 	}
 
@@ -188,6 +255,10 @@ func (m buildPickerModel) View() string {
 		b.WriteString("\nLoading...\n")
 	}
 
+	if m.cursor < len(m.builds) && m.blocked[m.builds[m.cursor].Tag] {
+		b.WriteString("\nThis build is in blocked_builds and can't be deployed.\n")
+	}
+
 	b.WriteString("\nenter: select  ctrl+c: cancel\n")
 	return b.String()
 }
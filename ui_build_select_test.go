@@ -116,6 +116,50 @@ func TestBuildPickerLiveMarker(t *testing.T) {
 	}
 }
 
+func TestBuildPickerStaleWarning(t *testing.T) {
+	builds := sampleBuilds(2)
+	m := newBuildPickerModel(&mockBuildsProvider{builds: builds}, "staging", nil)
+	m, _ = updateBuilds(m, buildsLoadedMsg{builds: builds})
+
+	m.now = func() time.Time { return builds[0].Time.Add(9*24*time.Hour + time.Hour) }
+	if view := m.View(); !strings.Contains(view, "warning: newest build is 9 days old") {
+		t.Errorf("expected stale build warning, got:\n%s", view)
+	}
+
+	m.now = func() time.Time { return builds[0].Time.Add(2 * 24 * time.Hour) }
+	if view := m.View(); strings.Contains(view, "warning:") {
+		t.Errorf("expected no warning for a recent build, got:\n%s", view)
+	}
+
+	m.staleAfter = 24 * time.Hour
+	if view := m.View(); !strings.Contains(view, "newest build is 2 days old") {
+		t.Errorf("expected warning with a lower threshold, got:\n%s", view)
+	}
+}
+
+func TestBuildPickerBlockedBuild(t *testing.T) {
+	builds := sampleBuilds(2)
+	m := newBuildPickerModel(&mockBuildsProvider{builds: builds}, "staging", nil)
+	m.blocked = map[string]bool{builds[0].Tag: true}
+	m, _ = updateBuilds(m, buildsLoadedMsg{builds: builds})
+
+	view := m.View()
+	if !strings.Contains(view, builds[0].Tag+" [BLOCKED]") {
+		t.Errorf("expected blocked marker, got:\n%s", view)
+	}
+
+	m, _ = updateBuilds(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.done {
+		t.Fatal("selecting a blocked build should not finish the picker")
+	}
+
+	m, _ = updateBuilds(m, tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = updateBuilds(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if !m.done || m.builds[m.cursor].Tag != builds[1].Tag {
+		t.Errorf("expected %s selected, got done=%v cursor=%d", builds[1].Tag, m.done, m.cursor)
+	}
+}
+
 func TestBuildPickerSelection(t *testing.T) {
 	builds := sampleBuilds(3)
 	bp := &mockBuildsProvider{builds: builds}
@@ -239,3 +283,28 @@ func TestBuildPickerHistoryAndBuildsLoad(t *testing.T) {
 		t.Fatalf("expected previousTags[backend] = %s, got %s", liveTag, m.previousTags["backend"])
 	}
 }
+
+func TestRollbackPickerMarksPrevious(t *testing.T) {
+	builds := sampleBuilds(3)
+	bp := &mockBuildsProvider{builds: builds}
+	previous := map[string]bool{builds[1].Tag: true}
+	m := newRollbackPickerModel(bp, "production", nil, previous)
+
+	m, _ = updateBuilds(m, m.Init()())
+	m, _ = updateBuilds(m, historyLoadedMsg{liveTags: map[string]bool{builds[0].Tag: true}})
+
+	if m.cursor != 1 {
+		t.Errorf("cursor = %d, want 1 (previous build)", m.cursor)
+	}
+
+	view := m.View()
+	if !strings.Contains(view, "Select a rollback target:") {
+		t.Errorf("expected rollback title, got:\n%s", view)
+	}
+	if !strings.Contains(view, builds[0].Tag+" [LIVE]") {
+		t.Errorf("expected live marker, got:\n%s", view)
+	}
+	if !strings.Contains(view, builds[1].Tag+" [PREVIOUS]") {
+		t.Errorf("expected previous marker, got:\n%s", view)
+	}
+}
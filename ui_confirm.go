@@ -19,18 +19,28 @@ type serviceChange struct {
 	service string
 	oldTag  string
 	newTag  string
+	commits *commitRange // nil when unknown
+	command string       // rendered docker run command or crontab line, if any
 }
 
 type confirmModel struct {
-	env     string
-	changes []serviceChange
-	result  confirmResult
+	env          string
+	changes      []serviceChange
+	rollback     bool
+	showCommands bool // expand each change into the command that will run
+	result       confirmResult
 }
 
 func newConfirmModel(env string, changes []serviceChange) confirmModel {
 	return confirmModel{env: env, changes: changes}
 }
 
+// newRollbackConfirmModel returns a confirm model worded for rolling back,
+// so it is clear the target tags are older than what is live.
+func newRollbackConfirmModel(env string, changes []serviceChange) confirmModel {
+	return confirmModel{env: env, changes: changes, rollback: true}
+}
+
 func (m confirmModel) Init() tea.Cmd { return nil }
 
 func (m confirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -43,6 +53,8 @@ func (m confirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "n", "N", "ctrl+c":
 			m.result = confirmRejected
 			return m, tea.Quit
+		case "c":
+			m.showCommands = !m.showCommands
 		}
 	}
 	return m, nil
@@ -52,6 +64,9 @@ func (m confirmModel) View() string {
 	if m.result != confirmPending {
 		return ""
 	}
+	if m.rollback {
+		return m.rollbackView()
+	}
 	var b strings.Builder
 	fmt.Fprintf(&b, "Deploy to %s:\n\n", m.env)
 
@@ -64,8 +79,53 @@ func (m confirmModel) View() string {
 			old = "(no change)"
 		}
 		fmt.Fprintf(&b, "  %-16s %s -> %s\n", c.service, old, c.newTag)
+		if c.commits != nil {
+			fmt.Fprintf(&b, "  %-16s %s\n", "", c.commits)
+		}
+		m.writeCommand(&b, c)
 	}
 
+	m.writeCommandsHint(&b)
 	b.WriteString("\nProceed? [Y/n] ")
 	return b.String()
 }
+
+func (m confirmModel) rollbackView() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ROLLBACK %s:\n\n", m.env)
+
+	for _, c := range m.changes {
+		cur := c.oldTag
+		if cur == "" {
+			cur = "(none)"
+		}
+		fmt.Fprintf(&b, "  %-16s %s, rolling back to %s\n", c.service, cur, c.newTag)
+		m.writeCommand(&b, c)
+	}
+
+	m.writeCommandsHint(&b)
+	b.WriteString("\nProceed with rollback? [Y/n] ")
+	return b.String()
+}
+
+func (m confirmModel) writeCommand(b *strings.Builder, c serviceChange) {
+	if m.showCommands && c.command != "" {
+		fmt.Fprintf(b, "    $ %s\n", c.command)
+	}
+}
+
+// writeCommandsHint tells the user about the c key when any change has a
+// command to show.
+func (m confirmModel) writeCommandsHint(b *strings.Builder) {
+	for _, c := range m.changes {
+		if c.command == "" {
+			continue
+		}
+		if m.showCommands {
+			b.WriteString("\nPress c to hide commands.\n")
+		} else {
+			b.WriteString("\nPress c to show the commands that will run.\n")
+		}
+		return
+	}
+}
@@ -93,3 +93,77 @@ func TestConfirmViewNormalChange(t *testing.T) {
 		t.Fatal("should show prompt")
 	}
 }
+
+func TestConfirmViewRollback(t *testing.T) {
+	m := newRollbackConfirmModel("production", []serviceChange{
+		{service: "backend", oldTag: "main-new1234-20250102000000", newTag: "main-old1234-20250101000000"},
+	})
+
+	view := m.View()
+	if !strings.Contains(view, "ROLLBACK production") {
+		t.Fatalf("should show rollback header, got %q", view)
+	}
+	if !strings.Contains(view, "main-new1234-20250102000000, rolling back to main-old1234-20250101000000") {
+		t.Fatalf("should show rolling back wording, got %q", view)
+	}
+	if !strings.Contains(view, "Proceed with rollback? [Y/n]") {
+		t.Fatal("should show rollback prompt")
+	}
+	if strings.Contains(view, "Deploy to") {
+		t.Fatal("should not show deploy header")
+	}
+}
+
+func TestConfirmViewCommitRange(t *testing.T) {
+	m := newConfirmModel("staging", []serviceChange{
+		{
+			service: "backend",
+			oldTag:  "main-old1234-20250101000000",
+			newTag:  "main-new1234-20250102000000",
+			commits: &commitRange{Count: 12, Subjects: []string{"Fix login redirect"}},
+		},
+	})
+
+	view := m.View()
+	if !strings.Contains(view, "12 commits, incl. Fix login redirect") {
+		t.Errorf("should show commit range, got:\n%s", view)
+	}
+}
+
+func TestConfirmToggleCommands(t *testing.T) {
+	m := newConfirmModel("production", []serviceChange{
+		{service: "backend", oldTag: "main-old1234-20250101000000", newTag: "main-new1234-20250102000000", command: "docker run '-d' 'backend:main-new1234'"},
+		{service: "frontend", oldTag: "main-old1234-20250101000000", newTag: "main-new1234-20250102000000"},
+	})
+
+	view := m.View()
+	if strings.Contains(view, "docker run") {
+		t.Fatal("commands should be hidden by default")
+	}
+	if !strings.Contains(view, "Press c to show") {
+		t.Errorf("should hint at the c key, got:\n%s", view)
+	}
+
+	m, cmd := updateConfirm(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if cmd != nil {
+		t.Fatal("c should not quit")
+	}
+	view = m.View()
+	if !strings.Contains(view, "$ docker run '-d' 'backend:main-new1234'") {
+		t.Errorf("should show command after c, got:\n%s", view)
+	}
+
+	m, _ = updateConfirm(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if strings.Contains(m.View(), "docker run") {
+		t.Fatal("second c should hide commands again")
+	}
+}
+
+func TestConfirmNoCommandsHint(t *testing.T) {
+	m := newConfirmModel("staging", []serviceChange{
+		{service: "frontend", oldTag: "main-old1234-20250101000000", newTag: "main-new1234-20250102000000"},
+	})
+	if strings.Contains(m.View(), "Press c") {
+		t.Fatal("should not hint at commands when there are none")
+	}
+}